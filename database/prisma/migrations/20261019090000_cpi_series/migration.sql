@@ -0,0 +1,24 @@
+-- AlterTable
+ALTER TABLE "Category" ADD COLUMN "coicop" TEXT;
+
+-- CreateTable
+CREATE TABLE "CpiSeries" (
+    "id" TEXT NOT NULL,
+    "coicop" TEXT NOT NULL,
+    "month" DATE NOT NULL,
+    "value" DECIMAL(10,3) NOT NULL,
+    "source" TEXT NOT NULL,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updatedAt" TIMESTAMP(3) NOT NULL,
+
+    CONSTRAINT "CpiSeries_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "Category_coicop_idx" ON "Category"("coicop");
+
+-- CreateIndex
+CREATE INDEX "CpiSeries_month_idx" ON "CpiSeries"("month");
+
+-- CreateIndex
+CREATE UNIQUE INDEX "CpiSeries_coicop_month_key" ON "CpiSeries"("coicop", "month");
@@ -0,0 +1,21 @@
+-- CreateTable
+CREATE TABLE "PriceDaily" (
+    "productId" TEXT NOT NULL,
+    "day" DATE NOT NULL,
+    "chain" TEXT NOT NULL DEFAULT '',
+    "district" TEXT NOT NULL DEFAULT '',
+    "minPrice" DECIMAL(10,2) NOT NULL,
+    "maxPrice" DECIMAL(10,2) NOT NULL,
+    "meanPrice" DECIMAL(10,4) NOT NULL,
+    "medianPrice" DECIMAL(10,4) NOT NULL,
+    "sampleCount" INTEGER NOT NULL,
+    "updatedAt" TIMESTAMP(3) NOT NULL,
+
+    CONSTRAINT "PriceDaily_pkey" PRIMARY KEY ("productId","day","chain","district")
+);
+
+-- CreateIndex
+CREATE INDEX "PriceDaily_day_idx" ON "PriceDaily"("day");
+
+-- AddForeignKey
+ALTER TABLE "PriceDaily" ADD CONSTRAINT "PriceDaily_productId_fkey" FOREIGN KEY ("productId") REFERENCES "Product"("id") ON DELETE RESTRICT ON UPDATE CASCADE;
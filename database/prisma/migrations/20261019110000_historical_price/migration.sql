@@ -0,0 +1,20 @@
+-- CreateTable
+CREATE TABLE "HistoricalPrice" (
+    "id" TEXT NOT NULL,
+    "productId" TEXT NOT NULL,
+    "price" DECIMAL(10,2) NOT NULL,
+    "observedAt" TIMESTAMP(3) NOT NULL,
+    "source" TEXT NOT NULL,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "HistoricalPrice_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "HistoricalPrice_productId_observedAt_idx" ON "HistoricalPrice"("productId", "observedAt");
+
+-- CreateIndex
+CREATE UNIQUE INDEX "HistoricalPrice_productId_observedAt_source_key" ON "HistoricalPrice"("productId", "observedAt", "source");
+
+-- AddForeignKey
+ALTER TABLE "HistoricalPrice" ADD CONSTRAINT "HistoricalPrice_productId_fkey" FOREIGN KEY ("productId") REFERENCES "Product"("id") ON DELETE RESTRICT ON UPDATE CASCADE;
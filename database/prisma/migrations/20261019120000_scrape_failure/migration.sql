@@ -0,0 +1,17 @@
+-- CreateTable
+CREATE TABLE "ScrapeFailure" (
+    "id" TEXT NOT NULL,
+    "phase" TEXT NOT NULL,
+    "categoryExternalId" INTEGER,
+    "productExternalId" INTEGER,
+    "regionId" INTEGER,
+    "regionName" TEXT,
+    "error" TEXT NOT NULL,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "resolvedAt" TIMESTAMP(3),
+
+    CONSTRAINT "ScrapeFailure_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "ScrapeFailure_resolvedAt_idx" ON "ScrapeFailure"("resolvedAt");
@@ -0,0 +1,18 @@
+-- Prices the source gives no date for, such as a product's previous price,
+-- have no "observedAt" and are kept once per product and source. Backfills
+-- used to record them against the day they ran; keep the latest of those.
+DELETE FROM "HistoricalPrice" h
+USING "HistoricalPrice" newer
+WHERE h."source" IN ('ekalathi_previous_price', 'ekalathi_start_price')
+  AND newer."productId" = h."productId"
+  AND newer."source" = h."source"
+  AND newer."observedAt" > h."observedAt";
+
+-- AlterTable
+ALTER TABLE "HistoricalPrice" ALTER COLUMN "observedAt" DROP NOT NULL;
+
+UPDATE "HistoricalPrice" SET "observedAt" = NULL
+WHERE "source" IN ('ekalathi_previous_price', 'ekalathi_start_price');
+
+-- CreateIndex (partial, which schema.prisma cannot express)
+CREATE UNIQUE INDEX "HistoricalPrice_productId_source_undated_key" ON "HistoricalPrice"("productId", "source") WHERE "observedAt" IS NULL;
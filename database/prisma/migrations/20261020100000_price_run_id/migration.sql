@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE "Price" ADD COLUMN "runId" TEXT;
+
+-- CreateIndex
+CREATE INDEX "Price_runId_idx" ON "Price"("runId");
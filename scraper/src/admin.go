@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxRunHistory is how many finished runs GET /runs remembers
+const maxRunHistory = 50
+
+var (
+	errRunActive    = errors.New("a run is already in progress")
+	errRunNotFound  = errors.New("run not found")
+	errRunNotActive = errors.New("run is not in progress")
+)
+
+// Run statuses
+const (
+	runStatusRunning   = "running"
+	runStatusSucceeded = "succeeded"
+	runStatusFailed    = "failed"
+	runStatusCancelled = "cancelled"
+)
+
+// RunInfo describes a run started through the RunManager
+type RunInfo struct {
+	ID         string     `json:"id"`
+	Trigger    string     `json:"trigger"`
+	Status     string     `json:"status"`
+	Options    RunOptions `json:"options"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+type managedRun struct {
+	info      RunInfo
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// RunManager starts runs, keeps a short history of them and makes sure only
+// one is active at a time. In serve mode both scheduled runs and runs started
+// through the admin API go through it.
+type RunManager struct {
+	ctx    context.Context
+	run    func(ctx context.Context, opts RunOptions) error
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	active  *managedRun
+	history []*managedRun // newest first
+}
+
+// NewRunManager returns a manager whose runs call run. Runs started in the
+// background derive their context from ctx, so they stop on shutdown.
+func NewRunManager(ctx context.Context, logger *slog.Logger, run func(ctx context.Context, opts RunOptions) error) *RunManager {
+	return &RunManager{ctx: ctx, run: run, logger: logger}
+}
+
+// Run runs synchronously, failing with errRunActive if another run is active
+func (m *RunManager) Run(ctx context.Context, opts RunOptions, trigger string) error {
+	r, runCtx, err := m.begin(ctx, opts, trigger)
+	if err != nil {
+		return err
+	}
+	err = m.run(runCtx, opts)
+	m.finish(r, err)
+	return err
+}
+
+// Start runs in the background and returns as soon as the run is registered
+func (m *RunManager) Start(opts RunOptions, trigger string) (RunInfo, error) {
+	r, runCtx, err := m.begin(m.ctx, opts, trigger)
+	if err != nil {
+		return RunInfo{}, err
+	}
+	go func() {
+		m.finish(r, m.run(runCtx, opts))
+	}()
+	return r.info, nil
+}
+
+// Cancel cancels the context of an active run. The run is marked cancelled
+// once it has stopped.
+func (m *RunManager) Cancel(id string) (RunInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.history {
+		if r.info.ID != id {
+			continue
+		}
+		if r != m.active {
+			return r.info, errRunNotActive
+		}
+		r.cancelled = true
+		r.cancel()
+		return r.info, nil
+	}
+	return RunInfo{}, errRunNotFound
+}
+
+// Active returns the active run, if any
+func (m *RunManager) Active() (RunInfo, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active == nil {
+		return RunInfo{}, false
+	}
+	return m.active.info, true
+}
+
+// List returns the recent runs, newest first
+func (m *RunManager) List() []RunInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runs := make([]RunInfo, len(m.history))
+	for i, r := range m.history {
+		runs[i] = r.info
+	}
+	return runs
+}
+
+func (m *RunManager) begin(ctx context.Context, opts RunOptions, trigger string) (*managedRun, context.Context, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.active != nil {
+		return nil, nil, errRunActive
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	r := &managedRun{
+		info: RunInfo{
+			ID:        uuid.New().String(),
+			Trigger:   trigger,
+			Status:    runStatusRunning,
+			Options:   opts,
+			StartedAt: time.Now().UTC(),
+		},
+		cancel: cancel,
+	}
+	runCtx = withRunID(runCtx, r.info.ID)
+	m.active = r
+	m.history = append([]*managedRun{r}, m.history...)
+	if len(m.history) > maxRunHistory {
+		m.history = m.history[:maxRunHistory]
+	}
+
+	m.logger.Info("run started", "runID", r.info.ID, "trigger", trigger)
+	return r, runCtx, nil
+}
+
+func (m *RunManager) finish(r *managedRun, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r.cancel()
+	finished := time.Now().UTC()
+	r.info.FinishedAt = &finished
+	switch {
+	case r.cancelled:
+		r.info.Status = runStatusCancelled
+	case err != nil:
+		r.info.Status = runStatusFailed
+	default:
+		r.info.Status = runStatusSucceeded
+	}
+	if err != nil {
+		r.info.Error = err.Error()
+	}
+	if m.active == r {
+		m.active = nil
+	}
+
+	m.logger.Info("run finished", "runID", r.info.ID, "status", r.info.Status)
+}
+
+// --- Admin API ---
+
+// registerAdminRoutes adds the run endpoints to mux behind bearer token auth
+func registerAdminRoutes(mux *http.ServeMux, manager *RunManager, token string) {
+	mux.Handle("GET /runs", requireToken(token, http.HandlerFunc(manager.handleList)))
+	mux.Handle("POST /runs", requireToken(token, http.HandlerFunc(manager.handleStart)))
+	mux.Handle("DELETE /runs/{id}", requireToken(token, http.HandlerFunc(manager.handleCancel)))
+}
+
+// requireToken rejects requests without an "Authorization: Bearer <token>"
+// header matching token
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scraper"`)
+			writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *RunManager) handleList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, m.List())
+}
+
+// handleStart starts a run. The body is optional; without one every phase
+// runs over everything.
+func (m *RunManager) handleStart(w http.ResponseWriter, r *http.Request) {
+	var opts RunOptions
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&opts); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid run options: %w", err))
+		return
+	}
+
+	info, err := m.Start(opts, "api")
+	switch {
+	case errors.Is(err, errRunActive):
+		active, _ := m.Active()
+		writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error(), "active": active})
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		w.Header().Set("Location", "/runs/"+info.ID)
+		writeJSON(w, http.StatusAccepted, info)
+	}
+}
+
+func (m *RunManager) handleCancel(w http.ResponseWriter, r *http.Request) {
+	info, err := m.Cancel(r.PathValue("id"))
+	switch {
+	case errors.Is(err, errRunNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, errRunNotActive):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeJSON(w, http.StatusAccepted, info)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAdminToken = "secret"
+
+// newTestAdmin returns a mux serving the admin API over a manager whose runs
+// block until cancelled
+func newTestAdmin(t *testing.T) (*http.ServeMux, *RunManager) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	manager := NewRunManager(ctx, slog.New(slog.DiscardHandler), func(ctx context.Context, opts RunOptions) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	mux := http.NewServeMux()
+	registerAdminRoutes(mux, manager, testAdminToken)
+	return mux, manager
+}
+
+func adminRequest(mux *http.ServeMux, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminAuth(t *testing.T) {
+	mux, _ := newTestAdmin(t)
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode int
+	}{
+		{"missing token", "", http.StatusUnauthorized},
+		{"wrong token", "nope", http.StatusUnauthorized},
+		{"valid token", testAdminToken, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := adminRequest(mux, "GET", "/runs", tt.token, "")
+			if rec.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", rec.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestAdminRunLifecycle(t *testing.T) {
+	mux, manager := newTestAdmin(t)
+
+	rec := adminRequest(mux, "POST", "/runs", testAdminToken, `{"phases": ["products"], "scope": {"includeCategories": [7]}}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("start: status code = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body)
+	}
+	var started RunInfo
+	if err := json.NewDecoder(rec.Body).Decode(&started); err != nil {
+		t.Fatalf("failed to decode run: %v", err)
+	}
+	if started.Status != runStatusRunning || started.Trigger != "api" {
+		t.Errorf("status/trigger = %q/%q, want running/api", started.Status, started.Trigger)
+	}
+	if len(started.Options.Scope.IncludeCategories) != 1 || started.Options.Scope.IncludeCategories[0] != 7 {
+		t.Errorf("scope = %+v, want includeCategories [7]", started.Options.Scope)
+	}
+
+	// Only one run at a time, whether started by the API or the scheduler
+	if rec := adminRequest(mux, "POST", "/runs", testAdminToken, ""); rec.Code != http.StatusConflict {
+		t.Errorf("second start: status code = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if err := manager.Run(context.Background(), RunOptions{}, "schedule"); err != errRunActive {
+		t.Errorf("scheduled run error = %v, want %v", err, errRunActive)
+	}
+
+	if rec := adminRequest(mux, "DELETE", "/runs/"+started.ID, testAdminToken, ""); rec.Code != http.StatusAccepted {
+		t.Fatalf("cancel: status code = %d, want %d", rec.Code, http.StatusAccepted)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, active := manager.Active(); !active {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("run did not stop after cancel")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	rec = adminRequest(mux, "GET", "/runs", testAdminToken, "")
+	var runs []RunInfo
+	if err := json.NewDecoder(rec.Body).Decode(&runs); err != nil {
+		t.Fatalf("failed to decode runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].ID != started.ID || runs[0].Status != runStatusCancelled || runs[0].FinishedAt == nil {
+		t.Errorf("runs = %+v, want the cancelled run", runs)
+	}
+
+	if rec := adminRequest(mux, "DELETE", "/runs/"+started.ID, testAdminToken, ""); rec.Code != http.StatusConflict {
+		t.Errorf("cancel finished run: status code = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestAdminBadRequests(t *testing.T) {
+	mux, _ := newTestAdmin(t)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+	}{
+		{"unknown phase", "POST", "/runs", `{"phases": ["nope"]}`, http.StatusBadRequest},
+		{"unknown field", "POST", "/runs", `{"phase": "prices"}`, http.StatusBadRequest},
+		{"malformed body", "POST", "/runs", `{`, http.StatusBadRequest},
+		{"cancel unknown run", "DELETE", "/runs/missing", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := adminRequest(mux, tt.method, tt.path, testAdminToken, tt.body)
+			if rec.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// --- Aggregate Methods ---
+
+// refreshAggregates recomputes the "PriceDaily" aggregates for every UTC day
+// between from and to, i.e. the days a run may have inserted prices into
+func (s *Scraper) refreshAggregates(ctx context.Context, from, to time.Time) error {
+	ctx, span := startPhaseSpan(ctx, phaseAggregates)
+	defer span.End()
+
+	days := utcDays(from, to)
+	s.progress.StartPhase(phaseAggregates, len(days))
+	for _, day := range days {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err := s.db.RefreshDailyAggregates(ctx, day); err != nil {
+			return fmt.Errorf("failed to refresh aggregates for %s: %w", day.Format(time.DateOnly), err)
+		}
+		s.logger.Info("refreshed daily aggregates", "day", day.Format(time.DateOnly))
+		s.progress.Done(0)
+	}
+	return nil
+}
+
+// utcDays returns the start of each UTC day touched by the [from, to] interval
+func utcDays(from, to time.Time) []time.Time {
+	from = from.UTC()
+	to = to.UTC()
+
+	var days []time.Time
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	for !day.After(to) {
+		days = append(days, day)
+		day = day.AddDate(0, 0, 1)
+	}
+	return days
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/archive"
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+)
+
+// errArchiveURL is returned by reprocess when no archive is configured
+var errArchiveURL = errors.New("an archive is required: set ARCHIVE_URL or archive.url in the config file")
+
+// archivedRun is what a run archives besides its responses: enough to send
+// the same requests again on replay
+type archivedRun struct {
+	Options         RunOptions `json:"options"`
+	ProductPageSize int        `json:"productPageSize"`
+	BranchPageSize  int        `json:"branchPageSize"`
+}
+
+// replayedPrices buffers the prices a replay derives, so none are stored
+// unless all of the run's responses could be replayed
+type replayedPrices struct {
+	mu     sync.Mutex
+	prices []store.Price
+}
+
+func (b *replayedPrices) add(p store.Price) {
+	b.mu.Lock()
+	b.prices = append(b.prices, p)
+	b.mu.Unlock()
+}
+
+type recorderKey struct{}
+
+// withRecorder attaches the recorder archiving the responses of ctx's run
+func withRecorder(ctx context.Context, rec *archive.Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, rec)
+}
+
+// recorderFromContext returns the recorder attached to ctx, if any
+func recorderFromContext(ctx context.Context) *archive.Recorder {
+	rec, _ := ctx.Value(recorderKey{}).(*archive.Recorder)
+	return rec
+}
+
+// archiveTransport archives every response to a request made during a run
+// with a recorder. The body is read in full first, so callers still get all
+// of it; failing to archive is logged and doesn't fail the request.
+type archiveTransport struct {
+	next   http.RoundTripper
+	logger *slog.Logger
+}
+
+func (t *archiveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := recorderFromContext(req.Context())
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || rec == nil {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := rec.Record(req.Context(), req.Method, req.URL.String(), resp.StatusCode, body); err != nil {
+		t.logger.Warn("failed to archive response", "runID", runIDFromContext(req.Context()), "url", req.URL.String(), "error", err)
+	}
+	return resp, nil
+}
+
+// startArchive starts archiving the responses of a run when an archive is
+// configured. The returned function writes the run's manifest once the run
+// has finished with runErr.
+func (s *Scraper) startArchive(ctx context.Context, opts RunOptions) (context.Context, func(runErr error)) {
+	if s.archive == nil {
+		return ctx, func(error) {}
+	}
+
+	rec, err := archive.NewRecorder(s.archive, runIDFromContext(ctx), archivedRun{
+		Options:         opts,
+		ProductPageSize: s.scrape.ProductPageSize,
+		BranchPageSize:  s.scrape.BranchPageSize,
+	}, time.Now())
+	if err != nil {
+		s.logger.Error("failed to start archiving responses", "error", err)
+		return ctx, func(error) {}
+	}
+
+	return withRecorder(ctx, rec), func(runErr error) {
+		// Write the manifest even when the run was interrupted
+		run, err := rec.Close(context.WithoutCancel(ctx), runErr)
+		if err != nil {
+			s.logger.Error("failed to archive run", "error", err)
+			return
+		}
+		if run.Failed > 0 {
+			s.logger.Warn("some responses could not be archived", "count", run.Failed)
+		}
+		s.logger.Info("archived run responses", "responses", len(run.Responses))
+	}
+}
+
+// Reprocess rebuilds the rows of an archived run from the responses it
+// received: they are parsed again as a new run would, and once every response
+// has been replayed, the prices the run stored are replaced by the ones
+// derived now, at their original times, in one transaction. A replay that
+// fails changes no prices. A dry run only reports what would change.
+func (s *Scraper) Reprocess(ctx context.Context, runID string, dryRun bool) error {
+	if s.archive == nil {
+		return errArchiveURL
+	}
+
+	run, err := archive.LoadRun(ctx, s.archive, runID)
+	if err != nil {
+		return err
+	}
+	var archived archivedRun
+	if err := json.Unmarshal(run.Options, &archived); err != nil {
+		return fmt.Errorf("failed to parse the options of run %s: %w", runID, err)
+	}
+
+	switch {
+	case dryRun:
+	case archived.Options.DryRun:
+		return fmt.Errorf("run %s was a dry run and stored nothing to rebuild; pass --dry-run to replay it", runID)
+	case run.Failed > 0:
+		return fmt.Errorf("run %s is missing %d responses that could not be archived; pass --dry-run to replay the rest", runID, run.Failed)
+	}
+
+	replay := archive.NewReplay(s.archive, run)
+	r := *s
+	r.client = &http.Client{Transport: replay}
+	r.archive = nil
+	r.replay = replay
+	r.replayed = &replayedPrices{}
+	// Send the requests the run sent, one at a time so replay.LastServed
+	// dates each price, without pausing or retrying
+	r.scrape.ProductPageSize = archived.ProductPageSize
+	r.scrape.BranchPageSize = archived.BranchPageSize
+	r.scrape.Concurrency = 1
+	r.scrape.PageDelay = 0
+	r.scrape.ItemDelay = 0
+	r.retry.MaxRetries = 0
+
+	opts := archived.Options
+	opts.DryRun = dryRun
+	s.logger.Info("reprocessing archived run", "archivedRunID", runID, "startedAt", run.StartedAt, "responses", len(run.Responses), "dryRun", dryRun)
+
+	if err := r.Run(ctx, opts); err != nil {
+		return err
+	}
+	if dryRun {
+		return nil
+	}
+	if missed := replay.Missed(); missed > 0 {
+		return fmt.Errorf("%d requests of run %s could not be replayed; no prices were changed", missed, runID)
+	}
+
+	if opts.runs(phasePrices) {
+		// Prices stored before runs were recorded have no run ID; those
+		// scraped while the run was going are taken to be its own
+		deleted, err := s.db.ReplacePrices(ctx, runID, run.StartedAt, run.FinishedAt, r.replayed.prices)
+		if err != nil {
+			return fmt.Errorf("failed to replace the prices of run %s: %w", runID, err)
+		}
+		s.logger.Info("replaced the prices the run stored", "deleted", deleted, "inserted", len(r.replayed.prices))
+	}
+	if opts.runs(phasePrices) || opts.runs(phaseAggregates) {
+		if err := s.refreshAggregates(ctx, run.StartedAt, run.FinishedAt); err != nil {
+			return fmt.Errorf("failed to refresh aggregates: %w", err)
+		}
+	}
+	return nil
+}
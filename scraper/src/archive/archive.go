@@ -0,0 +1,90 @@
+// Package archive keeps the raw eKalathi responses of scrape runs, so rows
+// can be re-derived after a parsing fix. Response bodies are stored once,
+// gzip-compressed and named by their SHA-256; each run has a manifest listing
+// the requests it made and the bodies they returned. Archives live in a local
+// directory or an S3-compatible bucket.
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrNotFound is returned for keys that are not in the bucket
+var ErrNotFound = errors.New("not found")
+
+// Bucket stores archive objects by slash-separated key. Implementations must
+// be safe for concurrent use.
+type Bucket interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns the object at key, or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// Options configures the S3 buckets Open creates
+type Options struct {
+	S3Endpoint        string // e.g. http://localhost:9000 for MinIO; empty means AWS
+	S3Region          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	Client            *http.Client // defaults to http.DefaultClient
+}
+
+// Open returns the bucket an archive URL names: a directory path or
+// file:///path/to/dir, or s3://bucket/prefix
+func Open(rawURL string, opts Options) (Bucket, error) {
+	if err := CheckURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	u, _ := url.Parse(rawURL)
+	switch u.Scheme {
+	case "s3":
+		return NewS3(u.Host, strings.Trim(u.Path, "/"), opts)
+	case "file":
+		return NewDir(u.Host + u.Path), nil
+	}
+	return NewDir(rawURL), nil
+}
+
+// CheckURL reports whether Open supports an archive URL
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid archive URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "":
+		if rawURL == "" {
+			return errors.New("archive URL is empty")
+		}
+	case "file":
+		if u.Host+u.Path == "" {
+			return errors.New("file archive URL needs a directory, e.g. file:///var/lib/scraper/archive")
+		}
+	case "s3":
+		if u.Host == "" {
+			return errors.New("s3 archive URL needs a bucket, e.g. s3://scraper-archive/responses")
+		}
+	default:
+		return fmt.Errorf("unsupported archive URL scheme %q (want a directory, file:// or s3://)", u.Scheme)
+	}
+	return nil
+}
+
+// objectKey is where a response body with a SHA-256 hash is stored. The
+// first two hex digits spread the objects over 256 directories.
+func objectKey(hash string) string {
+	return "objects/" + hash[:2] + "/" + hash + ".json.gz"
+}
+
+// runKey is where the manifest of a run is stored
+func runKey(runID string) string {
+	return "runs/" + runID + ".json"
+}
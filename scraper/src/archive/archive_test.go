@@ -0,0 +1,146 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"/var/lib/scraper/archive", false},
+		{"archive", false},
+		{"file:///var/lib/scraper/archive", false},
+		{"s3://scraper-archive/responses", false},
+		{"s3://scraper-archive", false},
+		{"", true},
+		{"file://", true},
+		{"s3:///responses", true},
+		{"gs://bucket", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if err := CheckURL(tt.url); (err != nil) != tt.wantErr {
+				t.Errorf("CheckURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// recordRun archives a run with a retried request and a repeated body
+func recordRun(t *testing.T, bucket Bucket) *Run {
+	t.Helper()
+	ctx := context.Background()
+
+	rec, err := NewRecorder(bucket, "run-1", map[string]int{"pageSize": 20}, timeOf("2026-03-01T08:00:00Z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses := []struct {
+		url    string
+		status int
+		body   string
+	}{
+		{"https://example.com/regions", 200, `[{"id":1}]`},
+		{"https://example.com/branches?page=0", 502, `bad gateway`},
+		{"https://example.com/branches?page=0", 200, `{"content":[]}`},
+		{"https://example.com/branches?page=1", 200, `{"content":[]}`},
+	}
+	for _, r := range responses {
+		if err := rec.Record(ctx, "GET", r.url, r.status, []byte(r.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run, err := rec.Close(ctx, errors.New("interrupted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Responses) != 4 || run.Error != "interrupted" || run.FinishedAt.IsZero() {
+		t.Errorf("Close() = %+v, want 4 responses and the run error", run)
+	}
+	return &run
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	bucket := NewDir(root)
+	recordRun(t, bucket)
+
+	objects, _ := filepath.Glob(filepath.Join(root, "objects", "*", "*.json.gz"))
+	if len(objects) != 3 {
+		t.Errorf("stored %d bodies, want 3 distinct ones: %v", len(objects), objects)
+	}
+
+	run, err := LoadRun(ctx, bucket, "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var options map[string]int
+	json.Unmarshal(run.Options, &options)
+	if options["pageSize"] != 20 || len(run.Responses) != 4 {
+		t.Errorf("LoadRun() = %+v, want the recorded options and responses", run)
+	}
+
+	replay := NewReplay(bucket, run)
+	client := &http.Client{Transport: replay}
+	resp, err := client.Get("https://example.com/branches?page=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != `{"content":[]}` {
+		t.Errorf("replayed %d %q, want the successful retry", resp.StatusCode, body)
+	}
+	if !replay.LastServed().Equal(run.Responses[2].Time) {
+		t.Errorf("LastServed() = %v, want the time of the retry, %v", replay.LastServed(), run.Responses[2].Time)
+	}
+
+	if replay.Missed() != 0 {
+		t.Errorf("Missed() = %d before any miss, want 0", replay.Missed())
+	}
+	if _, err := client.Get("https://example.com/branches?page=2"); err == nil {
+		t.Error("replaying a request that was not archived should fail")
+	}
+	if replay.Missed() != 1 {
+		t.Errorf("Missed() = %d, want the request that was not archived", replay.Missed())
+	}
+	if _, err := LoadRun(ctx, bucket, "run-2"); err == nil {
+		t.Error("LoadRun() of an unknown run should fail")
+	}
+	if _, err := LoadRun(ctx, bucket, "../run-1"); err == nil {
+		t.Error("LoadRun() should reject IDs outside the runs directory")
+	}
+}
+
+func TestBodyCorrupt(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	bucket := NewDir(root)
+	run := recordRun(t, bucket)
+
+	response := run.Responses[0]
+	other := run.Responses[2]
+	// Swap in another body under the first one's name
+	data, _ := os.ReadFile(filepath.Join(root, filepath.FromSlash(objectKey(other.SHA256))))
+	if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(objectKey(response.SHA256))), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Body(ctx, bucket, response); err == nil {
+		t.Error("Body() should reject a body that doesn't match its hash")
+	}
+	if _, err := Body(ctx, bucket, Response{SHA256: "00" + response.SHA256[2:]}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Body() of a missing object error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Replay is an http.RoundTripper that answers requests with the responses
+// archived for a run instead of sending them. When a run made the same
+// request more than once, the first successful response is served, so a
+// replay sees what retries eventually got.
+type Replay struct {
+	bucket    Bucket
+	run       *Run
+	responses map[string]Response // by method and URL
+
+	mu     sync.Mutex
+	last   time.Time
+	missed int
+}
+
+// NewReplay returns a transport serving the responses of an archived run
+func NewReplay(bucket Bucket, run *Run) *Replay {
+	responses := make(map[string]Response)
+	for _, r := range run.Responses {
+		key := r.Method + " " + r.URL
+		if previous, ok := responses[key]; !ok || previous.Status != http.StatusOK {
+			responses[key] = r
+		}
+	}
+	return &Replay{bucket: bucket, run: run, responses: responses}
+}
+
+// Missed returns how many requests could not be answered because their
+// response was not archived or could not be read back
+func (p *Replay) Missed() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.missed
+}
+
+// Run returns the manifest of the run being replayed
+func (p *Replay) Run() *Run {
+	return p.run
+}
+
+// LastServed returns when the response served last was originally received.
+// With one request at a time, that dates whatever the caller derives from it.
+func (p *Replay) LastServed() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.last
+}
+
+func (p *Replay) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	response, ok := p.responses[req.Method+" "+req.URL.String()]
+	if !ok {
+		p.miss()
+		return nil, fmt.Errorf("%s %s was not archived in run %s", req.Method, req.URL, p.run.ID)
+	}
+	body, err := Body(req.Context(), p.bucket, response)
+	if err != nil {
+		p.miss()
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.last = response.Time
+	p.mu.Unlock()
+
+	return &http.Response{
+		Status:        strconv.Itoa(response.Status) + " " + http.StatusText(response.Status),
+		StatusCode:    response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func (p *Replay) miss() {
+	p.mu.Lock()
+	p.missed++
+	p.mu.Unlock()
+}
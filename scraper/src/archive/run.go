@@ -0,0 +1,186 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Run is the manifest of an archived run
+type Run struct {
+	ID string `json:"id"`
+	// Options are what the scraper needs to replay the run, as it encoded
+	// them
+	Options    json.RawMessage `json:"options,omitempty"`
+	StartedAt  time.Time       `json:"startedAt"`
+	FinishedAt time.Time       `json:"finishedAt"`
+	Error      string          `json:"error,omitempty"`
+	// Failed counts the responses that could not be archived
+	Failed    int        `json:"failed,omitempty"`
+	Responses []Response `json:"responses"`
+}
+
+// Response is a request a run made and the body it got back
+type Response struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	URL    string    `json:"url"`
+	Status int       `json:"status"`
+	Size   int       `json:"size"`   // bytes before compression
+	SHA256 string    `json:"sha256"` // of the uncompressed body
+}
+
+// Recorder archives the responses of one run. Record may be called
+// concurrently; Close writes the manifest.
+type Recorder struct {
+	bucket Bucket
+
+	mu     sync.Mutex
+	run    Run
+	stored map[string]bool // body hashes already in the bucket
+}
+
+// NewRecorder starts archiving a run. options are stored in the manifest as
+// JSON.
+func NewRecorder(bucket Bucket, runID string, options any, startedAt time.Time) (*Recorder, error) {
+	if err := checkRunID(runID); err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode run options: %w", err)
+	}
+
+	return &Recorder{
+		bucket: bucket,
+		run:    Run{ID: runID, Options: encoded, StartedAt: startedAt.UTC()},
+		stored: make(map[string]bool),
+	}, nil
+}
+
+// Record archives a response body. Bodies already in the bucket, from this
+// run or an earlier one, are not stored again. A body that can't be stored
+// is counted in the manifest's Failed.
+func (r *Recorder) Record(ctx context.Context, method, url string, status int, body []byte) error {
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	response := Response{Time: time.Now().UTC(), Method: method, URL: url, Status: status, Size: len(body), SHA256: hash}
+
+	r.mu.Lock()
+	stored := r.stored[hash]
+	r.stored[hash] = true
+	r.mu.Unlock()
+
+	if !stored {
+		if err := r.store(ctx, hash, body); err != nil {
+			r.mu.Lock()
+			delete(r.stored, hash)
+			r.run.Failed++
+			r.mu.Unlock()
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	r.run.Responses = append(r.run.Responses, response)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Recorder) store(ctx context.Context, hash string, body []byte) error {
+	key := objectKey(hash)
+	if exists, err := r.bucket.Exists(ctx, key); err != nil || exists {
+		return err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return r.bucket.Put(ctx, key, buf.Bytes())
+}
+
+// Close finishes the run, recording runErr if it failed, and writes its
+// manifest. It returns the manifest.
+func (r *Recorder) Close(ctx context.Context, runErr error) (Run, error) {
+	r.mu.Lock()
+	r.run.FinishedAt = time.Now().UTC()
+	if runErr != nil {
+		r.run.Error = runErr.Error()
+	}
+	run := r.run
+	run.Responses = append([]Response(nil), r.run.Responses...)
+	r.mu.Unlock()
+
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return run, err
+	}
+	if err := r.bucket.Put(ctx, runKey(run.ID), data); err != nil {
+		return run, fmt.Errorf("failed to write run manifest: %w", err)
+	}
+	return run, nil
+}
+
+// LoadRun reads the manifest of an archived run
+func LoadRun(ctx context.Context, bucket Bucket, runID string) (*Run, error) {
+	if err := checkRunID(runID); err != nil {
+		return nil, err
+	}
+
+	data, err := bucket.Get(ctx, runKey(runID))
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("run %s is not in the archive", runID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var run Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse the manifest of run %s: %w", runID, err)
+	}
+	return &run, nil
+}
+
+// Body reads an archived response body and checks it against its hash
+func Body(ctx context.Context, bucket Bucket, response Response) ([]byte, error) {
+	compressed, err := bucket.Get(ctx, objectKey(response.SHA256))
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("archived body %s is corrupt: %w", response.SHA256, err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("archived body %s is corrupt: %w", response.SHA256, err)
+	}
+	if sum := sha256.Sum256(body); hex.EncodeToString(sum[:]) != response.SHA256 {
+		return nil, fmt.Errorf("archived body %s does not match its hash", response.SHA256)
+	}
+	return body, nil
+}
+
+// checkRunID rejects IDs that would escape the runs directory
+func checkRunID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("invalid run ID %q", id)
+	}
+	return nil
+}
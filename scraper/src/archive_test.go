@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/archive"
+	ekalathiapi "github.com/pheever/cy-price-watchdog/scraper/src/ekalathi-api"
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+)
+
+func TestArchiveAndReprocess(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemory()
+	seedProduct(t, db, 10)
+	bucket := archive.NewDir(t.TempDir())
+
+	branches := branchesAPI(map[string][]ekalathiapi.RetailBranchResponse{
+		"1": {{ID: 100, Name: "A1", CompanyName: "Alpha", RetailerProductPrice: 1.2}},
+	})
+	s := newTestScraper(t, db, func(w http.ResponseWriter, r *http.Request) {
+		if ekalathiapi.Endpoint(r) == ekalathiapi.RegionsEndpoint {
+			json.NewEncoder(w).Encode([]ekalathiapi.RegionResponse{{ID: 1, Name: "Nicosia"}})
+			return
+		}
+		branches(w, r)
+	})
+	s.client.Transport = &archiveTransport{next: s.client.Transport, logger: s.logger}
+	s.archive = bucket
+	if err := s.Run(withRunID(ctx, "run-1"), RunOptions{Phases: []string{phasePrices}}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	run, err := archive.LoadRun(ctx, bucket, "run-1")
+	if err != nil {
+		t.Fatalf("the run was not archived: %v", err)
+	}
+	if len(run.Responses) != 2 || run.Failed != 0 {
+		t.Fatalf("archived %d responses with %d failures, want the regions and one branch page", len(run.Responses), run.Failed)
+	}
+
+	// Reprocessing must not reach the API, so give it one that always fails
+	offline := newTestScraper(t, db, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	offline.archive = bucket
+
+	if err := offline.Reprocess(ctx, "run-1", true); err != nil {
+		t.Fatalf("Reprocess(dry run) error = %v", err)
+	}
+	before := db.Prices()
+	if err := offline.Reprocess(ctx, "run-1", false); err != nil {
+		t.Fatalf("Reprocess() error = %v", err)
+	}
+	after := db.Prices()
+	if len(before) != 1 || len(after) != 1 {
+		t.Fatalf("stored %d prices, then %d after reprocessing, want 1 each", len(before), len(after))
+	}
+	if after[0].Price != 1.2 || !after[0].ScrapedAt.Equal(run.Responses[1].Time) {
+		t.Errorf("reprocessed price = %+v, want 1.2 at %v", after[0], run.Responses[1].Time)
+	}
+	if failures, _ := db.Failures(ctx); len(failures) != 0 {
+		t.Errorf("failures = %+v, want none", failures)
+	}
+
+	if err := offline.Reprocess(ctx, "run-2", false); err == nil {
+		t.Error("reprocessing a run that was never archived succeeded")
+	}
+	offline.archive = nil
+	if err := offline.Reprocess(ctx, "run-1", false); err != errArchiveURL {
+		t.Errorf("Reprocess() without an archive error = %v, want errArchiveURL", err)
+	}
+}
+
+// failingBucket fails to read the objects whose keys contain fail
+type failingBucket struct {
+	archive.Bucket
+	fail string
+}
+
+func (b failingBucket) Get(ctx context.Context, key string) ([]byte, error) {
+	if strings.Contains(key, b.fail) {
+		return nil, errors.New("bucket unavailable")
+	}
+	return b.Bucket.Get(ctx, key)
+}
+
+func TestReprocessFailedReplay(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemory()
+	productID := seedProduct(t, db, 10)
+	bucket := archive.NewDir(t.TempDir())
+
+	branches := branchesAPI(map[string][]ekalathiapi.RetailBranchResponse{
+		"1": {{ID: 100, Name: "A1", CompanyName: "Alpha", RetailerProductPrice: 1.2}},
+		"2": {{ID: 200, Name: "B1", CompanyName: "Beta", RetailerProductPrice: 1.4}},
+	})
+	s := newTestScraper(t, db, func(w http.ResponseWriter, r *http.Request) {
+		if ekalathiapi.Endpoint(r) == ekalathiapi.RegionsEndpoint {
+			json.NewEncoder(w).Encode([]ekalathiapi.RegionResponse{{ID: 1, Name: "Nicosia"}, {ID: 2, Name: "Limassol"}})
+			return
+		}
+		branches(w, r)
+	})
+	s.client.Transport = &archiveTransport{next: s.client.Transport, logger: s.logger}
+	s.archive = bucket
+	if err := s.Run(withRunID(ctx, "run-1"), RunOptions{Phases: []string{phasePrices}}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	run, err := archive.LoadRun(ctx, bucket, "run-1")
+	if err != nil || len(run.Responses) != 3 {
+		t.Fatalf("LoadRun() = %+v, %v, want the regions and two branch pages", run, err)
+	}
+
+	// Another run stored a price while run 1 was going
+	a1, _ := db.LookupID(ctx, store.KindStore, 100)
+	other := store.Price{ProductID: productID, StoreID: a1, Price: 1.3, ScrapedAt: run.Responses[1].Time, RunID: "run-0"}
+	if err := db.InsertPrice(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	before := db.Prices()
+
+	// The second branch page can't be read back, so the replay fails after
+	// deriving the first price
+	offline := newTestScraper(t, db, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	offline.archive = failingBucket{Bucket: bucket, fail: run.Responses[2].SHA256}
+	if err := offline.Reprocess(ctx, "run-1", false); err == nil {
+		t.Fatal("Reprocess() succeeded though a response could not be replayed")
+	}
+	if after := db.Prices(); !slices.Equal(after, before) {
+		t.Fatalf("prices after a failed replay = %+v, want them untouched: %+v", after, before)
+	}
+
+	offline.archive = bucket
+	if err := offline.Reprocess(ctx, "run-1", false); err != nil {
+		t.Fatalf("Reprocess() error = %v", err)
+	}
+	after := db.Prices()
+	if len(after) != 3 || !slices.Contains(after, other) {
+		t.Errorf("prices after reprocessing = %+v, want run 1's two and run 0's", after)
+	}
+	for _, p := range after {
+		if p.RunID != "run-1" && p.RunID != "run-0" {
+			t.Errorf("price %+v has run ID %q, want the run it came from", p, p.RunID)
+		}
+	}
+}
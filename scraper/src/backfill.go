@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	ekalathiapi "github.com/pheever/cy-price-watchdog/scraper/src/ekalathi-api"
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Sources recorded on "HistoricalPrice" rows
+const (
+	sourcePriceHistory  = "ekalathi_price_history"
+	sourcePreviousPrice = "ekalathi_previous_price"
+	sourceStartPrice    = "ekalathi_start_price"
+)
+
+// historyDateLayouts are the date formats seen in priceHistory entries
+var historyDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	time.DateOnly,
+	"02/01/2006",
+}
+
+// --- Backfill Methods ---
+
+func (s *Scraper) fetchProductHistory(ctx context.Context, productID int) (*ekalathiapi.ProductHistoryResponse, error) {
+	req, err := ekalathiapi.GetProduct(ekalathiapi.ProductRequest{ID: productID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create product request: %w", err)
+	}
+
+	req = s.updateHeaders(req.WithContext(ctx))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch product: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var result ekalathiapi.ProductHistoryResponse
+	if err := s.decodeResponse(ctx, ekalathiapi.ProductEndpoint, "product", resp.Body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse product: %w", err)
+	}
+
+	return &result, nil
+}
+
+// insertHistoricalPrice stores a price observation that was not scraped
+// directly from a store. A zero observedAt stores the price as the latest
+// undated one for the product and source.
+func (s *Scraper) insertHistoricalPrice(ctx context.Context, productID string, price float64, observedAt time.Time, source string) (bool, error) {
+	return s.db.InsertHistoricalPrice(ctx, store.HistoricalPrice{
+		ProductID:  productID,
+		Price:      price,
+		ObservedAt: observedAt,
+		Source:     source,
+	})
+}
+
+// productItem holds both external and internal product IDs for queue processing
+type productItem struct {
+	ExternalID int
+	InternalID string
+}
+
+// historicalObservation is a price for a product, flagged with its source.
+// ObservedAt is zero when eKalathi gives no date.
+type historicalObservation struct {
+	Price      float64
+	ObservedAt time.Time
+	Source     string
+}
+
+// backfillProducts pulls the price history eKalathi exposes for each product
+// and stores it in "HistoricalPrice". The start and previous prices carry no
+// date, so only their latest value is kept per product. Re-running the
+// backfill is idempotent.
+func (s *Scraper) backfillProducts(ctx context.Context, productMap map[int]string) error {
+	s.logger.Info("backfilling price history", "productCount", len(productMap))
+	ctx, span := startPhaseSpan(ctx, "backfill")
+	defer span.End()
+	s.progress.StartPhase("backfill", len(productMap))
+
+	inserted := 0
+
+	queue := make([]WorkItem[productItem], 0, len(productMap))
+	for extID, intID := range productMap {
+		queue = append(queue, WorkItem[productItem]{
+			Data: productItem{ExternalID: extID, InternalID: intID},
+		})
+	}
+
+	var failedProducts []int
+
+	// Process queue with deferred retries
+	for len(queue) > 0 {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		item := queue[0]
+		queue = queue[1:]
+
+		itemCtx, itemSpan := tracer.Start(ctx, "backfill product", trace.WithAttributes(
+			attrProductID.Int(item.Data.ExternalID),
+			attrAttempt.Int(item.Retries+1),
+		))
+		history, err := s.fetchProductHistory(itemCtx, item.Data.ExternalID)
+		if err != nil {
+			endSpan(itemSpan, err)
+			if item.Retries < s.retry.MaxRetries {
+				item.Retries++
+				queue = append(queue, item) // back of the line
+				s.logger.Warn("retrying product history fetch", "productID", item.Data.ExternalID, "attempt", item.Retries)
+				s.progress.Retry(item.Retries)
+			} else {
+				s.logger.Error("failed to fetch product history after retries", "productID", item.Data.ExternalID, "error", err)
+				failedProducts = append(failedProducts, item.Data.ExternalID)
+				s.progress.Fail(item.Retries)
+			}
+			continue
+		}
+
+		var observations []historicalObservation
+
+		for _, entry := range history.PriceHistory {
+			observedAt, err := parseHistoryDate(entry.Date)
+			if err != nil {
+				s.logger.Warn("skipping price history entry", "productID", item.Data.ExternalID, "error", err)
+				continue
+			}
+			observations = append(observations, historicalObservation{entry.Price, observedAt, sourcePriceHistory})
+		}
+		if history.PreviousPrice > 0 {
+			observations = append(observations, historicalObservation{history.PreviousPrice, time.Time{}, sourcePreviousPrice})
+		}
+		if history.StartPrice > 0 {
+			observations = append(observations, historicalObservation{history.StartPrice, time.Time{}, sourceStartPrice})
+		}
+
+		for _, obs := range observations {
+			ok, err := s.insertHistoricalPrice(itemCtx, item.Data.InternalID, obs.Price, obs.ObservedAt, obs.Source)
+			if err != nil {
+				s.logger.Error("error inserting historical price", "productID", item.Data.ExternalID, "source", obs.Source, "error", err)
+				continue
+			}
+			if ok {
+				inserted++
+			}
+		}
+		itemSpan.End()
+		s.progress.Done(item.Retries)
+
+		// Rate limiting to be respectful to the API
+		time.Sleep(s.scrape.ItemDelay)
+	}
+
+	if len(failedProducts) > 0 {
+		s.logger.Warn("some products failed after all retries", "count", len(failedProducts), "productIDs", failedProducts)
+		s.metrics.RecordCount("failed_items", len(failedProducts), map[string]string{"phase": "backfill"})
+	}
+
+	s.metrics.RecordCount("historical_prices", inserted, nil)
+	s.logger.Info("inserted historical price records", "count", inserted)
+	return nil
+}
+
+// Backfill refreshes categories and products, then stores the price history
+// eKalathi exposes for every product
+func (s *Scraper) Backfill(ctx context.Context) (err error) {
+	s, ctx = s.forRun(ctx)
+	s.logger.Info("starting backfill")
+	ctx, span := tracer.Start(ctx, "scraper.backfill")
+	defer func() { endSpan(span, err) }()
+	defer s.reportDrift()
+	s.progress.StartRun()
+	defer s.progress.FinishRun()
+
+	categoryMap, err := s.scrapeCategories(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to scrape categories: %w", err)
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	productMap, err := s.scrapeProducts(ctx, categoryMap, Scope{})
+	if err != nil {
+		return fmt.Errorf("failed to scrape products: %w", err)
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	startBackfill := time.Now()
+	if err := s.backfillProducts(ctx, productMap); err != nil {
+		return fmt.Errorf("failed to backfill prices: %w", err)
+	}
+	s.metrics.RecordDuration("backfill", time.Since(startBackfill), nil)
+
+	s.logger.Info("backfill completed successfully")
+	return nil
+}
+
+func parseHistoryDate(s string) (time.Time, error) {
+	for _, layout := range historyDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid price history date %q", s)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	ekalathiapi "github.com/pheever/cy-price-watchdog/scraper/src/ekalathi-api"
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+)
+
+func TestParseHistoryDate(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "2026-03-01T09:30:00Z", want: day.Add(9*time.Hour + 30*time.Minute)},
+		{in: "2026-03-01T09:30:00+02:00", want: day.Add(7*time.Hour + 30*time.Minute)},
+		{in: "2026-03-01T09:30:00", want: day.Add(9*time.Hour + 30*time.Minute)},
+		{in: "2026-03-01", want: day},
+		{in: "01/03/2026", want: day},
+		{in: "", wantErr: true},
+		{in: "March 1st", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseHistoryDate(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHistoryDate(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("parseHistoryDate(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackfillProductsTwice(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemory()
+	productID := seedProduct(t, db, 10)
+
+	product := ekalathiapi.ProductHistoryResponse{
+		Product: ekalathiapi.Product{ProductMasterId: 10, Name: "Milk", PreviousPrice: 1.5, StartPrice: 1.2},
+		PriceHistory: []ekalathiapi.PriceHistory{
+			{Date: "2026-03-01", Price: 1.3},
+			{Date: "2026-03-08", Price: 1.4},
+			{Date: "someday", Price: 1.45},
+		},
+	}
+	s := newTestScraper(t, db, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(product)
+	})
+
+	// Two dated entries plus the previous and start prices; the second run
+	// finds them all stored, and a changed previous price replaces the old one
+	for i, previous := range []float64{1.5, 1.5, 1.6} {
+		product.PreviousPrice = previous
+		if err := s.backfillProducts(ctx, map[int]string{10: productID}); err != nil {
+			t.Fatalf("backfill %d: %v", i+1, err)
+		}
+		stats, err := db.Stats(ctx, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.HistoricalPrices != 4 {
+			t.Errorf("after backfill %d: %d historical prices, want 4", i+1, stats.HistoricalPrices)
+		}
+	}
+}
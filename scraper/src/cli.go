@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/config"
+	"github.com/pheever/cy-price-watchdog/scraper/src/metrics"
+)
+
+// command is a scraper subcommand. Running the binary without a command is
+// the same as "run".
+type command struct {
+	Name        string
+	Usage       string
+	Description string
+	Run         func(cfg *config.Config, logger *slog.Logger, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"run", "run [--phase=prices] [--category=<id>] [--product=<id>] [--region=<id>] [--dry-run]", "Scrape eKalathi and store the results (default)", runCommand},
+		{"serve", "serve (--cron=<expr> | --interval=<duration>) [--run-now] [run flags]", "Stay running and scrape on a schedule", serveCommand},
+		{"backfill", "backfill", "Store the price history eKalathi exposes for every product", backfillCommand},
+		{"retry-failed", "retry-failed", "Re-scrape the items that failed in earlier runs", retryFailedCommand},
+		{"reprocess", "reprocess [--dry-run] <run-id>", "Rebuild the rows of an archived run from its raw responses", reprocessCommand},
+		{"regions", "regions", "List eKalathi regions", regionsCommand},
+		{"categories", "categories", "List eKalathi categories and subcategories", categoriesCommand},
+		{"stats", "stats", "Show database totals and the latest scrape", statsCommand},
+		{"export", "export [--out=<dir>] [--format=csv,parquet] [--from=<day>] [--to=<day>] [--category=<id>] [--chain=<name>] [--incremental]", "Export prices as CSV and Parquet files partitioned by day", exportCommand},
+		{"import-cpi", "import-cpi [--source=cystat] [--unit=I15] [--geo=CY] [--mapping=file.csv] [file ...]", "Import official CPI series", importCPICommand},
+		{"help", "help", "Show this help", helpCommand},
+	}
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].Name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: scraper [--config=<file>] [--db=<url>] <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.Usage, cmd.Description)
+	}
+	tw.Flush()
+}
+
+// newFlagSet returns a flag set whose usage prints the command's synopsis
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		if cmd := findCommand(name); cmd != nil {
+			fmt.Fprintf(fs.Output(), "usage: scraper %s\n\n%s\n", cmd.Usage, cmd.Description)
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseNoArgs parses a command that takes no flags or arguments
+func parseNoArgs(name string, args []string) error {
+	fs := newFlagSet(name)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("%s takes no arguments, got %v", name, fs.Args())
+	}
+	return nil
+}
+
+// signalContext is cancelled on SIGTERM or SIGINT, for commands that don't
+// go through withScraper
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+}
+
+// --- Flag Types ---
+
+// intList is a repeatable, comma-separated list of IDs
+type intList []int
+
+func (l *intList) String() string {
+	parts := make([]string, len(*l))
+	for i, v := range *l {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *intList) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return fmt.Errorf("invalid ID %q", part)
+		}
+		*l = append(*l, id)
+	}
+	return nil
+}
+
+// stringList is a repeatable, comma-separated list of names
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*l = append(*l, part)
+		}
+	}
+	return nil
+}
+
+// orDefault returns list, or fallback when list is empty
+func orDefault[T any](list, fallback []T) []T {
+	if len(list) == 0 {
+		return fallback
+	}
+	return list
+}
+
+// --- Commands ---
+
+// addRunFlags registers the phase and scope flags shared by run and serve.
+// The returned function builds the RunOptions once the flags are parsed;
+// the configured scope fills in whatever the flags leave empty.
+func addRunFlags(fs *flag.FlagSet, defaults config.Scope) func() (RunOptions, error) {
+	var phases stringList
+	var scope struct {
+		IncludeCategories, ExcludeCategories intList
+		IncludeProducts, ExcludeProducts     intList
+		IncludeRegions, ExcludeRegions       intList
+	}
+
+	fs.Var(&phases, "phase", fmt.Sprintf("phases to run, comma-separated or repeated (%s)", strings.Join(allPhases, ", ")))
+	fs.Var(&scope.IncludeCategories, "category", "only scrape these eKalathi category IDs (and their subcategories)")
+	fs.Var(&scope.ExcludeCategories, "exclude-category", "skip these eKalathi category IDs (and their subcategories)")
+	fs.Var(&scope.IncludeProducts, "product", "only scrape these eKalathi product IDs")
+	fs.Var(&scope.ExcludeProducts, "exclude-product", "skip these eKalathi product IDs")
+	fs.Var(&scope.IncludeRegions, "region", "only scrape prices in these eKalathi region IDs")
+	fs.Var(&scope.ExcludeRegions, "exclude-region", "skip prices in these eKalathi region IDs")
+	dryRun := fs.Bool("dry-run", false, "fetch everything but write nothing to the database; log a summary of what would change")
+
+	return func() (RunOptions, error) {
+		if fs.NArg() > 0 {
+			return RunOptions{}, fmt.Errorf("%s takes no arguments, got %v", fs.Name(), fs.Args())
+		}
+
+		opts := RunOptions{
+			Phases: orDefault(phases, defaults.Phases),
+			Scope: Scope{
+				IncludeCategories: orDefault(scope.IncludeCategories, defaults.IncludeCategories),
+				ExcludeCategories: orDefault(scope.ExcludeCategories, defaults.ExcludeCategories),
+				IncludeProducts:   orDefault(scope.IncludeProducts, defaults.IncludeProducts),
+				ExcludeProducts:   orDefault(scope.ExcludeProducts, defaults.ExcludeProducts),
+				IncludeRegions:    orDefault(scope.IncludeRegions, defaults.IncludeRegions),
+				ExcludeRegions:    orDefault(scope.ExcludeRegions, defaults.ExcludeRegions),
+			},
+			DryRun: *dryRun,
+		}
+		return opts, opts.Validate()
+	}
+}
+
+func runCommand(cfg *config.Config, logger *slog.Logger, args []string) error {
+	fs := newFlagSet("run")
+	runOptions := addRunFlags(fs, cfg.Scope)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts, err := runOptions()
+	if err != nil {
+		return err
+	}
+
+	return withScraper(cfg, logger, func(ctx context.Context, scraper *Scraper, mux *http.ServeMux) error {
+		return recordRunResult(ctx, scraper.metrics, logger, scraper.Run(ctx, opts))
+	})
+}
+
+func serveCommand(cfg *config.Config, logger *slog.Logger, args []string) error {
+	fs := newFlagSet("serve")
+	cronExpr := fs.String("cron", "", `cron expression for scheduled runs, e.g. "0 */6 * * *"`)
+	interval := fs.Duration("interval", 0, "fixed interval between the end of a run and the start of the next, e.g. 6h")
+	runNow := fs.Bool("run-now", false, "start the first run immediately instead of at the first scheduled time")
+	runOptions := addRunFlags(fs, cfg.Scope)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts, err := runOptions()
+	if err != nil {
+		return err
+	}
+
+	sched, err := parseSchedule(*cronExpr, *interval)
+	if err != nil {
+		return err
+	}
+
+	return withScraper(cfg, logger, func(ctx context.Context, scraper *Scraper, mux *http.ServeMux) error {
+		manager := NewRunManager(ctx, logger, func(ctx context.Context, opts RunOptions) error {
+			err := recordRunResult(ctx, scraper.metrics, logger, scraper.Run(ctx, opts))
+			if flushErr := scraper.metrics.Flush(); flushErr != nil {
+				logger.Error("failed to flush metrics", "error", flushErr)
+			}
+
+			return err
+		})
+		scheduler := NewScheduler(sched, logger, func(ctx context.Context) error {
+			return manager.Run(ctx, opts, "schedule")
+		})
+		mux.Handle("/schedule", scheduler)
+
+		if token := cfg.Server.AdminToken; token != "" {
+			registerAdminRoutes(mux, manager, token)
+		} else {
+			logger.Info("ADMIN_TOKEN not set, admin API disabled")
+		}
+
+		logger.Info("serving scheduled runs", "cron", *cronExpr, "interval", interval.String())
+		scheduler.Start(ctx, *runNow)
+		return nil
+	})
+}
+
+func backfillCommand(cfg *config.Config, logger *slog.Logger, args []string) error {
+	if err := parseNoArgs("backfill", args); err != nil {
+		return err
+	}
+
+	return withScraper(cfg, logger, func(ctx context.Context, scraper *Scraper, mux *http.ServeMux) error {
+		return recordRunResult(ctx, scraper.metrics, logger, scraper.Backfill(ctx))
+	})
+}
+
+func retryFailedCommand(cfg *config.Config, logger *slog.Logger, args []string) error {
+	if err := parseNoArgs("retry-failed", args); err != nil {
+		return err
+	}
+
+	return withScraper(cfg, logger, func(ctx context.Context, scraper *Scraper, mux *http.ServeMux) error {
+		return recordRunResult(ctx, scraper.metrics, logger, scraper.RetryFailed(ctx))
+	})
+}
+
+func reprocessCommand(cfg *config.Config, logger *slog.Logger, args []string) error {
+	fs := newFlagSet("reprocess")
+	dryRun := fs.Bool("dry-run", false, "replay the run but write nothing to the database; log a summary of what would change")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("reprocess takes one run ID, got %d arguments", fs.NArg())
+	}
+	if cfg.Archive.URL == "" {
+		return errArchiveURL
+	}
+
+	return withScraper(cfg, logger, func(ctx context.Context, scraper *Scraper, mux *http.ServeMux) error {
+		return recordRunResult(ctx, scraper.metrics, logger, scraper.Reprocess(ctx, fs.Arg(0), *dryRun))
+	})
+}
+
+func regionsCommand(cfg *config.Config, logger *slog.Logger, args []string) error {
+	if err := parseNoArgs("regions", args); err != nil {
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	scraper := &Scraper{client: newHTTPClient(cfg.HTTP), logger: logger}
+	regions, err := scraper.fetchRegions(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME")
+	for _, region := range regions {
+		fmt.Fprintf(tw, "%d\t%s\n", region.ID, region.Name)
+	}
+	return tw.Flush()
+}
+
+func categoriesCommand(cfg *config.Config, logger *slog.Logger, args []string) error {
+	if err := parseNoArgs("categories", args); err != nil {
+		return err
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	scraper := &Scraper{client: newHTTPClient(cfg.HTTP), logger: logger}
+	categories, err := scraper.fetchCategories(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCODE\tNAME\tNAME (EN)")
+	for _, cat := range categories {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", cat.ID, cat.Code, cat.Name, cat.NameEnglish)
+		for _, subcat := range cat.ProductCategoryResponses {
+			fmt.Fprintf(tw, "  %d\t%s\t  %s\t  %s\n", subcat.ID, subcat.Code, subcat.Name, subcat.NameEnglish)
+		}
+	}
+	return tw.Flush()
+}
+
+func statsCommand(cfg *config.Config, logger *slog.Logger, args []string) error {
+	if err := parseNoArgs("stats", args); err != nil {
+		return err
+	}
+
+	if cfg.Database.URL == "" {
+		return errDatabaseURL
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	scraper, err := NewScraper(cfg, metrics.NewWithOptions(metrics.Options{}), logger)
+	if err != nil {
+		return err
+	}
+	defer scraper.Close()
+
+	stats, err := scraper.db.Stats(ctx, time.Now().UTC().Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+
+	latest := "never"
+	if stats.LatestScrape != nil {
+		latest = stats.LatestScrape.Format(time.RFC3339)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "categories\t%d\n", stats.Categories)
+	fmt.Fprintf(tw, "products\t%d\n", stats.Products)
+	fmt.Fprintf(tw, "stores\t%d\n", stats.Stores)
+	fmt.Fprintf(tw, "prices\t%d\n", stats.Prices)
+	fmt.Fprintf(tw, "prices (last 24h)\t%d\n", stats.RecentPrices)
+	fmt.Fprintf(tw, "historical prices\t%d\n", stats.HistoricalPrices)
+	fmt.Fprintf(tw, "pending failures\t%d\n", stats.PendingFailures)
+	fmt.Fprintf(tw, "latest scrape\t%s\n", latest)
+	return tw.Flush()
+}
+
+func importCPICommand(cfg *config.Config, logger *slog.Logger, args []string) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	return runImportCPI(ctx, cfg.Database.URL, logger, args)
+}
+
+func exportCommand(cfg *config.Config, logger *slog.Logger, args []string) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	return runExport(ctx, cfg.Database.URL, logger, args)
+}
+
+func helpCommand(cfg *config.Config, logger *slog.Logger, args []string) error {
+	printUsage(os.Stdout)
+	return nil
+}
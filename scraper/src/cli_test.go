@@ -0,0 +1,77 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/config"
+)
+
+func TestSplitGlobalFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		env      string
+		want     globalFlags
+		wantArgs []string
+	}{
+		{"separate value", []string{"--config", "a.yaml", "run"}, "", globalFlags{ConfigPath: "a.yaml"}, []string{"run"}},
+		{"equals", []string{"--config=a.toml", "serve", "--run-now"}, "", globalFlags{ConfigPath: "a.toml"}, []string{"serve", "--run-now"}},
+		{"single dash", []string{"-config=a.yaml"}, "", globalFlags{ConfigPath: "a.yaml"}, []string{}},
+		{"from environment", []string{"run"}, "env.yaml", globalFlags{ConfigPath: "env.yaml"}, []string{"run"}},
+		{"flag wins over environment", []string{"--config", "a.yaml"}, "env.yaml", globalFlags{ConfigPath: "a.yaml"}, []string{}},
+		{"only leading", []string{"run", "--config", "a.yaml"}, "", globalFlags{}, []string{"run", "--config", "a.yaml"}},
+		{"database", []string{"--db", "sqlite:///tmp/prices.db", "run"}, "", globalFlags{DatabaseURL: "sqlite:///tmp/prices.db"}, []string{"run"}},
+		{"both in any order", []string{"--db=memory://", "-config", "a.yaml", "stats"}, "", globalFlags{ConfigPath: "a.yaml", DatabaseURL: "memory://"}, []string{"stats"}},
+		{"command flags stay", []string{"--db=memory://", "--phase=prices"}, "", globalFlags{DatabaseURL: "memory://"}, []string{"--phase=prices"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SCRAPER_CONFIG", tt.env)
+			flags, args := splitGlobalFlags(tt.args)
+			if flags != tt.want || !slices.Equal(args, tt.wantArgs) {
+				t.Errorf("splitGlobalFlags(%v) = %+v, %v, want %+v, %v", tt.args, flags, args, tt.want, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestAddRunFlagsDefaults(t *testing.T) {
+	defaults := config.Scope{
+		Phases:          []string{phasePrices},
+		IncludeRegions:  []int{1},
+		ExcludeProducts: []int{7},
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantPhases  []string
+		wantRegions []int
+	}{
+		{"config scope without flags", nil, []string{phasePrices}, []int{1}},
+		{"flags replace the configured list", []string{"--region=2,3"}, []string{phasePrices}, []int{2, 3}},
+		{"phase flag", []string{"--phase=products"}, []string{phaseProducts}, []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := newFlagSet("run")
+			runOptions := addRunFlags(fs, defaults)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			opts, err := runOptions()
+			if err != nil {
+				t.Fatalf("runOptions() error = %v", err)
+			}
+			if !slices.Equal(opts.Phases, tt.wantPhases) || !slices.Equal(opts.Scope.IncludeRegions, tt.wantRegions) {
+				t.Errorf("options = %+v, want phases %v and regions %v", opts, tt.wantPhases, tt.wantRegions)
+			}
+			if !slices.Equal(opts.Scope.ExcludeProducts, []int{7}) {
+				t.Errorf("exclude products = %v, want the configured [7]", opts.Scope.ExcludeProducts)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+// Package config loads the scraper's settings from an optional YAML or TOML
+// file and from environment variables, which take precedence, and validates
+// them before anything starts.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pheever/cy-price-watchdog/scraper/src/archive"
+	"github.com/pheever/cy-price-watchdog/scraper/src/metrics"
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting of the scraper
+type Config struct {
+	Database Database `yaml:"database" toml:"database"`
+	HTTP     HTTP     `yaml:"http" toml:"http"`
+	Scrape   Scrape   `yaml:"scrape" toml:"scrape"`
+	Retry    Retry    `yaml:"retry" toml:"retry"`
+	Scope    Scope    `yaml:"scope" toml:"scope"`
+	Metrics  Metrics  `yaml:"metrics" toml:"metrics"`
+	Server   Server   `yaml:"server" toml:"server"`
+	Log      Log      `yaml:"log" toml:"log"`
+	Archive  Archive  `yaml:"archive" toml:"archive"`
+}
+
+// Database configures the store the scraper writes to, see store.Open
+type Database struct {
+	URL string `yaml:"url" toml:"url"`
+}
+
+// HTTP configures the client used for eKalathi requests
+type HTTP struct {
+	Timeout               time.Duration `yaml:"timeout" toml:"timeout"`
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout" toml:"response_header_timeout"`
+	IdleConnTimeout       time.Duration `yaml:"idle_conn_timeout" toml:"idle_conn_timeout"`
+	MaxIdleConns          int           `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	MaxIdleConnsPerHost   int           `yaml:"max_idle_conns_per_host" toml:"max_idle_conns_per_host"`
+}
+
+// Scrape configures paging, parallelism and the pauses that keep the load on
+// eKalathi reasonable
+type Scrape struct {
+	ProductPageSize int           `yaml:"product_page_size" toml:"product_page_size"`
+	BranchPageSize  int           `yaml:"branch_page_size" toml:"branch_page_size"`
+	Concurrency     int           `yaml:"concurrency" toml:"concurrency"`
+	PageDelay       time.Duration `yaml:"page_delay" toml:"page_delay"`
+	ItemDelay       time.Duration `yaml:"item_delay" toml:"item_delay"`
+	SchemaDrift     string        `yaml:"schema_drift" toml:"schema_drift"`
+}
+
+// Retry configures how often a failed item is tried again within a run
+type Retry struct {
+	MaxRetries int `yaml:"max_retries" toml:"max_retries"`
+}
+
+// Scope is the default phases and scope of run and serve, used when no
+// flags are given. IDs are eKalathi IDs.
+type Scope struct {
+	Phases            []string `yaml:"phases" toml:"phases"`
+	IncludeCategories []int    `yaml:"include_categories" toml:"include_categories"`
+	ExcludeCategories []int    `yaml:"exclude_categories" toml:"exclude_categories"`
+	IncludeProducts   []int    `yaml:"include_products" toml:"include_products"`
+	ExcludeProducts   []int    `yaml:"exclude_products" toml:"exclude_products"`
+	IncludeRegions    []int    `yaml:"include_regions" toml:"include_regions"`
+	ExcludeRegions    []int    `yaml:"exclude_regions" toml:"exclude_regions"`
+}
+
+// Metrics configures the metrics sink, see metrics.NewSink
+type Metrics struct {
+	URL           string        `yaml:"url" toml:"url"`
+	BufferSize    int           `yaml:"buffer_size" toml:"buffer_size"`
+	BatchSize     int           `yaml:"batch_size" toml:"batch_size"`
+	FlushInterval time.Duration `yaml:"flush_interval" toml:"flush_interval"`
+}
+
+// Server configures the health and admin server
+type Server struct {
+	Port         int           `yaml:"port" toml:"port"`
+	AdminToken   string        `yaml:"admin_token" toml:"admin_token"`
+	StallTimeout time.Duration `yaml:"stall_timeout" toml:"stall_timeout"`
+}
+
+// Log configures the process logger
+type Log struct {
+	Level  string `yaml:"level" toml:"level"`
+	Format string `yaml:"format" toml:"format"`
+}
+
+// Archive configures where raw eKalathi responses are kept, see
+// archive.Open. An empty URL disables archiving.
+type Archive struct {
+	URL               string `yaml:"url" toml:"url"`
+	S3Endpoint        string `yaml:"s3_endpoint" toml:"s3_endpoint"`
+	S3Region          string `yaml:"s3_region" toml:"s3_region"`
+	S3AccessKeyID     string `yaml:"s3_access_key_id" toml:"s3_access_key_id"`
+	S3SecretAccessKey string `yaml:"s3_secret_access_key" toml:"s3_secret_access_key"`
+}
+
+// Log formats
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+// What to do with a response whose required fields are absent or zero, see
+// Scrape.SchemaDrift
+const (
+	SchemaDriftFail = "fail" // treat the response as a failed request
+	SchemaDriftWarn = "warn" // use it anyway and report an anomaly
+)
+
+// Limits enforced by Validate
+const (
+	maxPageSize    = 1000
+	maxConcurrency = 32
+	maxRetries     = 20
+)
+
+// Default returns the settings used when neither the file nor the
+// environment sets a value
+func Default() Config {
+	return Config{
+		HTTP: HTTP{
+			Timeout:               120 * time.Second,
+			ResponseHeaderTimeout: 120 * time.Second,
+			IdleConnTimeout:       60 * time.Second,
+			MaxIdleConns:          10,
+			MaxIdleConnsPerHost:   5,
+		},
+		Scrape: Scrape{
+			ProductPageSize: 20,
+			BranchPageSize:  10,
+			Concurrency:     1,
+			PageDelay:       100 * time.Millisecond,
+			ItemDelay:       200 * time.Millisecond,
+			SchemaDrift:     SchemaDriftFail,
+		},
+		Retry: Retry{MaxRetries: 3},
+		Metrics: Metrics{
+			BufferSize:    metrics.DefaultBufferSize,
+			BatchSize:     metrics.DefaultBatchSize,
+			FlushInterval: metrics.DefaultFlushInterval,
+		},
+		Server: Server{
+			Port:         8080,
+			StallTimeout: 15 * time.Minute,
+		},
+		Log:     Log{Level: "info", Format: LogFormatJSON},
+		Archive: Archive{S3Region: "us-east-1"},
+	}
+}
+
+// Load reads the defaults, then the file at path if it is not empty, then
+// the environment, and validates the result. The file's format follows its
+// extension: .yaml, .yml or .toml.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyEnv(os.Getenv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// loadFile decodes the file at path over c. Unknown keys are errors, so a
+// misspelt setting doesn't go unnoticed.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("failed to parse %s: unknown setting %q", path, undecoded[0].String())
+		}
+	default:
+		return fmt.Errorf("unsupported config file %q: use .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// Validate checks every setting and reports all problems at once
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.HTTP.Timeout > 0, "http.timeout must be positive")
+	check(c.HTTP.ResponseHeaderTimeout > 0, "http.response_header_timeout must be positive")
+	check(c.HTTP.IdleConnTimeout > 0, "http.idle_conn_timeout must be positive")
+	check(c.HTTP.MaxIdleConns > 0, "http.max_idle_conns must be positive")
+	check(c.HTTP.MaxIdleConnsPerHost > 0, "http.max_idle_conns_per_host must be positive")
+
+	check(c.Scrape.ProductPageSize > 0 && c.Scrape.ProductPageSize <= maxPageSize, "scrape.product_page_size must be between 1 and %d", maxPageSize)
+	check(c.Scrape.BranchPageSize > 0 && c.Scrape.BranchPageSize <= maxPageSize, "scrape.branch_page_size must be between 1 and %d", maxPageSize)
+	check(c.Scrape.Concurrency > 0 && c.Scrape.Concurrency <= maxConcurrency, "scrape.concurrency must be between 1 and %d", maxConcurrency)
+	check(c.Scrape.PageDelay >= 0, "scrape.page_delay must not be negative")
+	check(c.Scrape.SchemaDrift == SchemaDriftFail || c.Scrape.SchemaDrift == SchemaDriftWarn, "scrape.schema_drift %q must be %s or %s", c.Scrape.SchemaDrift, SchemaDriftFail, SchemaDriftWarn)
+	check(c.Scrape.ItemDelay >= 0, "scrape.item_delay must not be negative")
+
+	check(c.Retry.MaxRetries >= 0 && c.Retry.MaxRetries <= maxRetries, "retry.max_retries must be between 0 and %d", maxRetries)
+
+	for _, list := range []struct {
+		name string
+		ids  []int
+	}{
+		{"include_categories", c.Scope.IncludeCategories},
+		{"exclude_categories", c.Scope.ExcludeCategories},
+		{"include_products", c.Scope.IncludeProducts},
+		{"exclude_products", c.Scope.ExcludeProducts},
+		{"include_regions", c.Scope.IncludeRegions},
+		{"exclude_regions", c.Scope.ExcludeRegions},
+	} {
+		for _, id := range list.ids {
+			check(id > 0, "scope.%s: invalid ID %d", list.name, id)
+		}
+	}
+
+	if c.Database.URL != "" {
+		if err := store.CheckURL(c.Database.URL); err != nil {
+			errs = append(errs, fmt.Errorf("database.url: %w", err))
+		}
+	}
+
+	if c.Metrics.URL != "" {
+		if err := metrics.CheckURL(c.Metrics.URL); err != nil {
+			errs = append(errs, fmt.Errorf("metrics.url: %w", err))
+		}
+	}
+	check(c.Metrics.BufferSize > 0, "metrics.buffer_size must be positive")
+	check(c.Metrics.BatchSize > 0, "metrics.batch_size must be positive")
+	check(c.Metrics.FlushInterval > 0, "metrics.flush_interval must be positive")
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port must be between 1 and 65535")
+	check(c.Server.StallTimeout > 0, "server.stall_timeout must be positive")
+
+	if c.Archive.URL != "" {
+		if err := archive.CheckURL(c.Archive.URL); err != nil {
+			errs = append(errs, fmt.Errorf("archive.url: %w", err))
+		}
+	}
+	if c.Archive.S3Endpoint != "" {
+		u, err := url.Parse(c.Archive.S3Endpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "archive.s3_endpoint must be an http or https URL")
+	}
+
+	if _, err := ParseLogLevel(c.Log.Level); err != nil {
+		errs = append(errs, err)
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "", LogFormatJSON, LogFormatText:
+	default:
+		errs = append(errs, fmt.Errorf("log.format %q must be %s or %s", c.Log.Format, LogFormatJSON, LogFormatText))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// ParseLogLevel parses debug, info, warn (or warning) and error,
+// case-insensitively. An empty level means info.
+func ParseLogLevel(level string) (slog.Level, error) {
+	level = strings.TrimSpace(level)
+	switch strings.ToLower(level) {
+	case "":
+		return slog.LevelInfo, nil
+	case "warning":
+		return slog.LevelWarn, nil
+	}
+
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("log.level %q must be debug, info, warn or error", level)
+	}
+	return lvl, nil
+}
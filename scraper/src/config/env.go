@@ -0,0 +1,142 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envVar overrides one setting from an environment variable
+type envVar struct {
+	name    string
+	setting string
+	set     func(value string) error
+}
+
+// envVars lists the environment variables that override c, with the setting
+// each one replaces. Empty variables are ignored.
+func (c *Config) envVars() []envVar {
+	return []envVar{
+		{"DATABASE_URL", "database.url", stringVar(&c.Database.URL)},
+
+		{"HTTP_TIMEOUT", "http.timeout", durationVar(&c.HTTP.Timeout)},
+		{"HTTP_RESPONSE_HEADER_TIMEOUT", "http.response_header_timeout", durationVar(&c.HTTP.ResponseHeaderTimeout)},
+		{"HTTP_IDLE_CONN_TIMEOUT", "http.idle_conn_timeout", durationVar(&c.HTTP.IdleConnTimeout)},
+		{"HTTP_MAX_IDLE_CONNS", "http.max_idle_conns", intVar(&c.HTTP.MaxIdleConns)},
+		{"HTTP_MAX_IDLE_CONNS_PER_HOST", "http.max_idle_conns_per_host", intVar(&c.HTTP.MaxIdleConnsPerHost)},
+
+		{"SCRAPE_PRODUCT_PAGE_SIZE", "scrape.product_page_size", intVar(&c.Scrape.ProductPageSize)},
+		{"SCRAPE_BRANCH_PAGE_SIZE", "scrape.branch_page_size", intVar(&c.Scrape.BranchPageSize)},
+		{"SCRAPE_CONCURRENCY", "scrape.concurrency", intVar(&c.Scrape.Concurrency)},
+		{"SCRAPE_PAGE_DELAY", "scrape.page_delay", durationVar(&c.Scrape.PageDelay)},
+		{"SCRAPE_ITEM_DELAY", "scrape.item_delay", durationVar(&c.Scrape.ItemDelay)},
+		{"SCRAPE_SCHEMA_DRIFT", "scrape.schema_drift", stringVar(&c.Scrape.SchemaDrift)},
+
+		{"RETRY_MAX_RETRIES", "retry.max_retries", intVar(&c.Retry.MaxRetries)},
+
+		{"SCOPE_PHASES", "scope.phases", stringsVar(&c.Scope.Phases)},
+		{"SCOPE_INCLUDE_CATEGORIES", "scope.include_categories", intsVar(&c.Scope.IncludeCategories)},
+		{"SCOPE_EXCLUDE_CATEGORIES", "scope.exclude_categories", intsVar(&c.Scope.ExcludeCategories)},
+		{"SCOPE_INCLUDE_PRODUCTS", "scope.include_products", intsVar(&c.Scope.IncludeProducts)},
+		{"SCOPE_EXCLUDE_PRODUCTS", "scope.exclude_products", intsVar(&c.Scope.ExcludeProducts)},
+		{"SCOPE_INCLUDE_REGIONS", "scope.include_regions", intsVar(&c.Scope.IncludeRegions)},
+		{"SCOPE_EXCLUDE_REGIONS", "scope.exclude_regions", intsVar(&c.Scope.ExcludeRegions)},
+
+		{"METRICS_URL", "metrics.url", stringVar(&c.Metrics.URL)},
+		{"METRICS_BUFFER_SIZE", "metrics.buffer_size", intVar(&c.Metrics.BufferSize)},
+		{"METRICS_BATCH_SIZE", "metrics.batch_size", intVar(&c.Metrics.BatchSize)},
+		{"METRICS_FLUSH_INTERVAL", "metrics.flush_interval", durationVar(&c.Metrics.FlushInterval)},
+
+		{"PORT", "server.port", intVar(&c.Server.Port)},
+		{"ADMIN_TOKEN", "server.admin_token", stringVar(&c.Server.AdminToken)},
+		{"HEALTH_STALL_TIMEOUT", "server.stall_timeout", durationVar(&c.Server.StallTimeout)},
+
+		{"LOG_LEVEL", "log.level", stringVar(&c.Log.Level)},
+		{"LOG_FORMAT", "log.format", stringVar(&c.Log.Format)},
+
+		{"ARCHIVE_URL", "archive.url", stringVar(&c.Archive.URL)},
+		{"ARCHIVE_S3_ENDPOINT", "archive.s3_endpoint", stringVar(&c.Archive.S3Endpoint)},
+		{"AWS_REGION", "archive.s3_region", stringVar(&c.Archive.S3Region)},
+		{"AWS_ACCESS_KEY_ID", "archive.s3_access_key_id", stringVar(&c.Archive.S3AccessKeyID)},
+		{"AWS_SECRET_ACCESS_KEY", "archive.s3_secret_access_key", stringVar(&c.Archive.S3SecretAccessKey)},
+	}
+}
+
+// applyEnv overrides c with the environment variables getenv returns
+func (c *Config) applyEnv(getenv func(string) string) error {
+	var errs []error
+	for _, v := range c.envVars() {
+		value := strings.TrimSpace(getenv(v.name))
+		if value == "" {
+			continue
+		}
+		if err := v.set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", v.name, v.setting, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid environment: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func stringVar(p *string) func(string) error {
+	return func(value string) error {
+		*p = value
+		return nil
+	}
+}
+
+func intVar(p *int) func(string) error {
+	return func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		*p = n
+		return nil
+	}
+}
+
+func durationVar(p *time.Duration) func(string) error {
+	return func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		*p = d
+		return nil
+	}
+}
+
+// stringsVar parses a comma-separated list
+func stringsVar(p *[]string) func(string) error {
+	return func(value string) error {
+		var list []string
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				list = append(list, part)
+			}
+		}
+		*p = list
+		return nil
+	}
+}
+
+// intsVar parses a comma-separated list of IDs
+func intsVar(p *[]int) func(string) error {
+	return func(value string) error {
+		var list []int
+		for _, part := range strings.Split(value, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return fmt.Errorf("invalid ID %q", part)
+			}
+			list = append(list, id)
+		}
+		*p = list
+		return nil
+	}
+}
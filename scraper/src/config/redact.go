@@ -0,0 +1,74 @@
+package config
+
+import (
+	"log/slog"
+	"net/url"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets in printed configuration
+const redacted = "REDACTED"
+
+// dsnPassword matches the password in a key/value connection string such as
+// "host=db user=scraper password=secret"
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// Redacted returns a copy of c that is safe to print: passwords in URLs and
+// connection strings, the admin token and the S3 secret key are replaced
+func (c Config) Redacted() Config {
+	c.Database.URL = redactURL(c.Database.URL)
+	c.Metrics.URL = redactURL(c.Metrics.URL)
+	if c.Server.AdminToken != "" {
+		c.Server.AdminToken = redacted
+	}
+	if c.Archive.S3SecretAccessKey != "" {
+		c.Archive.S3SecretAccessKey = redacted
+	}
+	return c
+}
+
+// String renders the redacted configuration as YAML
+func (c Config) String() string {
+	out, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
+
+// LogValue logs the redacted configuration as nested groups named after the
+// file's keys, so secrets never reach the logs
+func (c Config) LogValue() slog.Value {
+	var tree map[string]any
+	if err := yaml.Unmarshal([]byte(c.String()), &tree); err != nil {
+		return slog.StringValue(err.Error())
+	}
+	return slog.AnyValue(tree)
+}
+
+// redactURL hides the password of a URL, along with password query
+// parameters and key/value connection string passwords
+func redactURL(raw string) string {
+	if raw == "" {
+		return raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return dsnPassword.ReplaceAllString(raw, "${1}"+redacted)
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	query := u.Query()
+	for key := range query {
+		if key == "password" || key == "token" {
+			query.Set(key, redacted)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
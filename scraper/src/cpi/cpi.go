@@ -0,0 +1,412 @@
+// Package cpi parses official consumer price index series as published by
+// the Cyprus statistics service (CYSTAT) and Eurostat, in SDMX-CSV or
+// SDMX-JSON format.
+package cpi
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Observation is a single monthly index value for a COICOP group
+type Observation struct {
+	Coicop string
+	Month  time.Time // first day of the month, UTC
+	Value  float64
+}
+
+// Filter keeps the observations whose dimensions have the given values, e.g.
+// {"unit": "I15", "geo": "CY"}. Dimension IDs, CSV column names and values
+// compare case-insensitively.
+type Filter map[string]string
+
+// seriesKey identifies an observation; a file holding two for the same key
+// mixes several series
+type seriesKey struct {
+	coicop string
+	month  time.Time
+}
+
+// Column name aliases accepted in CSV headers (compared case-insensitively)
+var (
+	coicopColumns = []string{"coicop", "coicop_code", "coicop2018"}
+	periodColumns = []string{"time_period", "month", "period", "time"}
+	valueColumns  = []string{"obs_value", "value", "index"}
+)
+
+// Parse picks the parser based on the file extension (.csv or .json)
+func Parse(filename string, r io.Reader, filter Filter) ([]Observation, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return ParseCSV(r, filter)
+	case ".json":
+		return ParseSDMXJSON(r, filter)
+	default:
+		return nil, fmt.Errorf("unsupported CPI file format: %s", filename)
+	}
+}
+
+// ParseCSV reads an SDMX-CSV (or plain tabular) file with COICOP, period
+// and value columns. Rows with a missing value (empty or ":") are skipped,
+// as are rows the filter leaves out. A file with two values for the same
+// COICOP code and month, e.g. one per unit or geo, is rejected.
+func ParseCSV(r io.Reader, filter Filter) ([]Observation, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	coicopIdx := findColumn(header, coicopColumns)
+	periodIdx := findColumn(header, periodColumns)
+	valueIdx := findColumn(header, valueColumns)
+	if coicopIdx < 0 || periodIdx < 0 || valueIdx < 0 {
+		return nil, fmt.Errorf("CSV header must contain COICOP, period and value columns, got %v", header)
+	}
+
+	filterIdx := make(map[int]string, len(filter))
+	for column, want := range filter {
+		i := findColumn(header, []string{strings.ToLower(column)})
+		if i < 0 {
+			return nil, fmt.Errorf("CSV header has no %s column to filter on, got %v", column, header)
+		}
+		filterIdx[i] = want
+	}
+
+	var observations []Observation
+	seen := make(map[seriesKey][]string)
+	line := 1
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV line %d: %w", line, err)
+		}
+		if len(record) <= max(coicopIdx, periodIdx, valueIdx) {
+			return nil, fmt.Errorf("CSV line %d has %d columns, want at least %d", line, len(record), max(coicopIdx, periodIdx, valueIdx)+1)
+		}
+		if !matchesFilter(record, filterIdx) {
+			continue
+		}
+
+		rawValue := strings.TrimSpace(record[valueIdx])
+		if rawValue == "" || rawValue == ":" {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(rawValue, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q on CSV line %d: %w", rawValue, line, err)
+		}
+
+		month, err := ParseMonth(record[periodIdx])
+		if err != nil {
+			return nil, fmt.Errorf("CSV line %d: %w", line, err)
+		}
+
+		coicop := NormalizeCoicop(record[coicopIdx])
+		if coicop == "" {
+			return nil, fmt.Errorf("empty COICOP code on CSV line %d", line)
+		}
+
+		key := seriesKey{coicop, month}
+		if first, ok := seen[key]; ok {
+			for i := range record {
+				if i != valueIdx && i < len(first) && i < len(header) && record[i] != first[i] {
+					return nil, fmt.Errorf("CSV line %d: second value for %s %s, differing in %s (%q and %q); filter the file to one series",
+						line, coicop, month.Format("2006-01"), header[i], first[i], record[i])
+				}
+			}
+			return nil, fmt.Errorf("CSV line %d: second value for %s %s", line, coicop, month.Format("2006-01"))
+		}
+		seen[key] = record
+
+		observations = append(observations, Observation{Coicop: coicop, Month: month, Value: value})
+	}
+
+	return observations, nil
+}
+
+// sdmxMessage covers both SDMX-JSON 1.0 (dataSets and structure at the top
+// level) and 2.0 (wrapped in "data", with a "structures" array)
+type sdmxMessage struct {
+	sdmxData
+	Data *sdmxData `json:"data"`
+}
+
+type sdmxData struct {
+	DataSets   []sdmxDataSet   `json:"dataSets"`
+	Structure  *sdmxStructure  `json:"structure"`
+	Structures []sdmxStructure `json:"structures"`
+}
+
+type sdmxDataSet struct {
+	Series       map[string]sdmxSeries     `json:"series"`
+	Observations map[string][]*json.Number `json:"observations"`
+}
+
+type sdmxSeries struct {
+	Observations map[string][]*json.Number `json:"observations"`
+}
+
+type sdmxStructure struct {
+	Dimensions struct {
+		Series      []sdmxDimension `json:"series"`
+		Observation []sdmxDimension `json:"observation"`
+	} `json:"dimensions"`
+}
+
+type sdmxDimension struct {
+	ID     string `json:"id"`
+	Values []struct {
+		ID string `json:"id"`
+	} `json:"values"`
+}
+
+// ParseSDMXJSON reads an SDMX-JSON data message, in either series or flat
+// (AllDimensions) layout. The COICOP dimension is the one whose ID contains
+// "COICOP" and the period dimension is TIME_PERIOD. Observations the filter
+// leaves out are skipped, and a message with two values for the same COICOP
+// code and month, e.g. one per unit or geo, is rejected.
+func ParseSDMXJSON(r io.Reader, filter Filter) ([]Observation, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	var msg sdmxMessage
+	if err := decoder.Decode(&msg); err != nil {
+		return nil, fmt.Errorf("failed to parse SDMX-JSON: %w", err)
+	}
+
+	data := msg.sdmxData
+	if msg.Data != nil {
+		data = *msg.Data
+	}
+
+	structure := data.Structure
+	if structure == nil && len(data.Structures) > 0 {
+		structure = &data.Structures[0]
+	}
+	if structure == nil {
+		return nil, errors.New("SDMX-JSON message has no structure")
+	}
+	if len(data.DataSets) == 0 {
+		return nil, errors.New("SDMX-JSON message has no data sets")
+	}
+
+	// Dimension order is series dimensions followed by observation dimensions
+	dims := append(append([]sdmxDimension{}, structure.Dimensions.Series...), structure.Dimensions.Observation...)
+	coicopDim, periodDim := -1, -1
+	for i, dim := range dims {
+		switch {
+		case strings.Contains(strings.ToUpper(dim.ID), "COICOP"):
+			coicopDim = i
+		case strings.EqualFold(dim.ID, "TIME_PERIOD"):
+			periodDim = i
+		}
+	}
+	if coicopDim < 0 || periodDim < 0 {
+		return nil, errors.New("SDMX-JSON structure must have a COICOP and a TIME_PERIOD dimension")
+	}
+
+	filterDims := make(map[int]string, len(filter))
+	for id, want := range filter {
+		i := slices.IndexFunc(dims, func(dim sdmxDimension) bool { return strings.EqualFold(dim.ID, id) })
+		if i < 0 {
+			return nil, fmt.Errorf("SDMX-JSON structure has no %s dimension to filter on", id)
+		}
+		filterDims[i] = want
+	}
+
+	var observations []Observation
+	seen := make(map[seriesKey][]int)
+	add := func(key string, values []*json.Number) error {
+		if len(values) == 0 || values[0] == nil {
+			return nil
+		}
+
+		positions, err := parseKey(key)
+		if err != nil {
+			return err
+		}
+		if len(positions) != len(dims) {
+			return fmt.Errorf("SDMX-JSON key %q has %d dimensions, want %d", key, len(positions), len(dims))
+		}
+		for i, pos := range positions {
+			if _, err := dimensionValue(dims[i], pos); err != nil {
+				return err
+			}
+		}
+		for i, want := range filterDims {
+			value, err := dimensionValue(dims[i], positions[i])
+			if err != nil {
+				return err
+			}
+			if !strings.EqualFold(value, want) {
+				return nil
+			}
+		}
+
+		coicop, err := dimensionValue(dims[coicopDim], positions[coicopDim])
+		if err != nil {
+			return err
+		}
+		period, err := dimensionValue(dims[periodDim], positions[periodDim])
+		if err != nil {
+			return err
+		}
+
+		month, err := ParseMonth(period)
+		if err != nil {
+			return err
+		}
+		value, err := values[0].Float64()
+		if err != nil {
+			return fmt.Errorf("invalid SDMX-JSON value %q: %w", values[0].String(), err)
+		}
+
+		coicop = NormalizeCoicop(coicop)
+		k := seriesKey{coicop, month}
+		if first, ok := seen[k]; ok {
+			for i := range positions {
+				if positions[i] != first[i] {
+					return fmt.Errorf("SDMX-JSON has a second value for %s %s, differing in %s (%q and %q); filter the message to one series",
+						coicop, month.Format("2006-01"), dims[i].ID, dims[i].Values[first[i]].ID, dims[i].Values[positions[i]].ID)
+				}
+			}
+			return fmt.Errorf("SDMX-JSON has a second value for %s %s", coicop, month.Format("2006-01"))
+		}
+		seen[k] = positions
+
+		observations = append(observations, Observation{Coicop: coicop, Month: month, Value: value})
+		return nil
+	}
+
+	for _, dataSet := range data.DataSets {
+		for seriesKey, series := range dataSet.Series {
+			for obsKey, values := range series.Observations {
+				if err := add(seriesKey+":"+obsKey, values); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for obsKey, values := range dataSet.Observations {
+			if err := add(obsKey, values); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return observations, nil
+}
+
+// ParseMonth accepts the period notations used by CYSTAT and Eurostat:
+// 2024-01, 2024M01, 2024-01-01 and 2024/01
+func ParseMonth(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"2006-01", "2006M01", "2006-01-02", "2006/01"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid month %q", s)
+}
+
+// NormalizeCoicop trims and upper-cases a COICOP code so that codes from
+// different publications ("cp01", "CP01 ") compare equal
+func NormalizeCoicop(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ParseCategoryMapping reads a CSV of category_external_id,coicop pairs
+// mapping eKalathi categories to COICOP groups
+func ParseCategoryMapping(r io.Reader) (map[int]string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read category mapping: %w", err)
+	}
+
+	mapping := make(map[int]string)
+	for i, record := range records {
+		if len(record) != 2 {
+			return nil, fmt.Errorf("category mapping line %d has %d columns, want 2", i+1, len(record))
+		}
+
+		externalID, err := strconv.Atoi(strings.TrimSpace(record[0]))
+		if err != nil {
+			if i == 0 {
+				continue // header
+			}
+			return nil, fmt.Errorf("invalid category ID %q on mapping line %d: %w", record[0], i+1, err)
+		}
+
+		coicop := NormalizeCoicop(record[1])
+		if coicop == "" {
+			return nil, fmt.Errorf("empty COICOP code on mapping line %d", i+1)
+		}
+		mapping[externalID] = coicop
+	}
+
+	return mapping, nil
+}
+
+// Helper functions
+
+func findColumn(header []string, aliases []string) int {
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for _, alias := range aliases {
+			if name == alias {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// matchesFilter reports whether a CSV record has the wanted value in every
+// filtered column
+func matchesFilter(record []string, filterIdx map[int]string) bool {
+	for i, want := range filterIdx {
+		if i >= len(record) || !strings.EqualFold(strings.TrimSpace(record[i]), want) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseKey(key string) ([]int, error) {
+	parts := strings.Split(key, ":")
+	positions := make([]int, len(parts))
+	for i, part := range parts {
+		pos, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SDMX-JSON key %q: %w", key, err)
+		}
+		positions[i] = pos
+	}
+	return positions, nil
+}
+
+func dimensionValue(dim sdmxDimension, pos int) (string, error) {
+	if pos < 0 || pos >= len(dim.Values) {
+		return "", fmt.Errorf("SDMX-JSON dimension %s has no value at position %d", dim.ID, pos)
+	}
+	return dim.Values[pos].ID, nil
+}
@@ -0,0 +1,329 @@
+package cpi
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sortObservations(obs []Observation) {
+	sort.Slice(obs, func(i, j int) bool {
+		if obs[i].Coicop != obs[j].Coicop {
+			return obs[i].Coicop < obs[j].Coicop
+		}
+		return obs[i].Month.Before(obs[j].Month)
+	})
+}
+
+func month(year int, m time.Month) time.Time {
+	return time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseMonth(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"ISO month", "2024-01", month(2024, time.January), false},
+		{"SDMX month", "2024M03", month(2024, time.March), false},
+		{"full date", "2024-05-17", month(2024, time.May), false},
+		{"slash separated", "2023/12", month(2023, time.December), false},
+		{"surrounding spaces", " 2024-02 ", month(2024, time.February), false},
+		{"quarter rejected", "2024-Q1", time.Time{}, true},
+		{"empty rejected", "", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMonth(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMonth(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseMonth(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	t.Run("SDMX-CSV with extra dimensions", func(t *testing.T) {
+		input := "DATAFLOW,freq,unit,coicop,geo,TIME_PERIOD,OBS_VALUE,OBS_FLAG\n" +
+			"ESTAT:PRC_HICP_MIDX(1.0),M,I15,cp01,CY,2024-01,121.5,\n" +
+			"ESTAT:PRC_HICP_MIDX(1.0),M,I15,CP01,CY,2024-02,122.04,\n" +
+			"ESTAT:PRC_HICP_MIDX(1.0),M,I15,CP011,CY,2024-01,:,\n"
+
+		got, err := ParseCSV(strings.NewReader(input), nil)
+		if err != nil {
+			t.Fatalf("ParseCSV() error = %v", err)
+		}
+
+		want := []Observation{
+			{Coicop: "CP01", Month: month(2024, time.January), Value: 121.5},
+			{Coicop: "CP01", Month: month(2024, time.February), Value: 122.04},
+		}
+		if len(got) != len(want) {
+			t.Fatalf("ParseCSV() returned %d observations, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("observation %d = %+v, want %+v", i, got[i], want[i])
+			}
+		}
+	})
+
+	t.Run("several units and geos", func(t *testing.T) {
+		input := "DATAFLOW,freq,unit,coicop,geo,TIME_PERIOD,OBS_VALUE,OBS_FLAG\n" +
+			"ESTAT:PRC_HICP_MIDX(1.0),M,I15,CP01,CY,2024-01,121.5,\n" +
+			"ESTAT:PRC_HICP_MIDX(1.0),M,I05,CP01,CY,2024-01,140.1,\n" +
+			"ESTAT:PRC_HICP_MIDX(1.0),M,I15,CP01,EL,2024-01,118.3,\n" +
+			"ESTAT:PRC_HICP_MIDX(1.0),M,I15,CP01,CY,2024-02,122.04,\n"
+
+		tests := []struct {
+			name    string
+			filter  Filter
+			want    []float64 // values, in file order
+			wantErr string
+		}{
+			{name: "unfiltered", wantErr: "differing in unit"},
+			{name: "one unit", filter: Filter{"unit": "I15"}, wantErr: "differing in geo"},
+			{name: "one series", filter: Filter{"UNIT": "i15", "geo": "CY"}, want: []float64{121.5, 122.04}},
+			{name: "other geo", filter: Filter{"unit": "I15", "geo": "EL"}, want: []float64{118.3}},
+			{name: "unknown column", filter: Filter{"indic": "CP-HI"}, wantErr: "no indic column"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := ParseCSV(strings.NewReader(input), tt.filter)
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("ParseCSV() error = %v, want one mentioning %q", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("ParseCSV() error = %v", err)
+				}
+				if len(got) != len(tt.want) {
+					t.Fatalf("ParseCSV() = %+v, want values %v", got, tt.want)
+				}
+				for i, value := range tt.want {
+					if got[i].Value != value {
+						t.Errorf("observation %d = %+v, want value %v", i, got[i], value)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("plain columns", func(t *testing.T) {
+		input := "coicop,month,value\n01,2024M01,110.2\n"
+
+		got, err := ParseCSV(strings.NewReader(input), nil)
+		if err != nil {
+			t.Fatalf("ParseCSV() error = %v", err)
+		}
+		if len(got) != 1 || got[0].Coicop != "01" || got[0].Value != 110.2 {
+			t.Errorf("ParseCSV() = %+v", got)
+		}
+	})
+
+	t.Run("missing columns", func(t *testing.T) {
+		_, err := ParseCSV(strings.NewReader("coicop,value\n01,1\n"), nil)
+		if err == nil {
+			t.Error("ParseCSV() should fail without a period column")
+		}
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		_, err := ParseCSV(strings.NewReader("coicop,month,value\n01,2024-01,abc\n"), nil)
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("ParseCSV() error = %v, want error mentioning line 2", err)
+		}
+	})
+}
+
+func TestParseSDMXJSON(t *testing.T) {
+	t.Run("series layout (SDMX-JSON 1.0)", func(t *testing.T) {
+		input := `{
+			"dataSets": [{
+				"series": {
+					"0:0": {"observations": {"0": [101.5], "1": [102.25]}},
+					"0:1": {"observations": {"0": [99.0], "1": [null]}}
+				}
+			}],
+			"structure": {
+				"dimensions": {
+					"series": [
+						{"id": "FREQ", "values": [{"id": "M"}]},
+						{"id": "COICOP", "values": [{"id": "01"}, {"id": "02"}]}
+					],
+					"observation": [
+						{"id": "TIME_PERIOD", "values": [{"id": "2024-01"}, {"id": "2024-02"}]}
+					]
+				}
+			}
+		}`
+
+		got, err := ParseSDMXJSON(strings.NewReader(input), nil)
+		if err != nil {
+			t.Fatalf("ParseSDMXJSON() error = %v", err)
+		}
+		sortObservations(got)
+
+		want := []Observation{
+			{Coicop: "01", Month: month(2024, time.January), Value: 101.5},
+			{Coicop: "01", Month: month(2024, time.February), Value: 102.25},
+			{Coicop: "02", Month: month(2024, time.January), Value: 99.0},
+		}
+		if len(got) != len(want) {
+			t.Fatalf("ParseSDMXJSON() returned %d observations, want %d: %+v", len(got), len(want), got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("observation %d = %+v, want %+v", i, got[i], want[i])
+			}
+		}
+	})
+
+	t.Run("flat layout (SDMX-JSON 2.0)", func(t *testing.T) {
+		input := `{
+			"data": {
+				"dataSets": [{"observations": {"0:0": [120.1], "0:1": [120.9]}}],
+				"structures": [{
+					"dimensions": {
+						"observation": [
+							{"id": "COICOP_2018", "values": [{"id": "CP00"}]},
+							{"id": "TIME_PERIOD", "values": [{"id": "2025-01"}, {"id": "2025-02"}]}
+						]
+					}
+				}]
+			}
+		}`
+
+		got, err := ParseSDMXJSON(strings.NewReader(input), nil)
+		if err != nil {
+			t.Fatalf("ParseSDMXJSON() error = %v", err)
+		}
+		sortObservations(got)
+
+		if len(got) != 2 {
+			t.Fatalf("ParseSDMXJSON() returned %d observations, want 2", len(got))
+		}
+		if got[1].Coicop != "CP00" || !got[1].Month.Equal(month(2025, time.February)) || got[1].Value != 120.9 {
+			t.Errorf("observation 1 = %+v", got[1])
+		}
+	})
+
+	t.Run("several units", func(t *testing.T) {
+		input := `{
+			"dataSets": [{"observations": {"0:0:0": [121.5], "1:0:0": [140.1], "0:0:1": [122.04]}}],
+			"structure": {"dimensions": {"observation": [
+				{"id": "unit", "values": [{"id": "I15"}, {"id": "I05"}]},
+				{"id": "coicop", "values": [{"id": "CP01"}]},
+				{"id": "TIME_PERIOD", "values": [{"id": "2024-01"}, {"id": "2024-02"}]}
+			]}}
+		}`
+
+		_, err := ParseSDMXJSON(strings.NewReader(input), nil)
+		if err == nil || !strings.Contains(err.Error(), "differing in unit") {
+			t.Errorf("ParseSDMXJSON() error = %v, want one naming the unit dimension", err)
+		}
+
+		got, err := ParseSDMXJSON(strings.NewReader(input), Filter{"UNIT": "i05"})
+		if err != nil {
+			t.Fatalf("ParseSDMXJSON() error = %v", err)
+		}
+		if len(got) != 1 || got[0].Value != 140.1 {
+			t.Errorf("ParseSDMXJSON() = %+v, want the I05 value only", got)
+		}
+
+		if _, err := ParseSDMXJSON(strings.NewReader(input), Filter{"geo": "CY"}); err == nil {
+			t.Error("ParseSDMXJSON() should fail to filter on a missing dimension")
+		}
+	})
+
+	t.Run("missing COICOP dimension", func(t *testing.T) {
+		input := `{"dataSets": [{}], "structure": {"dimensions": {"observation": [{"id": "TIME_PERIOD", "values": []}]}}}`
+		if _, err := ParseSDMXJSON(strings.NewReader(input), nil); err == nil {
+			t.Error("ParseSDMXJSON() should fail without a COICOP dimension")
+		}
+	})
+
+	t.Run("key out of range", func(t *testing.T) {
+		input := `{
+			"dataSets": [{"observations": {"3:0": [1]}}],
+			"structure": {"dimensions": {"observation": [
+				{"id": "COICOP", "values": [{"id": "01"}]},
+				{"id": "TIME_PERIOD", "values": [{"id": "2024-01"}]}
+			]}}
+		}`
+		if _, err := ParseSDMXJSON(strings.NewReader(input), nil); err == nil {
+			t.Error("ParseSDMXJSON() should fail on an out-of-range key")
+		}
+	})
+
+	t.Run("key out of range in another dimension", func(t *testing.T) {
+		input := `{
+			"dataSets": [{"observations": {"0:0:0": [121.5], "5:0:0": [140.1]}}],
+			"structure": {"dimensions": {"observation": [
+				{"id": "unit", "values": [{"id": "I15"}]},
+				{"id": "COICOP", "values": [{"id": "CP01"}]},
+				{"id": "TIME_PERIOD", "values": [{"id": "2024-01"}]}
+			]}}
+		}`
+		_, err := ParseSDMXJSON(strings.NewReader(input), nil)
+		if err == nil || !strings.Contains(err.Error(), "dimension unit has no value at position 5") {
+			t.Errorf("ParseSDMXJSON() error = %v, want one about the unit position", err)
+		}
+	})
+}
+
+func TestParse(t *testing.T) {
+	if _, err := Parse("cpi.xlsx", strings.NewReader(""), nil); err == nil {
+		t.Error("Parse() should reject unknown extensions")
+	}
+
+	got, err := Parse("CPI.CSV", strings.NewReader("coicop,month,value\n01,2024-01,1\n"), nil)
+	if err != nil || len(got) != 1 {
+		t.Errorf("Parse() = %+v, %v", got, err)
+	}
+}
+
+func TestParseCategoryMapping(t *testing.T) {
+	t.Run("with header", func(t *testing.T) {
+		input := "category_external_id,coicop\n12,cp011\n 13 , CP012\n"
+
+		got, err := ParseCategoryMapping(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("ParseCategoryMapping() error = %v", err)
+		}
+
+		want := map[int]string{12: "CP011", 13: "CP012"}
+		if len(got) != len(want) {
+			t.Fatalf("ParseCategoryMapping() returned %d entries, want %d", len(got), len(want))
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("mapping[%d] = %q, want %q", k, got[k], v)
+			}
+		}
+	})
+
+	t.Run("invalid ID after header", func(t *testing.T) {
+		_, err := ParseCategoryMapping(strings.NewReader("12,CP011\nabc,CP012\n"))
+		if err == nil {
+			t.Error("ParseCategoryMapping() should fail on a non-numeric ID")
+		}
+	})
+
+	t.Run("empty COICOP", func(t *testing.T) {
+		_, err := ParseCategoryMapping(strings.NewReader("12, \n"))
+		if err == nil {
+			t.Error("ParseCategoryMapping() should fail on an empty COICOP code")
+		}
+	})
+}
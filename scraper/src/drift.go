@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/config"
+	ekalathiapi "github.com/pheever/cy-price-watchdog/scraper/src/ekalathi-api"
+)
+
+// errSchemaViolation marks a response whose required fields are absent or
+// zero, see ekalathiapi.Schemas
+var errSchemaViolation = errors.New("response breaks the pinned schema")
+
+// schemaDrift collects how the responses of a run differ from the pinned
+// schemas, by endpoint. A nil schemaDrift collects nothing.
+type schemaDrift struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpointDrift
+}
+
+// endpointDrift is the drift of one endpoint's responses
+type endpointDrift struct {
+	known      map[string]bool
+	unknown    map[string]bool
+	missing    map[string]bool
+	violations map[string]bool
+}
+
+// driftReport lists the fields an endpoint's responses had beyond its schema,
+// and the ones none of them had
+type driftReport struct {
+	Endpoint string
+	Unknown  []string
+	Missing  []string
+}
+
+// record adds the shape of a response from endpoint and returns its
+// violations that no earlier response of the run had
+func (d *schemaDrift) record(endpoint string, shape ekalathiapi.Shape) []string {
+	if d == nil {
+		return shape.Violations
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.endpoints == nil {
+		d.endpoints = map[string]*endpointDrift{}
+	}
+	e, ok := d.endpoints[endpoint]
+	if !ok {
+		e = &endpointDrift{known: map[string]bool{}, unknown: map[string]bool{}, missing: map[string]bool{}, violations: map[string]bool{}}
+		d.endpoints[endpoint] = e
+	}
+	for _, field := range shape.Known {
+		e.known[field] = true
+	}
+	for _, field := range shape.Unknown {
+		e.unknown[field] = true
+	}
+	for _, field := range shape.Missing {
+		e.missing[field] = true
+	}
+
+	var violations []string
+	for _, field := range shape.Violations {
+		if !e.violations[field] {
+			e.violations[field] = true
+			violations = append(violations, field)
+		}
+	}
+	return violations
+}
+
+// reports returns the drift of every endpoint whose responses differed from
+// its schema, sorted by endpoint. A field counts as missing when no response
+// had it.
+func (d *schemaDrift) reports() []driftReport {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var reports []driftReport
+	for _, endpoint := range slices.Sorted(maps.Keys(d.endpoints)) {
+		e := d.endpoints[endpoint]
+		r := driftReport{Endpoint: endpoint, Unknown: slices.Sorted(maps.Keys(e.unknown))}
+		for _, field := range slices.Sorted(maps.Keys(e.missing)) {
+			if !e.known[field] {
+				r.Missing = append(r.Missing, field)
+			}
+		}
+		if len(r.Unknown) > 0 || len(r.Missing) > 0 {
+			reports = append(reports, r)
+		}
+	}
+	return reports
+}
+
+// decodeResponse decodes the body of a response from endpoint into v, then
+// checks it against the endpoint's pinned schema
+func (s *Scraper) decodeResponse(ctx context.Context, endpoint, what string, r io.Reader, v any) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if err := decodeJSON(ctx, what, bytes.NewReader(body), v); err != nil {
+		return err
+	}
+	return s.checkSchema(endpoint, body)
+}
+
+// checkSchema records how a response differs from its endpoint's schema.
+// A response whose required fields are absent or zero is reported as an
+// anomaly the first time in a run, and fails unless scrape.schema_drift is
+// "warn".
+func (s *Scraper) checkSchema(endpoint string, body []byte) error {
+	schema, ok := ekalathiapi.Schemas[endpoint]
+	if !ok {
+		return nil
+	}
+	shape, err := schema.Inspect(body)
+	if err != nil {
+		return err
+	}
+
+	if violations := s.drift.record(endpoint, shape); len(violations) > 0 {
+		s.logger.Warn("eKalathi response has required fields absent or zero", "endpoint", endpoint, "fields", violations, "mode", s.scrape.SchemaDrift)
+		s.event(eventAnomalyDetected, map[string]interface{}{
+			"kind":     "schema_violation",
+			"endpoint": endpoint,
+			"fields":   violations,
+		})
+	}
+	if len(shape.Violations) == 0 {
+		return nil
+	}
+
+	s.count("schema_violations", 1, map[string]string{"endpoint": endpoint})
+	if s.scrape.SchemaDrift == config.SchemaDriftWarn {
+		return nil
+	}
+	return fmt.Errorf("%w: %s absent or zero", errSchemaViolation, strings.Join(shape.Violations, ", "))
+}
+
+// reportDrift logs and counts, per endpoint, the fields the run's responses
+// had beyond the pinned schema and the ones they lacked
+func (s *Scraper) reportDrift() {
+	for _, r := range s.drift.reports() {
+		s.logger.Warn("eKalathi responses drifted from the pinned schema", "endpoint", r.Endpoint, "unknown", r.Unknown, "missing", r.Missing)
+		if len(r.Unknown) > 0 {
+			s.count("schema_drift", len(r.Unknown), map[string]string{"endpoint": r.Endpoint, "kind": "unknown"})
+		}
+		if len(r.Missing) > 0 {
+			s.count("schema_drift", len(r.Missing), map[string]string{"endpoint": r.Endpoint, "kind": "missing"})
+		}
+		s.event(eventAnomalyDetected, map[string]interface{}{
+			"kind":     "schema_drift",
+			"endpoint": r.Endpoint,
+			"unknown":  r.Unknown,
+			"missing":  r.Missing,
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/config"
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+)
+
+func TestSchemaDrift(t *testing.T) {
+	// retailerProductPrice renamed to price. A name on one branch is enough.
+	page := `{"content": [
+		{"id": 100, "name": "A1", "companyName": "Alpha", "price": 1.2},
+		{"id": 200, "companyName": "Beta", "price": 1.4}
+	], "last": true}`
+
+	tests := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{config.SchemaDriftFail, true},
+		{config.SchemaDriftWarn, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			s := newTestScraper(t, store.NewMemory(), func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(page))
+			})
+			s.scrape.SchemaDrift = tt.mode
+			s, ctx := s.forRun(context.Background())
+
+			for range 2 {
+				branches, _, err := s.fetchRetailBranchesPage(ctx, 10, 1, 0)
+				if tt.wantErr != errors.Is(err, errSchemaViolation) {
+					t.Fatalf("fetchRetailBranchesPage() error = %v, want a schema violation: %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && len(branches) != 2 {
+					t.Fatalf("got %d branches, want 2", len(branches))
+				}
+			}
+			s.reportDrift()
+
+			rec := httptest.NewRecorder()
+			s.metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+			body := rec.Body.String()
+			for _, want := range []string{
+				`scraper_schema_violations_total{endpoint="retail/fetch-retail-branch-list"} 2`,
+				`scraper_schema_drift_total{endpoint="retail/fetch-retail-branch-list",kind="unknown"} 1`,
+				// the price, 12 optional branch fields and 4 optional page fields
+				`scraper_schema_drift_total{endpoint="retail/fetch-retail-branch-list",kind="missing"} 17`,
+				// one violation anomaly despite two pages, one drift anomaly
+				`scraper_events_total{event="anomaly_detected"} 2`,
+			} {
+				if !strings.Contains(body, want) {
+					t.Errorf("metrics lack %s:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+func TestSchemaDriftWithoutCollector(t *testing.T) {
+	// As built by the regions command, with no collector
+	s := &Scraper{
+		client: &http.Client{Transport: handlerTransport{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[{"id": 1, "title": "Nicosia"}]`))
+		})}},
+		logger: slog.New(slog.DiscardHandler),
+		drift:  &schemaDrift{},
+	}
+
+	if _, err := s.fetchRegions(context.Background()); !errors.Is(err, errSchemaViolation) {
+		t.Errorf("fetchRegions() error = %v, want a schema violation", err)
+	}
+	s.reportDrift()
+}
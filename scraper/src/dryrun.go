@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+)
+
+// maxDryRunSamples bounds the new rows and price changes listed in a dry run
+// summary; the counts always cover everything
+const maxDryRunSamples = 100
+
+// dryRunIDPrefix marks the IDs handed out for rows a dry run would create
+const dryRunIDPrefix = "dry-run:"
+
+// dryRun collects what a run with RunOptions.DryRun would have written. The
+// upsert and insert methods report to it instead of writing, and only read
+// the database to tell new rows and changed prices from existing ones.
+type dryRun struct {
+	mu      sync.Mutex
+	summary DryRunSummary
+}
+
+// DryRunSummary is logged at the end of a dry run
+type DryRunSummary struct {
+	Categories   DryRunCounts        `json:"categories"`
+	Products     DryRunCounts        `json:"products"`
+	Stores       DryRunCounts        `json:"stores"`
+	Prices       DryRunPriceCounts   `json:"prices"`
+	NewProducts  []DryRunEntity      `json:"newProducts,omitempty"`
+	NewStores    []DryRunEntity      `json:"newStores,omitempty"`
+	PriceChanges []DryRunPriceChange `json:"priceChanges,omitempty"`
+	Anomalies    []map[string]any    `json:"anomalies,omitempty"`
+}
+
+// DryRunCounts counts the rows an upsert would insert or update
+type DryRunCounts struct {
+	New      int `json:"new"`
+	Existing int `json:"existing"`
+}
+
+// DryRunPriceCounts compares scraped prices with the latest stored price for
+// the same product and store
+type DryRunPriceCounts struct {
+	New       int `json:"new"`
+	Changed   int `json:"changed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// DryRunEntity is a product or store the run would create
+type DryRunEntity struct {
+	ExternalID int    `json:"externalId"`
+	Name       string `json:"name"`
+}
+
+// DryRunPriceChange is a price that differs from the latest stored one
+type DryRunPriceChange struct {
+	Product  string  `json:"product"`
+	Store    string  `json:"store"`
+	Previous float64 `json:"previous"`
+	Price    float64 `json:"price"`
+}
+
+func (d *dryRun) recordEntity(kind store.Kind, externalID int, name string, isNew bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var counts *DryRunCounts
+	var samples *[]DryRunEntity
+	switch kind {
+	case store.KindCategory:
+		counts = &d.summary.Categories
+	case store.KindProduct:
+		counts, samples = &d.summary.Products, &d.summary.NewProducts
+	case store.KindStore:
+		counts, samples = &d.summary.Stores, &d.summary.NewStores
+	default:
+		return
+	}
+
+	if !isNew {
+		counts.Existing++
+		return
+	}
+	counts.New++
+	if samples != nil && len(*samples) < maxDryRunSamples {
+		*samples = append(*samples, DryRunEntity{ExternalID: externalID, Name: name})
+	}
+}
+
+// recordPrice counts a scraped price. change is nil for a price with nothing
+// stored to compare against.
+func (d *dryRun) recordPrice(change *DryRunPriceChange) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch {
+	case change == nil:
+		d.summary.Prices.New++
+	case change.Previous == change.Price:
+		d.summary.Prices.Unchanged++
+	default:
+		d.summary.Prices.Changed++
+		if len(d.summary.PriceChanges) < maxDryRunSamples {
+			d.summary.PriceChanges = append(d.summary.PriceChanges, *change)
+		}
+	}
+}
+
+func (d *dryRun) recordAnomaly(properties map[string]any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.summary.Anomalies) < maxDryRunSamples {
+		d.summary.Anomalies = append(d.summary.Anomalies, properties)
+	}
+}
+
+// Summary returns a copy of what has been collected so far
+func (d *dryRun) Summary() DryRunSummary {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	summary := d.summary
+	summary.NewProducts = append([]DryRunEntity(nil), d.summary.NewProducts...)
+	summary.NewStores = append([]DryRunEntity(nil), d.summary.NewStores...)
+	summary.PriceChanges = append([]DryRunPriceChange(nil), d.summary.PriceChanges...)
+	summary.Anomalies = append([]map[string]any(nil), d.summary.Anomalies...)
+	return summary
+}
+
+// --- Dry Run Methods ---
+
+// previewUpsert stands in for an upsert during a dry run. It returns the ID
+// of the existing row, or a placeholder for a row the run would create.
+func (s *Scraper) previewUpsert(ctx context.Context, kind store.Kind, externalID int, name string) (string, error) {
+	id, err := s.db.LookupID(ctx, kind, externalID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		s.dryRun.recordEntity(kind, externalID, name, true)
+		return dryRunIDPrefix + strings.ToLower(string(kind)) + ":" + strconv.Itoa(externalID), nil
+	case err != nil:
+		return "", err
+	}
+	s.dryRun.recordEntity(kind, externalID, name, false)
+	return id, nil
+}
+
+// previewPrice stands in for insertPrice during a dry run, comparing price
+// with the latest one stored for the product and store
+func (s *Scraper) previewPrice(ctx context.Context, productID, storeID string, price float64) error {
+	if strings.HasPrefix(productID, dryRunIDPrefix) || strings.HasPrefix(storeID, dryRunIDPrefix) {
+		s.dryRun.recordPrice(nil)
+		return nil
+	}
+
+	latest, err := s.db.LatestPrice(ctx, productID, storeID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		s.dryRun.recordPrice(nil)
+		return nil
+	case err != nil:
+		return err
+	}
+	s.dryRun.recordPrice(&DryRunPriceChange{
+		Product:  latest.Product,
+		Store:    latest.Store,
+		Previous: latest.Price,
+		Price:    price,
+	})
+	return nil
+}
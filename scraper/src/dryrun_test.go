@@ -0,0 +1,65 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/metrics"
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+)
+
+func TestDryRunRecordEntity(t *testing.T) {
+	d := &dryRun{}
+	d.recordEntity(store.KindCategory, 1, "Dairy", true)
+	d.recordEntity(store.KindCategory, 2, "Bakery", false)
+	d.recordEntity(store.KindStore, 3, "Central", false)
+	for i := range maxDryRunSamples + 5 {
+		d.recordEntity(store.KindProduct, i, "Milk", true)
+	}
+
+	got := d.Summary()
+	if got.Categories != (DryRunCounts{New: 1, Existing: 1}) {
+		t.Errorf("categories = %+v, want 1 new and 1 existing", got.Categories)
+	}
+	if got.Stores != (DryRunCounts{Existing: 1}) || len(got.NewStores) != 0 {
+		t.Errorf("stores = %+v with %d new, want 1 existing", got.Stores, len(got.NewStores))
+	}
+	if got.Products.New != maxDryRunSamples+5 {
+		t.Errorf("new products = %d, want every one counted", got.Products.New)
+	}
+	if len(got.NewProducts) != maxDryRunSamples {
+		t.Errorf("listed %d new products, want at most %d", len(got.NewProducts), maxDryRunSamples)
+	}
+}
+
+func TestDryRunRecordPrice(t *testing.T) {
+	d := &dryRun{}
+	d.recordPrice(nil)
+	d.recordPrice(&DryRunPriceChange{Product: "Milk", Store: "Central", Previous: 1.5, Price: 1.5})
+	d.recordPrice(&DryRunPriceChange{Product: "Milk", Store: "Central", Previous: 1.5, Price: 1.8})
+
+	got := d.Summary()
+	if got.Prices != (DryRunPriceCounts{New: 1, Changed: 1, Unchanged: 1}) {
+		t.Errorf("prices = %+v, want 1 each", got.Prices)
+	}
+	if len(got.PriceChanges) != 1 || got.PriceChanges[0].Price != 1.8 {
+		t.Errorf("price changes = %+v, want only the change to 1.8", got.PriceChanges)
+	}
+
+	// The summary is a copy
+	got.PriceChanges[0].Price = 0
+	if d.Summary().PriceChanges[0].Price != 1.8 {
+		t.Error("changing a summary must not change the dry run")
+	}
+}
+
+func TestDryRunRecordsAnomalies(t *testing.T) {
+	s := &Scraper{metrics: metrics.NewWithOptions(metrics.Options{}), progress: NewProgress(), logger: slog.New(slog.DiscardHandler), dryRun: &dryRun{}}
+	s.checkFailureRate(phasePrices, 5, 10)
+	s.checkFailureRate(phaseProducts, 0, 10)
+
+	got := s.dryRun.Summary().Anomalies
+	if len(got) != 1 || got[0]["phase"] != phasePrices {
+		t.Errorf("anomalies = %v, want the prices failure rate", got)
+	}
+}
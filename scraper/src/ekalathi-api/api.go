@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
 )
 
 const (
@@ -32,6 +33,17 @@ func newBaseRequest(method, endpoint string) (*http.Request, error) {
 	return req, nil
 }
 
+// Endpoint returns the API endpoint a request targets, e.g.
+// "fetch-product-list", without the host, root path or query. It is used to
+// label request metrics.
+func Endpoint(req *http.Request) string {
+	p := strings.TrimPrefix(req.URL.Path, "/")
+	if rest, ok := strings.CutPrefix(p, APIRootPath+"/"); ok {
+		return rest
+	}
+	return p
+}
+
 func GetCategory(params CategoryRequest) (*http.Request, error) {
 	req, err := newBaseRequest("GET", CategoriesEndpoint)
 	if err != nil {
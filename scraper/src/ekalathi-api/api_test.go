@@ -332,3 +332,27 @@ func assertRequest(t *testing.T, req *http.Request, method, host, path string) {
 		t.Errorf("path = %q, want %q", req.URL.Path, path)
 	}
 }
+
+func TestEndpoint(t *testing.T) {
+	products, _ := GetProducts(ProductRequest{CategoryIds: []int{1}, Page: 2})
+	branches, _ := GetBranches(RetailBranchRequest{ProductId: 1, RegionIds: []int{2}})
+	other, _ := http.NewRequest("GET", "https://example.com/health", http.NoBody)
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want string
+	}{
+		{"strips root path and query", products, ProductsEndpoint},
+		{"keeps nested endpoints", branches, RetailBranchesEndpoint},
+		{"other hosts keep their path", other, "health"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Endpoint(tt.req); got != tt.want {
+				t.Errorf("Endpoint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
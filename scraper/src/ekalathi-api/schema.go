@@ -0,0 +1,244 @@
+package ekalathiapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Field says how much the scraper relies on a field of a pinned schema
+type Field int
+
+const (
+	// Optional fields may be absent
+	Optional Field = iota
+	// Required fields must be present in at least one object of a response
+	Required
+	// NonZero fields must be present and non-zero in at least one object of a
+	// response: a page where every price is 0 means the field was renamed,
+	// not that everything is free
+	NonZero
+)
+
+// Schema pins the fields of an endpoint's response by path. Fields of nested
+// objects are joined with dots and array elements are marked with [], so
+// "content[].retailerProductPrice" is the price of every branch on a page.
+type Schema map[string]Field
+
+// Schemas pins the responses the scraper decodes, by endpoint, as the types
+// in this package expect them. Keep them in sync when a type changes.
+var Schemas = map[string]Schema{
+	CategoriesEndpoint: {
+		"[].id":                                     NonZero,
+		"[].code":                                   Optional,
+		"[].name":                                   NonZero,
+		"[].nameEnglish":                            Optional,
+		"[].productCategoryResponses":               Optional,
+		"[].productCategoryResponses[].id":          NonZero,
+		"[].productCategoryResponses[].code":        Optional,
+		"[].productCategoryResponses[].name":        NonZero,
+		"[].productCategoryResponses[].nameEnglish": Optional,
+	},
+	RegionsEndpoint: {
+		"[].id":   NonZero,
+		"[].name": NonZero,
+	},
+	CompaniesEndpoint: {
+		"[].id":   NonZero,
+		"[].name": NonZero,
+	},
+	ProductsEndpoint: page(map[string]Field{
+		"productMasterId":            NonZero,
+		"code":                       Optional,
+		"name":                       NonZero,
+		"discount":                   Optional,
+		"startPrice":                 Optional,
+		"previousPrice":              Optional,
+		"productCategoryName":        Optional,
+		"productCategoryNameEnglish": Optional,
+		"numberOfChains":             Optional,
+		"favorite":                   Optional,
+		"preferred":                  Optional,
+		"productMainPhotoFileId":     Optional,
+		"productMainPhotoFileType":   Optional,
+		"notifiedAbout":              Optional,
+		"hasBeenPurchased":           Optional,
+		"toSendOffers":               Optional,
+		"productMainPhotoUrl":        Optional,
+	}),
+	ProductEndpoint: {
+		"productMasterId":            Optional,
+		"code":                       Optional,
+		"name":                       Optional,
+		"discount":                   Optional,
+		"startPrice":                 Optional,
+		"previousPrice":              Optional,
+		"productCategoryName":        Optional,
+		"productCategoryNameEnglish": Optional,
+		"numberOfChains":             Optional,
+		"favorite":                   Optional,
+		"preferred":                  Optional,
+		"productMainPhotoFileId":     Optional,
+		"productMainPhotoFileType":   Optional,
+		"notifiedAbout":              Optional,
+		"hasBeenPurchased":           Optional,
+		"toSendOffers":               Optional,
+		"productMainPhotoUrl":        Optional,
+		"priceHistory":               Optional,
+		"priceHistory[].date":        NonZero,
+		"priceHistory[].price":       NonZero,
+	},
+	RetailBranchesEndpoint: page(map[string]Field{
+		"id":                          NonZero,
+		"name":                        NonZero,
+		"landPhone":                   Optional,
+		"postalAddress":               Optional,
+		"companyName":                 NonZero,
+		"companyPhotoUrl":             Optional,
+		"companyPhotoFileName":        Optional,
+		"companyPhotoFileType":        Optional,
+		"companyPhotoFile":            Optional,
+		"branchLatitude":              Optional,
+		"branchLongitude":             Optional,
+		"closerBranchDistance":        Optional,
+		"retailerProductPrice":        NonZero,
+		"retailerInitialProductPrice": Optional,
+		"retailerBasketProducts":      Optional,
+		"isInOfferOrDiscount":         Optional,
+	}),
+}
+
+// page returns the schema of a PaginatedResponse with content fields
+func page(content map[string]Field) Schema {
+	s := Schema{
+		"content":       Required,
+		"totalElements": Optional,
+		"totalPages":    Optional,
+		"last":          Required,
+		"first":         Optional,
+		"empty":         Optional,
+	}
+	for path, field := range content {
+		s["content[]."+path] = field
+	}
+	return s
+}
+
+// Shape is how a response compares with its schema. Every list is sorted.
+type Shape struct {
+	Known      []string // fields of the schema that are present
+	Unknown    []string // fields the schema doesn't have, not counting their own fields
+	Missing    []string // fields of the schema that are absent
+	Violations []string // Required fields that are absent, and NonZero fields that are absent or zero
+}
+
+// Inspect compares a JSON response body with s. A field only counts as
+// missing when the object or array holding it is there, so the content
+// fields of an empty page are neither missing nor violations.
+func (s Schema) Inspect(body []byte) (Shape, error) {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return Shape{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	in := inspection{
+		schema:  s,
+		fields:  map[string]bool{},
+		unknown: map[string]bool{},
+		arrays:  map[string]bool{},
+	}
+	in.walk("", v)
+
+	var shape Shape
+	for path, field := range s {
+		nonZero, present := in.fields[path]
+		switch {
+		case present:
+			shape.Known = append(shape.Known, path)
+		case in.holds(path):
+			shape.Missing = append(shape.Missing, path)
+		default:
+			continue
+		}
+		if (field == Required && !present) || (field == NonZero && !nonZero) {
+			shape.Violations = append(shape.Violations, path)
+		}
+	}
+	for path := range in.unknown {
+		shape.Unknown = append(shape.Unknown, path)
+	}
+
+	slices.Sort(shape.Known)
+	slices.Sort(shape.Unknown)
+	slices.Sort(shape.Missing)
+	slices.Sort(shape.Violations)
+	return shape, nil
+}
+
+// inspection is the state of Schema.Inspect's walk over a response
+type inspection struct {
+	schema  Schema
+	fields  map[string]bool // fields present, true when non-zero in some object
+	unknown map[string]bool
+	arrays  map[string]bool // arrays with elements, e.g. "content[]"
+}
+
+func (in *inspection) walk(path string, v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, child := range v {
+			p := key
+			if path != "" {
+				p = path + "." + key
+			}
+			if _, ok := in.schema[p]; !ok {
+				in.unknown[p] = true
+				continue
+			}
+			in.fields[p] = in.fields[p] || !isZero(child)
+			in.walk(p, child)
+		}
+	case []any:
+		if len(v) > 0 {
+			in.arrays[path+"[]"] = true
+		}
+		for _, elem := range v {
+			in.walk(path+"[]", elem)
+		}
+	}
+}
+
+// holds reports whether the response has the object or array elements the
+// field at path belongs to
+func (in *inspection) holds(path string) bool {
+	i := strings.LastIndex(path, ".")
+	if i < 0 {
+		return true
+	}
+	parent := path[:i]
+	if strings.HasSuffix(parent, "[]") {
+		return in.arrays[parent]
+	}
+	_, ok := in.fields[parent]
+	return ok
+}
+
+// isZero reports whether a decoded JSON value is null, false, 0, "" or empty
+func isZero(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		return v == ""
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
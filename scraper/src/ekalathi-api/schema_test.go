@@ -0,0 +1,156 @@
+package ekalathiapi
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// jsonFields lists the field paths a type decodes, in the notation of Schema
+func jsonFields(t reflect.Type, path string, fields map[string]bool) {
+	switch t.Kind() {
+	case reflect.Slice:
+		jsonFields(t.Elem(), path+"[]", fields)
+	case reflect.Struct:
+		for i := range t.NumField() {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if f.Anonymous && name == "" {
+				jsonFields(f.Type, path, fields)
+				continue
+			}
+			p := name
+			if path != "" {
+				p = path + "." + name
+			}
+			fields[p] = true
+			jsonFields(f.Type, p, fields)
+		}
+	}
+}
+
+func TestSchemasMatchTypes(t *testing.T) {
+	types := map[string]any{
+		CategoriesEndpoint:     []CategoryResponse{},
+		RegionsEndpoint:        []RegionResponse{},
+		CompaniesEndpoint:      []CompanyResponse{},
+		ProductsEndpoint:       ProductListResponse{},
+		ProductEndpoint:        ProductHistoryResponse{},
+		RetailBranchesEndpoint: RetailBranchListResponse{},
+	}
+	if len(types) != len(Schemas) {
+		t.Errorf("%d schemas, want one for each of the %d decoded types", len(Schemas), len(types))
+	}
+
+	for endpoint, v := range types {
+		fields := map[string]bool{}
+		jsonFields(reflect.TypeOf(v), "", fields)
+		for path := range fields {
+			if _, ok := Schemas[endpoint][path]; !ok {
+				t.Errorf("%s: the type decodes %q, which the schema doesn't pin", endpoint, path)
+			}
+		}
+		for path := range Schemas[endpoint] {
+			if !fields[path] {
+				t.Errorf("%s: the schema pins %q, which the type doesn't decode", endpoint, path)
+			}
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	branches := Schemas[RetailBranchesEndpoint]
+	tests := []struct {
+		name           string
+		schema         Schema
+		body           string
+		wantUnknown    []string
+		wantMissing    []string
+		wantViolations []string
+	}{
+		{
+			name:   "matches",
+			schema: Schemas[RegionsEndpoint],
+			body:   `[{"id": 1, "name": "Nicosia"}, {"id": 2, "name": "Limassol"}]`,
+		},
+		{
+			name:        "unknown fields are listed without their own fields",
+			schema:      Schemas[RegionsEndpoint],
+			body:        `[{"id": 1, "name": "Nicosia", "district": {"code": "NIC"}}]`,
+			wantUnknown: []string{"[].district"},
+		},
+		{
+			name:           "renamed price",
+			schema:         branches,
+			body:           `{"content": [{"id": 1, "name": "A1", "companyName": "Alpha", "price": 1.2}], "last": true}`,
+			wantUnknown:    []string{"content[].price"},
+			wantMissing:    missingBranchFields("retailerProductPrice"),
+			wantViolations: []string{"content[].retailerProductPrice"},
+		},
+		{
+			name:   "zero on one branch only",
+			schema: branches,
+			body: `{"content": [
+				{"id": 1, "name": "A1", "companyName": "Alpha", "retailerProductPrice": 0},
+				{"id": 2, "name": "B1", "companyName": "Beta", "retailerProductPrice": 1.2}
+			], "last": true}`,
+			wantMissing: missingBranchFields(),
+		},
+		{
+			name:   "zero on every branch",
+			schema: branches,
+			body: `{"content": [
+				{"id": 1, "name": "A1", "companyName": "Alpha", "retailerProductPrice": 0},
+				{"id": 2, "name": "B1", "companyName": "Beta", "retailerProductPrice": null}
+			], "last": true}`,
+			wantMissing:    missingBranchFields(),
+			wantViolations: []string{"content[].retailerProductPrice"},
+		},
+		{
+			name:           "empty page",
+			schema:         branches,
+			body:           `{"content": []}`,
+			wantMissing:    []string{"empty", "first", "last", "totalElements", "totalPages"},
+			wantViolations: []string{"last"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shape, err := tt.schema.Inspect([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("Inspect() error = %v", err)
+			}
+			if !slices.Equal(shape.Unknown, tt.wantUnknown) {
+				t.Errorf("unknown = %v, want %v", shape.Unknown, tt.wantUnknown)
+			}
+			if !slices.Equal(shape.Missing, tt.wantMissing) {
+				t.Errorf("missing = %v, want %v", shape.Missing, tt.wantMissing)
+			}
+			if !slices.Equal(shape.Violations, tt.wantViolations) {
+				t.Errorf("violations = %v, want %v", shape.Violations, tt.wantViolations)
+			}
+			if len(shape.Known)+len(shape.Missing) > len(tt.schema) {
+				t.Errorf("%d known and %d missing fields, more than the schema has", len(shape.Known), len(shape.Missing))
+			}
+		})
+	}
+
+	if _, err := branches.Inspect([]byte("{")); err == nil {
+		t.Error("Inspect() of invalid JSON succeeded")
+	}
+}
+
+// missingBranchFields lists the fields of a branch page the tests leave
+// out: every optional branch field, plus extra
+func missingBranchFields(extra ...string) []string {
+	missing := []string{"empty", "first", "totalElements", "totalPages"}
+	for path, field := range Schemas[RetailBranchesEndpoint] {
+		if rest, ok := strings.CutPrefix(path, "content[]."); ok && (field == Optional || slices.Contains(extra, rest)) {
+			missing = append(missing, path)
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
@@ -134,6 +134,13 @@ type ProductDetailsResponse struct {
 	ImageURL           string         `json:"imageUrl"`
 }
 
+// ProductHistoryResponse is the subset of fetch-product used for backfilling:
+// the listing fields (start and previous price) plus the price history
+type ProductHistoryResponse struct {
+	Product
+	PriceHistory []PriceHistory `json:"priceHistory"`
+}
+
 type RetailerResponse struct {
 	ID       int     `json:"id"`
 	Name     string  `json:"name"`
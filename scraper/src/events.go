@@ -0,0 +1,67 @@
+package main
+
+import "time"
+
+// Events recorded through the metrics collector, see metrics.RecordEvent
+const (
+	eventRunStarted      = "run_started"
+	eventRunCompleted    = "run_completed"
+	eventPhaseCompleted  = "phase_completed"
+	eventCategoryFailed  = "category_failed"
+	eventAnomalyDetected = "anomaly_detected"
+)
+
+// anomalyFailureRate is the share of failed items in a phase above which an
+// anomaly is reported
+const anomalyFailureRate = 0.1
+
+// event records an event, logging rather than failing the run if it cannot
+// be encoded. Anomalies found during a dry run also go into its summary.
+func (s *Scraper) event(name string, properties map[string]interface{}) {
+	if s.dryRun != nil && name == eventAnomalyDetected {
+		s.dryRun.recordAnomaly(properties)
+	}
+	if s.metrics == nil {
+		return
+	}
+	if err := s.metrics.RecordEvent(name, properties); err != nil {
+		s.logger.Warn("failed to record event", "event", name, "error", err)
+	}
+}
+
+// count records a count metric. Scrapers built for one-off commands such as
+// regions have no collector, and count nothing.
+func (s *Scraper) count(name string, n int, tags map[string]string) {
+	if s.metrics == nil {
+		return
+	}
+	s.metrics.RecordCount(name, n, tags)
+}
+
+// phaseCompleted records the end of a phase with how long it took and the
+// items it processed and failed, as counted by progress
+func (s *Scraper) phaseCompleted(phase string, start time.Time) {
+	snap := s.progress.Snapshot()
+	s.event(eventPhaseCompleted, map[string]interface{}{
+		"phase":       phase,
+		"duration_ms": time.Since(start).Milliseconds(),
+		"items":       snap.Done,
+		"failed":      snap.Failed,
+	})
+}
+
+// checkFailureRate reports an anomaly when too many of a phase's items
+// failed after all retries
+func (s *Scraper) checkFailureRate(phase string, failed, total int) {
+	if total == 0 || float64(failed)/float64(total) <= anomalyFailureRate {
+		return
+	}
+	s.logger.Warn("high failure rate", "phase", phase, "failed", failed, "total", total)
+	s.event(eventAnomalyDetected, map[string]interface{}{
+		"kind":   "high_failure_rate",
+		"phase":  phase,
+		"failed": failed,
+		"total":  total,
+		"rate":   float64(failed) / float64(total),
+	})
+}
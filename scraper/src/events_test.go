@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/metrics"
+)
+
+func TestCheckFailureRate(t *testing.T) {
+	tests := []struct {
+		name          string
+		failed, total int
+		wantAnomaly   bool
+	}{
+		{"nothing to do", 0, 0, false},
+		{"no failures", 0, 100, false},
+		{"at the threshold", 10, 100, false},
+		{"above the threshold", 11, 100, true},
+		{"everything failed", 5, 5, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scraper{metrics: metrics.NewWithOptions(metrics.Options{}), progress: NewProgress(), logger: slog.New(slog.DiscardHandler)}
+			s.checkFailureRate(phasePrices, tt.failed, tt.total)
+
+			rec := httptest.NewRecorder()
+			s.metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+			got := strings.Contains(rec.Body.String(), `scraper_events_total{event="anomaly_detected"} 1`)
+			if got != tt.wantAnomaly {
+				t.Errorf("anomaly recorded = %v, want %v", got, tt.wantAnomaly)
+			}
+		})
+	}
+}
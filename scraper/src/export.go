@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/export"
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+)
+
+// runExport writes the prices in the database to CSV and Parquet files
+// partitioned by day, for publishing as open data
+func runExport(ctx context.Context, dbURL string, logger *slog.Logger, args []string) error {
+	fs := newFlagSet("export")
+	dir := fs.String("out", "export", "directory to write the dataset to")
+	var formats, chains stringList
+	var categories intList
+	fs.Var(&formats, "format", "formats to write, comma-separated or repeated (csv, parquet; default both)")
+	from := fs.String("from", "", "first day to export, YYYY-MM-DD (UTC)")
+	to := fs.String("to", "", "last day to export, YYYY-MM-DD (UTC)")
+	fs.Var(&categories, "category", "only export these eKalathi category IDs (and their subcategories)")
+	fs.Var(&chains, "chain", "only export prices at these chains")
+	incremental := fs.Bool("incremental", false, "continue the export in --out from where it stopped")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("export takes no arguments, got %v", fs.Args())
+	}
+
+	opts := export.Options{
+		Dir:         *dir,
+		Formats:     export.Formats,
+		Filter:      store.PriceFilter{Categories: categories, Chains: chains},
+		Incremental: *incremental,
+	}
+	if len(formats) > 0 {
+		opts.Formats = nil
+		for _, name := range formats {
+			format, err := export.ParseFormat(strings.ToLower(name))
+			if err != nil {
+				return err
+			}
+			opts.Formats = append(opts.Formats, format)
+		}
+	}
+
+	var err error
+	if opts.Filter.From, err = parseDay("from", *from); err != nil {
+		return err
+	}
+	if opts.Filter.Until, err = parseDay("to", *to); err != nil {
+		return err
+	}
+	if !opts.Filter.Until.IsZero() {
+		// --to is inclusive
+		opts.Filter.Until = opts.Filter.Until.AddDate(0, 0, 1)
+		if !opts.Filter.From.Before(opts.Filter.Until) {
+			return fmt.Errorf("--from %s is after --to %s", *from, *to)
+		}
+	}
+
+	if dbURL == "" {
+		return errDatabaseURL
+	}
+	db, err := store.Open(ctx, dbURL, store.Options{})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := export.Run(ctx, db, opts)
+	if err != nil {
+		return fmt.Errorf("failed to export prices: %w", err)
+	}
+
+	logger.Info("exported prices",
+		"dir", *dir,
+		"rows", result.Rows,
+		"files", len(result.Files),
+		"from", result.From,
+		"watermark", result.Until,
+	)
+	return nil
+}
+
+// parseDay parses a YYYY-MM-DD flag value as the start of that UTC day; an
+// empty value is the zero time
+func parseDay(flag, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --%s %q, want YYYY-MM-DD", flag, value)
+	}
+	return day, nil
+}
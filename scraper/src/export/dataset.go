@@ -0,0 +1,196 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/zstd"
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+)
+
+// dataset writes rows to one file per format and day, at
+// <dir>/<format>/date=<day>/<part>.<format>. Files are written under hidden
+// temporary names and only moved into place by commit.
+type dataset struct {
+	dir     string
+	formats []Format
+	part    string
+
+	day     string // partition being written
+	writers []fileWriter
+	pending []pendingFile
+	rows    int
+}
+
+// pendingFile is a finished file still under its temporary name
+type pendingFile struct {
+	tmp, path string
+}
+
+// fileWriter writes the rows of one file
+type fileWriter interface {
+	Write(row Row) error
+	Close() error
+}
+
+func newDataset(dir string, formats []Format, part string) *dataset {
+	return &dataset{dir: dir, formats: formats, part: part}
+}
+
+// write adds a price, starting the files of its day when it is the first.
+// Prices must come in scrape time order.
+func (d *dataset) write(r store.PriceRecord) error {
+	row := rowOf(r)
+	day := row.ScrapedAt.Format(time.DateOnly)
+	if day < d.day {
+		return fmt.Errorf("prices out of order: %s after %s", day, d.day)
+	}
+	if day != d.day {
+		if err := d.closeDay(); err != nil {
+			return err
+		}
+		if err := d.openDay(day); err != nil {
+			return err
+		}
+	}
+
+	for _, w := range d.writers {
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("failed to write %s: %w", day, err)
+		}
+	}
+	d.rows++
+	return nil
+}
+
+func (d *dataset) openDay(day string) error {
+	d.day = day
+	for _, format := range d.formats {
+		name := d.part + "." + string(format)
+		path := filepath.Join(string(format), "date="+day, name)
+		tmp := filepath.Join(string(format), "date="+day, "."+name+".tmp")
+		// Recorded first so abort removes the file even if opening fails
+		d.pending = append(d.pending, pendingFile{tmp: tmp, path: path})
+
+		w, err := openWriter(format, filepath.Join(d.dir, tmp))
+		if err != nil {
+			return err
+		}
+		d.writers = append(d.writers, w)
+	}
+	return nil
+}
+
+func (d *dataset) closeDay() error {
+	writers := d.writers
+	d.writers = nil
+	for _, w := range writers {
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to finish %s: %w", d.day, err)
+		}
+	}
+	return nil
+}
+
+// commit finishes the open files and moves every file into place, returning
+// their paths relative to the export directory
+func (d *dataset) commit() ([]string, error) {
+	if err := d.closeDay(); err != nil {
+		d.abort()
+		return nil, err
+	}
+
+	var paths []string
+	for _, f := range d.pending {
+		if err := os.Rename(filepath.Join(d.dir, f.tmp), filepath.Join(d.dir, f.path)); err != nil {
+			d.abort()
+			return paths, fmt.Errorf("failed to publish %s: %w", f.path, err)
+		}
+		paths = append(paths, f.path)
+	}
+	return paths, nil
+}
+
+// abort closes and removes the files not yet moved into place
+func (d *dataset) abort() {
+	for _, w := range d.writers {
+		w.Close()
+	}
+	d.writers = nil
+	for _, f := range d.pending {
+		os.Remove(filepath.Join(d.dir, f.tmp))
+	}
+}
+
+// openWriter creates a file of a format, along with its directory
+func openWriter(format Format, path string) (fileWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	switch format {
+	case CSV:
+		w := &csvWriter{f: f, w: csv.NewWriter(f)}
+		if err := w.w.Write(csvHeader); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return w, nil
+	case Parquet:
+		return &parquetWriter{
+			f: f,
+			w: parquet.NewGenericWriter[Row](f,
+				parquet.Compression(&zstd.Codec{}),
+				parquet.CreatedBy("cy-price-watchdog", "", ""),
+			),
+		}, nil
+	}
+	f.Close()
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// --- File Writers ---
+
+type csvWriter struct {
+	f *os.File
+	w *csv.Writer
+}
+
+func (c *csvWriter) Write(row Row) error {
+	return c.w.Write(row.csvRecord())
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	if err := c.w.Error(); err != nil {
+		c.f.Close()
+		return err
+	}
+	return c.f.Close()
+}
+
+type parquetWriter struct {
+	f *os.File
+	w *parquet.GenericWriter[Row]
+}
+
+func (p *parquetWriter) Write(row Row) error {
+	_, err := p.w.Write([]Row{row})
+	return err
+}
+
+func (p *parquetWriter) Close() error {
+	if err := p.w.Close(); err != nil {
+		p.f.Close()
+		return err
+	}
+	return p.f.Close()
+}
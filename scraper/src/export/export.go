@@ -0,0 +1,119 @@
+// Package export publishes the price archive as open data: CSV and Parquet
+// files partitioned by the UTC day prices were scraped, with a manifest that
+// lets the next export continue where the last one stopped.
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+)
+
+// Format is an output file format
+type Format string
+
+const (
+	CSV     Format = "csv"
+	Parquet Format = "parquet"
+)
+
+// Formats lists every supported format
+var Formats = []Format{CSV, Parquet}
+
+// ParseFormat returns the format with a name
+func ParseFormat(name string) (Format, error) {
+	if f := Format(name); slices.Contains(Formats, f) {
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown export format %q (want csv or parquet)", name)
+}
+
+// Settle is how far behind the current time an export stops, so that prices
+// a running scrape is still writing are left for the next export
+const Settle = time.Minute
+
+// Options configures an export
+type Options struct {
+	Dir     string
+	Formats []Format
+	Filter  store.PriceFilter
+	// Incremental continues the export already in Dir, starting from its
+	// watermark. Without it Dir must not hold an export yet.
+	Incremental bool
+	Now         time.Time // defaults to the current time
+}
+
+// Result summarizes a finished export
+type Result struct {
+	From  time.Time // zero when the export starts at the first price
+	Until time.Time // the new watermark
+	Rows  int
+	Files []string // relative to the export directory
+}
+
+// Run exports the prices matching opts.Filter that were scraped before the
+// watermark it sets, writing the files and then the manifest. Nothing a
+// reader would pick up is left behind when it fails.
+func Run(ctx context.Context, db store.Store, opts Options) (Result, error) {
+	if len(opts.Formats) == 0 {
+		return Result{}, errors.New("no export formats given")
+	}
+	opts.Formats = slices.Compact(slices.Sorted(slices.Values(opts.Formats)))
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	previous, err := readManifest(opts.Dir)
+	if err != nil {
+		return Result{}, err
+	}
+
+	filter := opts.Filter
+	start := filter.From
+	switch {
+	case previous != nil && !opts.Incremental:
+		return Result{}, fmt.Errorf("%s already holds an export; pass --incremental to continue it, or export to an empty directory", opts.Dir)
+	case previous != nil:
+		if err := previous.check(opts.Formats, filter); err != nil {
+			return Result{}, fmt.Errorf("cannot continue the export in %s: %w", opts.Dir, err)
+		}
+		if filter.From.Before(previous.Watermark) {
+			filter.From = previous.Watermark
+		}
+		start = previous.From
+	}
+
+	until := opts.Now.UTC().Add(-Settle).Truncate(time.Millisecond)
+	if filter.Until.IsZero() || until.Before(filter.Until) {
+		filter.Until = until
+	}
+	result := Result{From: filter.From, Until: filter.Until}
+	if !filter.From.IsZero() && !filter.From.Before(filter.Until) {
+		return result, nil
+	}
+
+	data := newDataset(opts.Dir, opts.Formats, "part-"+filter.Until.Format("20060102T150405.000Z"))
+	if err := db.ExportPrices(ctx, filter, data.write); err != nil {
+		data.abort()
+		return result, err
+	}
+	if result.Files, err = data.commit(); err != nil {
+		return result, err
+	}
+	result.Rows = data.rows
+
+	err = writeManifest(opts.Dir, manifest{
+		From:       start,
+		Watermark:  filter.Until,
+		ExportedAt: opts.Now.UTC(),
+		Rows:       data.rows,
+		Formats:    opts.Formats,
+		Categories: filter.Categories,
+		Chains:     filter.Chains,
+	})
+	return result, err
+}
@@ -0,0 +1,238 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+)
+
+// seedStore returns a store with milk at two stores of different chains
+func seedStore(t *testing.T) (*store.Memory, func(price float64, storeExtID int, at time.Time)) {
+	t.Helper()
+	ctx := context.Background()
+	db := store.NewMemory()
+
+	dairy, _ := db.UpsertCategory(ctx, store.Category{ExternalID: 1, Code: "D", Name: "Γαλακτοκομικά", NameEnglish: "Dairy"})
+	milk, _ := db.UpsertCategory(ctx, store.Category{ExternalID: 2, Code: "D1", Name: "Γάλα", NameEnglish: "Milk", ParentID: &dairy})
+	product, err := db.UpsertProduct(ctx, store.Product{ExternalID: 10, Code: "P10", Name: "Γάλα 1L", NameEnglish: "Milk 1L", CategoryID: milk})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.UpsertStore(ctx, store.Branch{ExternalID: 100, Name: "A1", Chain: "Alpha", District: "Nicosia", Location: "Makariou 1, Nicosia"})
+	db.UpsertStore(ctx, store.Branch{ExternalID: 200, Name: "B1", Chain: "Beta", District: "Limassol"})
+
+	insert := func(price float64, storeExtID int, at time.Time) {
+		storeID, _ := db.LookupID(ctx, store.KindStore, storeExtID)
+		if err := db.InsertPrice(ctx, store.Price{ProductID: product, StoreID: storeID, Price: price, ScrapedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db, insert
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	day := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	db, insert := seedStore(t)
+	insert(1.2, 100, day)
+	insert(1.35, 200, day.Add(time.Hour))
+	insert(1.25, 100, day.AddDate(0, 0, 1))
+
+	opts := Options{Dir: dir, Formats: []Format{Parquet, CSV}, Now: day.AddDate(0, 0, 2)}
+	result, err := Run(ctx, db, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	part := "part-20260303T075900.000Z"
+	wantFiles := []string{
+		"csv/date=2026-03-01/" + part + ".csv",
+		"parquet/date=2026-03-01/" + part + ".parquet",
+		"csv/date=2026-03-02/" + part + ".csv",
+		"parquet/date=2026-03-02/" + part + ".parquet",
+	}
+	if result.Rows != 3 || !slices.Equal(result.Files, wantFiles) {
+		t.Errorf("Run() = %d rows in %v, want 3 rows in %v", result.Rows, result.Files, wantFiles)
+	}
+
+	wantCSV := strings.Join([]string{
+		strings.Join(csvHeader, ","),
+		"2026-03-01T08:00:00.000Z,1.20,10,P10,Γάλα 1L,Milk 1L,2,D1,Γάλα,Milk,1,Γαλακτοκομικά,Dairy,100,A1,Alpha,Nicosia,\"Makariou 1, Nicosia\"",
+		"2026-03-01T09:00:00.000Z,1.35,10,P10,Γάλα 1L,Milk 1L,2,D1,Γάλα,Milk,1,Γαλακτοκομικά,Dairy,200,B1,Beta,Limassol,",
+		"",
+	}, "\n")
+	if got := readFile(t, filepath.Join(dir, wantFiles[0])); got != wantCSV {
+		t.Errorf("CSV =\n%s\nwant\n%s", got, wantCSV)
+	}
+
+	rows, err := parquet.ReadFile[Row](filepath.Join(dir, wantFiles[3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Price != 1.25 || rows[0].StoreID != 100 || !rows[0].ScrapedAt.Equal(day.AddDate(0, 0, 1)) ||
+		rows[0].ParentCategoryID == nil || *rows[0].ParentCategoryID != 1 {
+		t.Errorf("Parquet rows = %+v, want the 1.25 price at A1 under category 1", rows)
+	}
+
+	// An export into the same directory has to continue it
+	if _, err := Run(ctx, db, opts); err == nil {
+		t.Error("a second full export into the same directory should fail")
+	}
+
+	insert(1.3, 200, day.AddDate(0, 0, 2).Add(-30*time.Second)) // not settled yet
+	insert(1.4, 200, day.AddDate(0, 0, 2).Add(time.Hour))
+
+	opts.Incremental = true
+	opts.Now = day.AddDate(0, 0, 3)
+	result, err = Run(ctx, db, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != 2 || !result.From.Equal(day.AddDate(0, 0, 2).Add(-Settle)) || len(result.Files) != 2 {
+		t.Errorf("incremental Run() = %+v, want the 2 prices since the last watermark", result)
+	}
+	if got := readFile(t, filepath.Join(dir, "csv/date=2026-03-03/part-20260304T075900.000Z.csv")); strings.Count(got, "\n") != 3 {
+		t.Errorf("incremental CSV =\n%s\nwant a header and 2 rows", got)
+	}
+
+	m, err := readManifest(dir)
+	if err != nil || m == nil || !m.Watermark.Equal(day.AddDate(0, 0, 3).Add(-Settle)) || m.Rows != 2 {
+		t.Errorf("manifest = %+v, %v, want the new watermark and 2 rows", m, err)
+	}
+
+	opts.Filter.Chains = []string{"Alpha"}
+	if _, err := Run(ctx, db, opts); err == nil || !strings.Contains(err.Error(), "chains") {
+		t.Errorf("continuing with another chain filter error = %v, want a chains mismatch", err)
+	}
+}
+
+func TestRunFilter(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	day := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	db, insert := seedStore(t)
+	insert(1.2, 100, day)
+	insert(1.35, 200, day)
+	insert(1.25, 100, day.AddDate(0, 0, 1))
+
+	result, err := Run(ctx, db, Options{
+		Dir:     dir,
+		Formats: []Format{CSV},
+		Filter:  store.PriceFilter{From: day.AddDate(0, 0, 1), Chains: []string{"Alpha"}},
+		Now:     day.AddDate(0, 0, 2),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != 1 || len(result.Files) != 1 || !strings.Contains(result.Files[0], "date=2026-03-02") {
+		t.Errorf("Run() = %+v, want the one Alpha price on 2026-03-02", result)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "parquet")); !os.IsNotExist(err) {
+		t.Errorf("a CSV export wrote Parquet files: %v", err)
+	}
+}
+
+func TestRunIncrementalFrom(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	db, insert := seedStore(t)
+	for i := range 6 {
+		insert(1.2, 100, day.AddDate(0, 0, i))
+	}
+
+	tests := []struct {
+		name     string
+		from     time.Time // of the incremental export
+		wantErr  string
+		wantRows int
+	}{
+		{name: "from the watermark", wantRows: 2},
+		{name: "from before the watermark", from: day.AddDate(0, 0, 2), wantRows: 2},
+		{name: "from before the first export", from: day, wantErr: "it starts at"},
+		{name: "from after the watermark", from: day.AddDate(0, 0, 4), wantErr: "never be exported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := Options{Dir: dir, Formats: []Format{CSV}, Filter: store.PriceFilter{From: day.AddDate(0, 0, 1)}, Now: day.AddDate(0, 0, 3)}
+			if result, err := Run(ctx, db, opts); err != nil || result.Rows != 2 {
+				t.Fatalf("first Run() = %+v, %v, want 2 rows", result, err)
+			}
+			if m, _ := readManifest(dir); m == nil || !m.From.Equal(day.AddDate(0, 0, 1)) {
+				t.Fatalf("manifest = %+v, want it to record the first export's start", m)
+			}
+
+			opts.Incremental = true
+			opts.Filter.From = tt.from
+			opts.Now = day.AddDate(0, 0, 5)
+			result, err := Run(ctx, db, opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Run() error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				if m, _ := readManifest(dir); m == nil || !m.Watermark.Equal(day.AddDate(0, 0, 3).Add(-Settle)) {
+					t.Errorf("manifest = %+v, want the watermark left where it was", m)
+				}
+				return
+			}
+			if err != nil || result.Rows != tt.wantRows {
+				t.Fatalf("incremental Run() = %+v, %v, want %d rows", result, err, tt.wantRows)
+			}
+			if m, _ := readManifest(dir); m == nil || !m.From.Equal(day.AddDate(0, 0, 1)) {
+				t.Errorf("manifest = %+v, want the first export's start kept", m)
+			}
+		})
+	}
+}
+
+// failingStore fails exports after passing on every price
+type failingStore struct {
+	store.Store
+}
+
+func (f failingStore) ExportPrices(ctx context.Context, filter store.PriceFilter, fn func(store.PriceRecord) error) error {
+	if err := f.Store.ExportPrices(ctx, filter, fn); err != nil {
+		return err
+	}
+	return errors.New("connection reset")
+}
+
+func TestRunFailure(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	db, insert := seedStore(t)
+	insert(1.2, 100, day)
+
+	if _, err := Run(context.Background(), failingStore{db}, Options{Dir: dir, Formats: Formats, Now: day.AddDate(0, 0, 1)}); err == nil {
+		t.Fatal("Run() should fail when the store does")
+	}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			t.Errorf("a failed export left %s behind", path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,100 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+)
+
+// manifestName is the file in the export directory describing the last
+// export. Dataset readers skip files starting with an underscore.
+const manifestName = "_export.json"
+
+// manifest records how far an export directory is complete
+type manifest struct {
+	// From is the scrape time the first export started at, zero when it
+	// started at the first price
+	From time.Time `json:"from,omitzero"`
+	// Watermark is the scrape time the last export stopped before; every
+	// matching price scraped from From until then has been exported
+	Watermark  time.Time `json:"watermark"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Rows       int       `json:"rows"`
+	Formats    []Format  `json:"formats"`
+	Categories []int     `json:"categories,omitempty"`
+	Chains     []string  `json:"chains,omitempty"`
+}
+
+// readManifest returns the manifest in dir, or nil when there is none
+func readManifest(dir string) (*manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export manifest: %w", err)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse export manifest %s: %w", filepath.Join(dir, manifestName), err)
+	}
+	return &m, nil
+}
+
+// writeManifest replaces the manifest in dir
+func writeManifest(dir string, m manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+	tmp := filepath.Join(dir, "."+manifestName+".tmp")
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write export manifest: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, manifestName)); err != nil {
+		return fmt.Errorf("failed to write export manifest: %w", err)
+	}
+	return nil
+}
+
+// check reports whether an export with formats and filter continues the one
+// the manifest describes. Different formats or filters would leave earlier
+// days incomplete, starting before From would leave them missing, and
+// starting after the watermark would skip the prices in between for good.
+func (m *manifest) check(formats []Format, filter store.PriceFilter) error {
+	if !filter.From.IsZero() && filter.From.Before(m.From) {
+		return fmt.Errorf("it starts at %s, after %s", m.From.Format(time.RFC3339), filter.From.Format(time.RFC3339))
+	}
+	if filter.From.After(m.Watermark) {
+		return fmt.Errorf("its watermark %s is before %s, so the prices in between would never be exported", m.Watermark.Format(time.RFC3339), filter.From.Format(time.RFC3339))
+	}
+	if !sameSet(m.Formats, formats) {
+		return fmt.Errorf("it has formats %v, not %v", m.Formats, formats)
+	}
+	if !sameSet(m.Categories, filter.Categories) {
+		return fmt.Errorf("it has categories %v, not %v", m.Categories, filter.Categories)
+	}
+	if !sameSet(m.Chains, filter.Chains) {
+		return fmt.Errorf("it has chains %q, not %q", m.Chains, filter.Chains)
+	}
+	return nil
+}
+
+// sameSet reports whether a and b hold the same values, in any order
+func sameSet[T interface{ ~int | ~string }](a, b []T) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
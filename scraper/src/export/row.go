@@ -0,0 +1,94 @@
+package export
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+)
+
+// Row is a price as published. IDs are eKalathi's, which stay the same
+// across databases; the day is the partition, not a column.
+type Row struct {
+	ScrapedAt                 time.Time `parquet:"scraped_at,timestamp(millisecond)"`
+	Price                     float64   `parquet:"price"`
+	ProductID                 int64     `parquet:"product_id"`
+	ProductCode               string    `parquet:"product_code,dict"`
+	ProductName               string    `parquet:"product_name,dict"`
+	ProductNameEnglish        string    `parquet:"product_name_en,dict"`
+	CategoryID                int64     `parquet:"category_id"`
+	CategoryCode              string    `parquet:"category_code,dict"`
+	CategoryName              string    `parquet:"category_name,dict"`
+	CategoryNameEnglish       string    `parquet:"category_name_en,dict"`
+	ParentCategoryID          *int64    `parquet:"parent_category_id,optional"`
+	ParentCategoryName        *string   `parquet:"parent_category_name,optional,dict"`
+	ParentCategoryNameEnglish *string   `parquet:"parent_category_name_en,optional,dict"`
+	StoreID                   int64     `parquet:"store_id"`
+	StoreName                 string    `parquet:"store_name,dict"`
+	Chain                     string    `parquet:"chain,dict"`
+	District                  string    `parquet:"district,dict"`
+	Location                  string    `parquet:"location,dict"`
+}
+
+// csvHeader names the CSV columns, in the same order and with the same names
+// as the Parquet columns
+var csvHeader = []string{
+	"scraped_at", "price",
+	"product_id", "product_code", "product_name", "product_name_en",
+	"category_id", "category_code", "category_name", "category_name_en",
+	"parent_category_id", "parent_category_name", "parent_category_name_en",
+	"store_id", "store_name", "chain", "district", "location",
+}
+
+// csvTimeLayout formats scraped_at in CSV files, in UTC
+const csvTimeLayout = "2006-01-02T15:04:05.000Z"
+
+func rowOf(r store.PriceRecord) Row {
+	row := Row{
+		ScrapedAt:                 r.ScrapedAt.UTC(),
+		Price:                     r.Price,
+		ProductID:                 int64(r.ProductExternalID),
+		ProductCode:               r.ProductCode,
+		ProductName:               r.ProductName,
+		ProductNameEnglish:        r.ProductNameEnglish,
+		CategoryID:                int64(r.CategoryExternalID),
+		CategoryCode:              r.CategoryCode,
+		CategoryName:              r.CategoryName,
+		CategoryNameEnglish:       r.CategoryNameEnglish,
+		ParentCategoryName:        r.ParentCategoryName,
+		ParentCategoryNameEnglish: r.ParentCategoryNameEnglish,
+		StoreID:                   int64(r.StoreExternalID),
+		StoreName:                 r.StoreName,
+		Chain:                     r.Chain,
+		District:                  r.District,
+		Location:                  r.Location,
+	}
+	if r.ParentCategoryExternalID != nil {
+		id := int64(*r.ParentCategoryExternalID)
+		row.ParentCategoryID = &id
+	}
+	return row
+}
+
+// csvRecord formats a row for a CSV file; missing parent category fields are
+// empty
+func (r Row) csvRecord() []string {
+	optional := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	parentID := ""
+	if r.ParentCategoryID != nil {
+		parentID = strconv.FormatInt(*r.ParentCategoryID, 10)
+	}
+
+	return []string{
+		r.ScrapedAt.Format(csvTimeLayout), strconv.FormatFloat(r.Price, 'f', 2, 64),
+		strconv.FormatInt(r.ProductID, 10), r.ProductCode, r.ProductName, r.ProductNameEnglish,
+		strconv.FormatInt(r.CategoryID, 10), r.CategoryCode, r.CategoryName, r.CategoryNameEnglish,
+		parentID, optional(r.ParentCategoryName), optional(r.ParentCategoryNameEnglish),
+		strconv.FormatInt(r.StoreID, 10), r.StoreName, r.Chain, r.District, r.Location,
+	}
+}
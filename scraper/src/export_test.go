@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRunExportFlags(t *testing.T) {
+	tests := []struct {
+		args    []string
+		wantErr string
+	}{
+		{[]string{"--format=xlsx"}, "unknown export format"},
+		{[]string{"--from=01/03/2026"}, "invalid --from"},
+		{[]string{"--from=2026-03-02", "--to=2026-03-01"}, "after --to"},
+		{[]string{"--from=2026-03-01", "--to=2026-03-01"}, "database URL is required"},
+		{[]string{"extra"}, "takes no arguments"},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			err := runExport(context.Background(), "", slog.New(slog.DiscardHandler), tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("runExport(%v) error = %v, want %q", tt.args, err, tt.wantErr)
+			}
+		})
+	}
+}
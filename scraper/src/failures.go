@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+)
+
+// --- Failure Methods ---
+
+// recordFailure stores a work item that still failed after all retries, so
+// it can be retried without a full run. Errors are only logged since the
+// failure itself has already been reported. A dry run stores nothing, and
+// neither does a replay: the archived run recorded its own failures.
+func (s *Scraper) recordFailure(ctx context.Context, f store.Failure) {
+	if s.dryRun != nil || s.replay != nil {
+		return
+	}
+
+	if err := s.db.RecordFailure(ctx, f); err != nil {
+		s.logger.Error("failed to record scrape failure", "phase", f.Phase, "error", err)
+	}
+}
+
+func (s *Scraper) resolveFailures(ctx context.Context, failures []store.Failure) error {
+	ids := make([]string, len(failures))
+	for i, f := range failures {
+		ids[i] = f.ID
+	}
+	return s.db.ResolveFailures(ctx, ids)
+}
+
+// RetryFailed re-scrapes the categories and product-region pairs that failed
+// in earlier runs. Items that fail again are recorded as new failures. The
+// earlier failures of each phase are resolved once its retry has finished, so
+// a retry that is cancelled or fails leaves them to be retried again.
+func (s *Scraper) RetryFailed(ctx context.Context) (err error) {
+	s, ctx = s.forRun(ctx)
+	ctx, span := tracer.Start(ctx, "scraper.retry_failed")
+	defer func() { endSpan(span, err) }()
+	defer s.reportDrift()
+
+	failures, err := s.db.Failures(ctx)
+	if err != nil {
+		return err
+	}
+	if len(failures) == 0 {
+		s.logger.Info("no failed items to retry")
+		return nil
+	}
+	s.logger.Info("retrying failed items", "count", len(failures))
+	runStart := time.Now()
+	s.progress.StartRun()
+	defer s.progress.FinishRun()
+
+	var categoryIDs []int
+	var priceItems []productRegionItem
+	var productIDs []int
+	var categoryFailures, priceFailures, unknownFailures []store.Failure
+	for _, f := range failures {
+		switch {
+		case f.Phase == phaseProducts && f.CategoryExternalID != nil:
+			categoryFailures = append(categoryFailures, f)
+			if !slices.Contains(categoryIDs, *f.CategoryExternalID) {
+				categoryIDs = append(categoryIDs, *f.CategoryExternalID)
+			}
+		case f.Phase == phasePrices && f.ProductExternalID != nil && f.RegionID != nil:
+			priceFailures = append(priceFailures, f)
+			item := productRegionItem{ProductExtID: *f.ProductExternalID, RegionID: *f.RegionID}
+			if f.RegionName != nil {
+				item.RegionName = *f.RegionName
+			}
+			if slices.ContainsFunc(priceItems, func(i productRegionItem) bool {
+				return i.ProductExtID == item.ProductExtID && i.RegionID == item.RegionID
+			}) {
+				continue
+			}
+			priceItems = append(priceItems, item)
+			productIDs = append(productIDs, *f.ProductExternalID)
+		default:
+			unknownFailures = append(unknownFailures, f)
+			s.logger.Warn("skipping unknown scrape failure", "id", f.ID, "phase", f.Phase)
+		}
+	}
+
+	// Nothing can retry these, so keeping them would only repeat the warning
+	if len(unknownFailures) > 0 {
+		if err := s.resolveFailures(ctx, unknownFailures); err != nil {
+			return err
+		}
+	}
+
+	if len(categoryIDs) > 0 {
+		categoryMap, err := s.loadCategoryMap(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load categories: %w", err)
+		}
+		scope, err := s.withSubcategories(ctx, Scope{IncludeCategories: categoryIDs})
+		if err != nil {
+			return err
+		}
+		if _, err := s.scrapeProducts(ctx, categoryMap, scope); err != nil {
+			return fmt.Errorf("failed to retry products: %w", err)
+		}
+		if err := s.resolveFailures(ctx, categoryFailures); err != nil {
+			return err
+		}
+	}
+
+	if len(priceItems) > 0 {
+		productMap, err := s.loadProductMap(ctx, Scope{IncludeProducts: productIDs})
+		if err != nil {
+			return fmt.Errorf("failed to load products: %w", err)
+		}
+
+		items := priceItems[:0]
+		for _, item := range priceItems {
+			intID, ok := productMap[item.ProductExtID]
+			if !ok {
+				s.logger.Warn("skipping retry for unknown product", "productID", item.ProductExtID)
+				continue
+			}
+			item.ProductIntID = intID
+			items = append(items, item)
+		}
+
+		if err := s.scrapePriceItems(ctx, items); err != nil {
+			return fmt.Errorf("failed to retry prices: %w", err)
+		}
+		if err := s.resolveFailures(ctx, priceFailures); err != nil {
+			return err
+		}
+
+		if err := s.refreshAggregates(ctx, runStart, time.Now()); err != nil {
+			return fmt.Errorf("failed to refresh aggregates: %w", err)
+		}
+	}
+
+	s.logger.Info("retry completed", "categories", len(categoryIDs), "priceItems", len(priceItems))
+	return nil
+}
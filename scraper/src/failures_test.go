@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	ekalathiapi "github.com/pheever/cy-price-watchdog/scraper/src/ekalathi-api"
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+)
+
+func TestRetryFailed(t *testing.T) {
+	ptr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name         string
+		cancel       bool
+		wantErr      bool
+		wantFailures []int // regions of the failures left afterwards
+		wantPrices   int
+	}{
+		// Region 1 succeeds; region 2 fails again and is recorded anew
+		{name: "retried", wantFailures: []int{2}, wantPrices: 1},
+		{name: "cancelled", cancel: true, wantErr: true, wantFailures: []int{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := store.NewMemory()
+			seedProduct(t, db, 10)
+			var before []string
+			for _, region := range []int{1, 2} {
+				if err := db.RecordFailure(context.Background(), store.Failure{Phase: phasePrices, ProductExternalID: ptr(10), RegionID: ptr(region)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			failures, _ := db.Failures(context.Background())
+			for _, f := range failures {
+				before = append(before, f.ID)
+			}
+
+			s := newTestScraper(t, db, branchesAPI(map[string][]ekalathiapi.RetailBranchResponse{
+				"1": {{ID: 100, Name: "A1", CompanyName: "Alpha", RetailerProductPrice: 1.2}},
+			}))
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel {
+				cancel()
+			}
+			defer cancel()
+
+			if err := s.RetryFailed(ctx); (err != nil) != tt.wantErr {
+				t.Fatalf("RetryFailed() error = %v, want an error: %v", err, tt.wantErr)
+			}
+
+			failures, _ = db.Failures(context.Background())
+			var regions []int
+			for _, f := range failures {
+				regions = append(regions, *f.RegionID)
+				if !tt.cancel && slices.Contains(before, f.ID) {
+					t.Errorf("failure %s is still pending after its retry", f.ID)
+				}
+			}
+			if len(regions) != len(tt.wantFailures) {
+				t.Fatalf("failures left for regions %v, want %v", regions, tt.wantFailures)
+			}
+			for i := range regions {
+				if regions[i] != tt.wantFailures[i] {
+					t.Errorf("failures left for regions %v, want %v", regions, tt.wantFailures)
+				}
+			}
+			if prices := db.Prices(); len(prices) != tt.wantPrices {
+				t.Errorf("stored %d prices, want %d", len(prices), tt.wantPrices)
+			}
+		})
+	}
+}
+
+func TestRetryFailedSubcategories(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemory()
+	dairy, err := db.UpsertCategory(ctx, store.Category{ExternalID: 1, Name: "Dairy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.UpsertCategory(ctx, store.Category{ExternalID: 11, Name: "Cheese", ParentID: &dairy}); err != nil {
+		t.Fatal(err)
+	}
+	dairyID := 1
+	if err := db.RecordFailure(ctx, store.Failure{Phase: phaseProducts, CategoryExternalID: &dairyID}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Products resolve to the subcategory named on them, which must be in
+	// the scope of the failed parent
+	s := newTestScraper(t, db, func(w http.ResponseWriter, r *http.Request) {
+		if ekalathiapi.Endpoint(r) != ekalathiapi.ProductsEndpoint {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(ekalathiapi.ProductListResponse{
+			Content: []ekalathiapi.Product{{ProductMasterId: 20, Name: "Halloumi", ProductCategoryName: "Cheese"}},
+			Last:    true,
+		})
+	})
+	if err := s.RetryFailed(ctx); err != nil {
+		t.Fatalf("RetryFailed() error = %v", err)
+	}
+
+	products, err := db.ProductCategories(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || products[0].ExternalID != 20 || products[0].CategoryExternalID != 11 {
+		t.Errorf("stored products %+v, want product 20 in category 11", products)
+	}
+	if failures, _ := db.Failures(ctx); len(failures) != 0 {
+		t.Errorf("%d failures left, want none", len(failures))
+	}
+}
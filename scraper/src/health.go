@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ekalathiapi "github.com/pheever/cy-price-watchdog/scraper/src/ekalathi-api"
+)
+
+const (
+	readyCheckTimeout = 5 * time.Second
+	upstreamCheckTTL  = time.Minute
+	progressInterval  = time.Second
+)
+
+// healthChecks backs the /health, /ready and /progress endpoints. The scraper is set
+// once the database connection is established; until then the process is
+// alive but not ready.
+type healthChecks struct {
+	scraper        atomic.Pointer[Scraper]
+	stallTimeout   time.Duration
+	streamInterval time.Duration
+	logger         *slog.Logger
+
+	mu                sync.Mutex
+	upstreamErr       error
+	upstreamCheckedAt time.Time
+}
+
+// HealthStatus is the body of the /health endpoint
+type HealthStatus struct {
+	Status          string     `json:"status"`
+	Running         bool       `json:"running"`
+	Phase           string     `json:"phase,omitempty"`
+	Processed       int        `json:"processed"`
+	RunStartedAt    *time.Time `json:"runStartedAt,omitempty"`
+	LastProgressAge string     `json:"lastProgressAge,omitempty"`
+	StallTimeout    string     `json:"stallTimeout"`
+}
+
+// ReadyStatus is the body of the /ready endpoint
+type ReadyStatus struct {
+	Status   string `json:"status"`
+	Database string `json:"database"`
+	Upstream string `json:"upstream"`
+}
+
+func newHealthChecks(stallTimeout time.Duration, logger *slog.Logger) *healthChecks {
+	return &healthChecks{
+		stallTimeout:   stallTimeout,
+		streamInterval: progressInterval,
+		logger:         logger,
+	}
+}
+
+// handleHealth reports liveness. It fails only when a run is in progress
+// and has not made progress for longer than the stall timeout.
+func (h *healthChecks) handleHealth(w http.ResponseWriter, r *http.Request) {
+	status := HealthStatus{
+		Status:       "ok",
+		StallTimeout: h.stallTimeout.String(),
+	}
+	code := http.StatusOK
+
+	if scraper := h.scraper.Load(); scraper == nil {
+		status.Status = "starting"
+	} else {
+		snap := scraper.progress.Snapshot()
+		status.Running = snap.Running
+		status.Phase = snap.Phase
+		status.Processed = snap.Done + snap.Failed
+		if !snap.StartedAt.IsZero() {
+			started := snap.StartedAt.UTC()
+			status.RunStartedAt = &started
+		}
+		if !snap.LastProgress.IsZero() {
+			age := time.Since(snap.LastProgress)
+			status.LastProgressAge = age.Round(time.Second).String()
+			if snap.Running && age > h.stallTimeout {
+				status.Status = "stalled"
+				code = http.StatusServiceUnavailable
+			}
+		}
+	}
+
+	writeJSON(w, code, status)
+}
+
+// handleReady reports readiness. The database must answer a ping; eKalathi
+// reachability is reported but does not fail the check, since an upstream
+// outage is not something restarting or rerouting the scraper would fix.
+func (h *healthChecks) handleReady(w http.ResponseWriter, r *http.Request) {
+	scraper := h.scraper.Load()
+	if scraper == nil {
+		writeJSON(w, http.StatusServiceUnavailable, ReadyStatus{
+			Status:   "unavailable",
+			Database: "not connected",
+			Upstream: "unknown",
+		})
+		return
+	}
+
+	status := ReadyStatus{Status: "ok", Database: "ok", Upstream: "ok"}
+	code := http.StatusOK
+
+	if err := h.database(r.Context(), scraper); err != nil {
+		status.Status = "unavailable"
+		status.Database = err.Error()
+		code = http.StatusServiceUnavailable
+	}
+	if err := h.upstream(r.Context(), scraper); err != nil {
+		status.Upstream = err.Error()
+	}
+
+	writeJSON(w, code, status)
+}
+
+// handleProgress reports the progress of the current or last run
+func (h *healthChecks) handleProgress(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.progress())
+}
+
+// handleProgressStream sends a progress event every streamInterval as
+// server-sent events until the client disconnects
+func (h *healthChecks) handleProgressStream(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(h.streamInterval)
+	defer ticker.Stop()
+
+	for {
+		data, err := json.Marshal(h.progress())
+		if err != nil {
+			h.logger.Error("failed to encode progress", "error", err)
+			return
+		}
+		if _, err := fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *healthChecks) progress() ProgressSnapshot {
+	if scraper := h.scraper.Load(); scraper != nil {
+		return scraper.progress.Snapshot()
+	}
+	return ProgressSnapshot{}
+}
+
+func (h *healthChecks) database(ctx context.Context, scraper *Scraper) error {
+	ctx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+	defer cancel()
+	return scraper.db.Ping(ctx)
+}
+
+// upstream returns the result of the last eKalathi check, refreshing it when
+// older than upstreamCheckTTL so probes don't add load on the API
+func (h *healthChecks) upstream(ctx context.Context, scraper *Scraper) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if time.Since(h.upstreamCheckedAt) < upstreamCheckTTL {
+		return h.upstreamErr
+	}
+	ctx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+	defer cancel()
+	h.upstreamErr = scraper.pingUpstream(ctx)
+	h.upstreamCheckedAt = time.Now()
+	return h.upstreamErr
+}
+
+// pingUpstream checks that the eKalathi API answers a cheap request
+func (s *Scraper) pingUpstream(ctx context.Context) error {
+	req, err := ekalathiapi.GetRegions(ekalathiapi.RegionRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to create regions request: %w", err)
+	}
+
+	resp, err := s.client.Do(s.updateHeaders(req.WithContext(ctx)))
+	if err != nil {
+		return fmt.Errorf("eKalathi unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func startHealthServer(mux *http.ServeMux, checks *healthChecks, port int) *http.Server {
+
+	mux.HandleFunc("/health", checks.handleHealth)
+	mux.HandleFunc("/ready", checks.handleReady)
+	mux.HandleFunc("/progress", checks.handleProgress)
+	mux.HandleFunc("/progress/stream", checks.handleProgressStream)
+
+	server := &http.Server{
+		Addr:    ":" + strconv.Itoa(port),
+		Handler: mux,
+	}
+
+	go func() {
+		checks.logger.Info("health server starting", "port", port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			checks.logger.Error("health server error", "error", err)
+		}
+	}()
+
+	return server
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		scraper    func() *Scraper
+		wantCode   int
+		wantStatus string
+	}{
+		{
+			name:       "not connected yet",
+			scraper:    func() *Scraper { return nil },
+			wantCode:   http.StatusOK,
+			wantStatus: "starting",
+		},
+		{
+			name:       "idle",
+			scraper:    func() *Scraper { return &Scraper{progress: NewProgress()} },
+			wantCode:   http.StatusOK,
+			wantStatus: "ok",
+		},
+		{
+			name: "run making progress",
+			scraper: func() *Scraper {
+				s := &Scraper{progress: NewProgress()}
+				s.progress.StartRun()
+				s.progress.StartPhase(phasePrices, 10)
+				s.progress.Done(0)
+				return s
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: "ok",
+		},
+		{
+			name: "run stalled",
+			scraper: func() *Scraper {
+				s := &Scraper{progress: NewProgress()}
+				s.progress.StartRun()
+				s.progress.StartPhase(phasePrices, 10)
+				s.progress.lastProgress = time.Now().Add(-time.Hour)
+				return s
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "stalled",
+		},
+		{
+			name: "finished run is not stalled",
+			scraper: func() *Scraper {
+				s := &Scraper{progress: NewProgress()}
+				s.progress.StartRun()
+				s.progress.FinishRun()
+				s.progress.lastProgress = time.Now().Add(-time.Hour)
+				return s
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: "ok",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := newHealthChecks(time.Minute, slog.New(slog.DiscardHandler))
+			if s := tt.scraper(); s != nil {
+				checks.scraper.Store(s)
+			}
+
+			rec := httptest.NewRecorder()
+			checks.handleHealth(rec, httptest.NewRequest("GET", "/health", nil))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", rec.Code, tt.wantCode)
+			}
+			var status HealthStatus
+			if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+				t.Fatalf("failed to decode status: %v", err)
+			}
+			if status.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", status.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestReadyHandlerNotConnected(t *testing.T) {
+	checks := newHealthChecks(time.Minute, slog.New(slog.DiscardHandler))
+
+	rec := httptest.NewRecorder()
+	checks.handleReady(rec, httptest.NewRequest("GET", "/ready", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status code = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestProgressStream(t *testing.T) {
+	checks := newHealthChecks(time.Minute, slog.New(slog.DiscardHandler))
+	checks.streamInterval = 10 * time.Millisecond
+	scraper := &Scraper{progress: NewProgress()}
+	scraper.progress.StartRun()
+	scraper.progress.StartPhase(phasePrices, 4)
+	checks.scraper.Store(scraper)
+
+	server := httptest.NewServer(http.HandlerFunc(checks.handleProgressStream))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	// Read events until one reflects the item completed after connecting
+	scraper.progress.Done(0)
+	reader := bufio.NewReader(resp.Body)
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read stream: %v", err)
+		}
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: ")
+		if !ok {
+			continue
+		}
+		var snap ProgressSnapshot
+		if err := json.Unmarshal([]byte(data), &snap); err != nil {
+			t.Fatalf("failed to decode event %q: %v", data, err)
+		}
+		if snap.Phase != phasePrices || snap.Total != 4 {
+			t.Errorf("phase/total = %q/%d, want %q/4", snap.Phase, snap.Total, phasePrices)
+		}
+		if snap.Done == 1 {
+			return
+		}
+	}
+	t.Error("no progress event reported the completed item")
+}
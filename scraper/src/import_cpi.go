@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pheever/cy-price-watchdog/scraper/src/cpi"
+)
+
+// runImportCPI loads official CPI series files (SDMX-CSV or SDMX-JSON) into
+// the CpiSeries table, and optionally the category to COICOP mapping
+func runImportCPI(ctx context.Context, dbURL string, logger *slog.Logger, args []string) error {
+	fs := newFlagSet("import-cpi")
+	source := fs.String("source", "cystat", "publisher of the CPI series")
+	mappingFile := fs.String("mapping", "", "CSV of category_external_id,coicop pairs")
+	unit := fs.String("unit", "", "only import the series with this unit, e.g. I15")
+	geo := fs.String("geo", "", "only import the series for this geo, e.g. CY")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	filter := cpi.Filter{}
+	if *unit != "" {
+		filter["unit"] = *unit
+	}
+	if *geo != "" {
+		filter["geo"] = *geo
+	}
+	if fs.NArg() == 0 && *mappingFile == "" {
+		fs.Usage()
+		return fmt.Errorf("no CPI files or mapping given")
+	}
+
+	if dbURL == "" {
+		return errDatabaseURL
+	}
+	// CpiSeries only exists in the PostgreSQL schema
+	if u, err := url.Parse(dbURL); err == nil && u.Scheme != "" && u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return fmt.Errorf("import-cpi needs a PostgreSQL database, got a %s URL", u.Scheme)
+	}
+
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer pool.Close()
+
+	for _, path := range fs.Args() {
+		observations, err := readCPIFile(path, filter)
+		if err != nil {
+			return err
+		}
+
+		if err := upsertCPISeries(ctx, pool, observations, *source); err != nil {
+			return fmt.Errorf("failed to import %s: %w", path, err)
+		}
+		logger.Info("imported CPI series", "file", path, "observations", len(observations), "source", *source)
+	}
+
+	if *mappingFile != "" {
+		f, err := os.Open(*mappingFile)
+		if err != nil {
+			return fmt.Errorf("failed to open category mapping: %w", err)
+		}
+		defer f.Close()
+
+		mapping, err := cpi.ParseCategoryMapping(f)
+		if err != nil {
+			return err
+		}
+
+		updated, err := updateCategoryCoicop(ctx, pool, logger, mapping)
+		if err != nil {
+			return err
+		}
+		logger.Info("imported category COICOP mapping", "entries", len(mapping), "updated", updated)
+	}
+
+	return nil
+}
+
+func readCPIFile(path string, filter cpi.Filter) ([]cpi.Observation, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CPI file: %w", err)
+	}
+	defer f.Close()
+
+	observations, err := cpi.Parse(path, f, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return observations, nil
+}
+
+func upsertCPISeries(ctx context.Context, pool *pgxpool.Pool, observations []cpi.Observation, source string) error {
+	now := time.Now().UTC()
+
+	batch := &pgx.Batch{}
+	for _, obs := range observations {
+		batch.Queue(`
+			INSERT INTO "CpiSeries" (id, coicop, month, value, source, "createdAt", "updatedAt")
+			VALUES ($1, $2, $3, $4, $5, $6, $6)
+			ON CONFLICT (coicop, month) DO UPDATE SET
+				value = EXCLUDED.value,
+				source = EXCLUDED.source,
+				"updatedAt" = EXCLUDED."updatedAt"
+		`, uuid.New().String(), obs.Coicop, obs.Month, obs.Value, source, now)
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to upsert CPI series: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// updateCategoryCoicop sets Category.coicop for each mapped external ID and
+// returns the number of categories updated
+func updateCategoryCoicop(ctx context.Context, pool *pgxpool.Pool, logger *slog.Logger, mapping map[int]string) (int64, error) {
+	var updated int64
+	for externalID, coicop := range mapping {
+		tag, err := pool.Exec(ctx, `
+			UPDATE "Category" SET coicop = $2, "updatedAt" = $3 WHERE "externalId" = $1
+		`, externalID, coicop, time.Now().UTC())
+		if err != nil {
+			return updated, fmt.Errorf("failed to update category %d: %w", externalID, err)
+		}
+		if tag.RowsAffected() == 0 {
+			logger.Warn("mapped category not found", "categoryID", externalID)
+		}
+		updated += tag.RowsAffected()
+	}
+	return updated, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pheever/cy-price-watchdog/scraper/src/config"
+)
+
+// newLogger creates a logger writing to w at level in format. main builds
+// one from log.level and log.format (LOG_LEVEL and LOG_FORMAT) and hands it
+// to the command; each Scraper derives its own, which carries the run ID
+// during a run.
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	lvl, err := config.ParseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", config.LogFormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case config.LogFormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("log.format %q must be %s or %s", format, config.LogFormatJSON, config.LogFormatText)
+	}
+}
+
+// --- Run IDs ---
+
+type runIDKey struct{}
+
+// withRunID attaches the ID of the run ctx belongs to
+func withRunID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, id)
+}
+
+// runIDFromContext returns the run ID attached to ctx, if any
+func runIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
+
+// forRun returns a copy of s whose log lines carry the run ID and which
+// collects the run's schema drift, along with a context holding the ID. Runs
+// started through the RunManager keep the ID it assigned; other runs get a
+// new one.
+func (s *Scraper) forRun(ctx context.Context) (*Scraper, context.Context) {
+	id := runIDFromContext(ctx)
+	if id == "" {
+		id = uuid.New().String()
+		ctx = withRunID(ctx, id)
+	}
+
+	run := *s
+	run.logger = s.logger.With("runID", id)
+	run.drift = &schemaDrift{}
+	return &run, ctx
+}
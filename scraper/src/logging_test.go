@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name      string
+		level     string
+		format    string
+		wantDebug bool
+		wantJSON  bool
+		wantErr   bool
+	}{
+		{"defaults", "", "", false, true, false},
+		{"debug json", "debug", "json", true, true, false},
+		{"upper case text", "DEBUG", "TEXT", true, false, false},
+		{"warning alias", "warning", "text", false, false, false},
+		{"error", "error", "", false, true, false},
+		{"invalid level", "verbose", "", false, false, true},
+		{"invalid format", "info", "xml", false, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l, err := newLogger(&buf, tt.level, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newLogger(%q, %q) error = %v, wantErr %v", tt.level, tt.format, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			l.Debug("debug line")
+			if got := strings.Contains(buf.String(), "debug line"); got != tt.wantDebug {
+				t.Errorf("debug line logged = %v, want %v", got, tt.wantDebug)
+			}
+
+			buf.Reset()
+			l.Error("error line")
+			if got := json.Valid(buf.Bytes()); got != tt.wantJSON {
+				t.Errorf("JSON output = %v, want %v: %s", got, tt.wantJSON, buf.String())
+			}
+		})
+	}
+}
+
+func TestForRun(t *testing.T) {
+	var buf bytes.Buffer
+	s := &Scraper{progress: NewProgress(), logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		wantID string
+	}{
+		{"ID from the run manager", withRunID(context.Background(), "run-1"), "run-1"},
+		{"new ID", context.Background(), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			run, ctx := s.forRun(tt.ctx)
+			run.logger.Info("hello")
+
+			var line map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("invalid log line %q: %v", buf.String(), err)
+			}
+			id, _ := line["runID"].(string)
+			if id == "" || id != runIDFromContext(ctx) {
+				t.Errorf("runID = %q, want the context's %q", id, runIDFromContext(ctx))
+			}
+			if tt.wantID != "" && id != tt.wantID {
+				t.Errorf("runID = %q, want %q", id, tt.wantID)
+			}
+			if run.progress != s.progress {
+				t.Error("run should share the scraper's progress")
+			}
+		})
+	}
+
+	buf.Reset()
+	s.logger.Info("outside a run")
+	if strings.Contains(buf.String(), "runID") {
+		t.Errorf("the scraper's own logger should not carry a run ID: %s", buf.String())
+	}
+}
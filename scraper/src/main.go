@@ -2,104 +2,198 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/pheever/cy-price-watchdog/scraper/src/config"
 	"github.com/pheever/cy-price-watchdog/scraper/src/metrics"
 )
 
+// errDatabaseURL is returned by commands that need the database when none is
+// configured
+var errDatabaseURL = errors.New("a database URL is required: pass --db, or set DATABASE_URL or database.url in the config file")
+
 func main() {
-	logger.Info("scraper starting")
+	flags, args := splitGlobalFlags(os.Args[1:])
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(flags.ConfigPath)
+	if err == nil && flags.DatabaseURL != "" {
+		cfg.Database.URL = flags.DatabaseURL
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger, err := newLogger(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if err := cmd.Run(cfg, logger, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		logger.Error("command failed", "command", cmd.Name, "error", err)
+		os.Exit(1)
+	}
+}
+
+// globalFlags are the flags given before the command
+type globalFlags struct {
+	ConfigPath  string // --config, or SCRAPER_CONFIG; empty means no file
+	DatabaseURL string // --db, replacing database.url
+}
+
+// splitGlobalFlags takes the leading --config and --db flags off args, in
+// either order
+func splitGlobalFlags(args []string) (globalFlags, []string) {
+	flags := globalFlags{ConfigPath: os.Getenv("SCRAPER_CONFIG")}
+	for len(args) > 0 {
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(args[0], "-"), "=")
+		name = strings.TrimPrefix(name, "-")
+
+		var target *string
+		switch {
+		case !strings.HasPrefix(args[0], "-"):
+			return flags, args
+		case name == "config":
+			target = &flags.ConfigPath
+		case name == "db":
+			target = &flags.DatabaseURL
+		default:
+			return flags, args
+		}
+
+		if !hasValue {
+			if len(args) < 2 {
+				return flags, args
+			}
+			value, args = args[1], args[1:]
+		}
+		*target = value
+		args = args[1:]
+	}
+	return flags, args
+}
+
+// withScraper sets up tracing, metrics, signal handling, the health server
+// and the database connection, then runs fn. It is shared by the commands
+// that scrape; fn may register extra routes on the health server's mux.
+func withScraper(cfg *config.Config, logger *slog.Logger, fn func(ctx context.Context, scraper *Scraper, mux *http.ServeMux) error) error {
+	logger.Info("scraper starting", "config", cfg)
+
+	// Export traces when an OTLP endpoint is configured
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("failed to flush traces", "error", err)
+		}
+	}()
 
 	// Initialize metrics collector
-	metricsCollector := metrics.New()
+	metricsCollector := metrics.NewWithOptions(metrics.Options{
+		URL:           cfg.Metrics.URL,
+		BufferSize:    cfg.Metrics.BufferSize,
+		BatchSize:     cfg.Metrics.BatchSize,
+		FlushInterval: cfg.Metrics.FlushInterval,
+	})
 	defer func() {
-		if err := metricsCollector.Flush(); err != nil {
+		if err := metricsCollector.Close(); err != nil {
 			logger.Error("failed to flush metrics", "error", err)
 		} else {
 			logger.Info("metrics flushed successfully")
 		}
+		if dropped := metricsCollector.Dropped(); dropped > 0 {
+			logger.Warn("metrics were dropped", "count", dropped)
+		}
 	}()
 
 	// Set up signal handling to log before shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Push metrics periodically so a crash loses at most one interval
+	metricsCollector.Start(ctx, func(err error) {
+		logger.Error("failed to flush metrics", "error", err)
+	})
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigCh)
 
 	go func() {
-		sig := <-sigCh
-		logger.Info("received signal, initiating graceful shutdown", "signal", sig.String())
-		metricsCollector.RecordCount("errors", 1, map[string]string{"phase": "signal", "signal": sig.String()})
-		cancel()
+		select {
+		case sig := <-sigCh:
+			logger.Info("received signal, initiating graceful shutdown", "signal", sig.String())
+			metricsCollector.RecordCount("errors", 1, map[string]string{"phase": "signal", "signal": sig.String()})
+			cancel()
+		case <-ctx.Done():
+		}
 	}()
 
 	// Start health check server
-	healthServer := startHealthServer()
+	mux := http.NewServeMux()
+	checks := newHealthChecks(cfg.Server.StallTimeout, logger)
+	mux.Handle("/metrics", metricsCollector.Handler())
+	healthServer := startHealthServer(mux, checks, cfg.Server.Port)
 	defer healthServer.Close()
 
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		logger.Error("DATABASE_URL environment variable is required")
-		os.Exit(1)
+	if cfg.Database.URL == "" {
+		return errDatabaseURL
 	}
-	logger.Info("DATABASE_URL found, connecting to database")
+	logger.Info("database URL found, connecting to database")
 
-	scraper, err := NewScraper(dbURL, metricsCollector)
+	scraper, err := NewScraper(cfg, metricsCollector, logger)
 	if err != nil {
-		logger.Error("failed to initialize scraper", "error", err)
 		metricsCollector.RecordCount("errors", 1, map[string]string{"phase": "init"})
-		os.Exit(1)
+		return fmt.Errorf("failed to initialize scraper: %w", err)
 	}
 	defer scraper.Close()
+	checks.scraper.Store(scraper)
+	registerProgressMetrics(metricsCollector, scraper.progress)
 	logger.Info("database connection established")
 
-	if err := scraper.Run(ctx); err != nil {
+	return fn(ctx, scraper, mux)
+}
+
+// recordRunResult counts a finished run in the metrics and wraps its error
+func recordRunResult(ctx context.Context, metricsCollector *metrics.Collector, logger *slog.Logger, err error) error {
+	if err != nil {
 		if ctx.Err() != nil {
-			logger.Error("scraper interrupted by signal", "error", err)
 			metricsCollector.RecordCount("errors", 1, map[string]string{"phase": "interrupted"})
-			os.Exit(1)
+			return fmt.Errorf("scraper interrupted: %w", err)
 		}
-		logger.Error("scraper failed", "error", err)
 		metricsCollector.RecordCount("errors", 1, map[string]string{"phase": "run"})
-		os.Exit(1)
+		return fmt.Errorf("scraper failed: %w", err)
 	}
 
 	metricsCollector.RecordCount("runs", 1, map[string]string{"status": "success"})
 	logger.Info("scraper finished successfully")
-}
-
-func startHealthServer() *http.Server {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	mux := http.NewServeMux()
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintln(w, "ok")
-	})
-	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintln(w, "ok")
-	})
-
-	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
-	}
-
-	go func() {
-		logger.Info("health server starting", "port", port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Error("health server error", "error", err)
-		}
-	}()
-
-	return server
+	return nil
 }
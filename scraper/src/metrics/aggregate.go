@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+type aggregateKind int
+
+const (
+	aggCount aggregateKind = iota
+	aggDuration
+	aggGauge
+	aggHistogram
+)
+
+// aggregate accumulates the typed metrics recorded for one name and tag set
+// between flushes, so a metric recorded per request is sent as one point per
+// flush instead of one point per call
+type aggregate struct {
+	kind aggregateKind
+	tags map[string]string
+
+	count int64 // aggCount: sum of the recorded counts
+
+	samples  int64 // aggDuration and aggHistogram
+	sum      time.Duration
+	min, max time.Duration
+
+	buckets   []int64         // aggHistogram: counts per LatencyBuckets bound, not cumulative
+	reservoir []time.Duration // aggHistogram: sampled durations for percentiles
+
+	value float64 // aggGauge: last recorded value
+}
+
+// aggregate applies update to the series for name and tags, creating it if
+// there is room in the buffer
+func (c *Collector) aggregate(kind aggregateKind, name string, tags map[string]string, update func(*aggregate)) {
+	if c.url == "" {
+		return // Metrics disabled
+	}
+
+	tags = mergeTags(tags, map[string]string{"metric": name})
+	if err := validateTags(tags); err != nil {
+		c.drop(1)
+		return
+	}
+	key := strconv.Itoa(int(kind)) + formatLabels(tags)
+
+	c.mu.Lock()
+	a, ok := c.aggregates[key]
+	if !ok {
+		if c.bufferSize > 0 && c.pending() >= c.bufferSize {
+			c.mu.Unlock()
+			c.drop(1)
+			return
+		}
+		if c.aggregates == nil {
+			c.aggregates = make(map[string]*aggregate)
+		}
+		a = &aggregate{kind: kind, tags: tags}
+		c.aggregates[key] = a
+	}
+	update(a)
+	c.mu.Unlock()
+}
+
+// drainAggregates adds every aggregate as a point at the end of the buffer
+// and resets them. The caller must hold c.mu.
+func (c *Collector) drainAggregates(ts time.Time) {
+	for _, a := range c.aggregates {
+		c.metrics = append(c.metrics, Point{
+			Measurement: "scraper",
+			Tags:        a.tags,
+			Fields:      a.fields(),
+			Time:        ts,
+			Kind:        a.kindOfPoint(),
+		})
+	}
+	clear(c.aggregates)
+}
+
+// pending returns the number of buffered points and series. The caller must
+// hold c.mu.
+func (c *Collector) pending() int {
+	return len(c.metrics) + len(c.aggregates)
+}
+
+func (a *aggregate) kindOfPoint() Kind {
+	switch a.kind {
+	case aggCount:
+		return KindCounter
+	case aggDuration:
+		return KindDuration
+	case aggHistogram:
+		return KindHistogram
+	default:
+		return KindGauge
+	}
+}
+
+func (a *aggregate) fields() map[string]interface{} {
+	switch a.kind {
+	case aggCount:
+		return map[string]interface{}{"count": a.count}
+	case aggDuration:
+		return map[string]interface{}{
+			"duration_ms": (a.sum / time.Duration(a.samples)).Milliseconds(),
+			"min_ms":      a.min.Milliseconds(),
+			"max_ms":      a.max.Milliseconds(),
+			"sum_ms":      a.sum.Milliseconds(),
+			"samples":     a.samples,
+		}
+	case aggHistogram:
+		return a.histogramFields()
+	default:
+		return map[string]interface{}{"value": a.value}
+	}
+}
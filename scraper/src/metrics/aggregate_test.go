@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAggregation(t *testing.T) {
+	c := newTestCollector("http://localhost:8086", http.DefaultClient)
+
+	c.RecordCount("requests", 1, map[string]string{"status": "ok"})
+	c.RecordCount("requests", 2, map[string]string{"status": "ok"})
+	c.RecordCount("requests", 1, map[string]string{"status": "error"})
+	c.RecordDuration("request", 100*time.Millisecond, nil)
+	c.RecordDuration("request", 300*time.Millisecond, nil)
+	c.RecordDuration("request", 200*time.Millisecond, nil)
+	c.RecordGauge("queue_depth", 5, nil)
+	c.RecordGauge("queue_depth", 3, nil)
+
+	lines := pointLines(c.take())
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 aggregated lines, got %d: %q", len(lines), lines)
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		fields []string
+	}{
+		{"counts summed per tag set", "metric=requests", []string{"count=3i"}},
+		{"counts kept apart by tags", "metric=requests", []string{"count=1i"}},
+		{"durations summarised", "metric=request", []string{"duration_ms=200i", "min_ms=100i", "max_ms=300i", "sum_ms=600i", "samples=3i"}},
+		{"gauge keeps last value", "metric=queue_depth", []string{"value=3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := slices.ContainsFunc(lines, func(line string) bool {
+				if !strings.Contains(line, tt.prefix+" ") && !strings.Contains(line, tt.prefix+",") {
+					return false
+				}
+				for _, field := range tt.fields {
+					if !strings.Contains(line, field) {
+						return false
+					}
+				}
+				return true
+			})
+			if !found {
+				t.Errorf("no line with %s and fields %v in %q", tt.prefix, tt.fields, lines)
+			}
+		})
+	}
+
+	if more := c.take(); len(more) != 0 {
+		t.Errorf("aggregates should reset after being taken, got %q", more)
+	}
+}
+
+func TestAggregationBounded(t *testing.T) {
+	c := newTestCollector("http://localhost:8086", http.DefaultClient)
+	c.bufferSize = 2
+
+	c.RecordCount("a", 1, nil)
+	c.RecordCount("b", 1, nil)
+	c.RecordCount("a", 1, nil) // existing series still updates
+	c.RecordCount("c", 1, nil) // new series does not fit
+
+	if got := c.Dropped(); got != 1 {
+		t.Errorf("Dropped() = %d, want 1", got)
+	}
+	if lines := c.take(); len(lines) != 2 {
+		t.Errorf("expected 2 lines, got %q", lines)
+	}
+}
+
+func TestConcurrentRecording(t *testing.T) {
+	c := newTestCollector("http://localhost:8086", http.DefaultClient)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				c.RecordCount("requests", 1, nil)
+				c.RecordDuration("request", time.Millisecond, nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := pointLines(c.take())
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", lines)
+	}
+	if !slices.ContainsFunc(lines, func(line string) bool { return strings.Contains(line, "count=8000i") }) {
+		t.Errorf("count should be 8000, got %q", lines)
+	}
+	if !slices.ContainsFunc(lines, func(line string) bool { return strings.Contains(line, "samples=8000i") }) {
+		t.Errorf("samples should be 8000, got %q", lines)
+	}
+}
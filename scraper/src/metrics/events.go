@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// eventsMeasurement is the measurement, and so the TimescaleDB table Telegraf
+// writes to, for events. It matches metrics/schema/003_events_table.sql.
+const eventsMeasurement = "events"
+
+// RecordEvent records a discrete event, such as a run starting or an anomaly
+// being detected, with free-form properties. Events are never aggregated:
+// each call is sent as one point in the "events" measurement with an "event"
+// tag and the properties as a JSON string field, which Telegraf writes to the
+// events hypertable. Events are also counted in the live events_total metric.
+func (c *Collector) RecordEvent(name string, properties map[string]interface{}) error {
+	if c.live != nil {
+		c.live.add("events", map[string]string{"event": name}, 1)
+	}
+	if c.url == "" {
+		return nil // Metrics disabled
+	}
+
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	encoded, err := json.Marshal(properties)
+	if err != nil {
+		c.drop(1)
+		return fmt.Errorf("%w: event %q properties: %v", ErrInvalidPoint, name, err)
+	}
+
+	p := Point{
+		Measurement: eventsMeasurement,
+		Tags:        map[string]string{"event": name},
+		Fields:      map[string]interface{}{"properties": string(encoded)},
+		Time:        time.Now(),
+		Kind:        KindEvent,
+	}
+	if _, err := AppendLine(nil, p); err != nil {
+		c.drop(1)
+		return err
+	}
+	c.enqueue(p)
+	return nil
+}
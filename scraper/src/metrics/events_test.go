@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRecordEvent(t *testing.T) {
+	c := newTestCollector("http://localhost:8086", http.DefaultClient)
+
+	err := c.RecordEvent("category_failed", map[string]interface{}{"category_id": 12, "error": `timeout "x"`})
+	if err != nil {
+		t.Fatalf("RecordEvent() error = %v", err)
+	}
+	c.RecordEvent("run_started", nil)
+
+	points := c.take()
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(points))
+	}
+
+	tests := []struct {
+		name       string
+		point      Point
+		event      string
+		properties map[string]interface{}
+	}{
+		{"with properties", points[0], "category_failed", map[string]interface{}{"category_id": 12.0, "error": `timeout "x"`}},
+		{"without properties", points[1], "run_started", map[string]interface{}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.point.Measurement != "events" || tt.point.Kind != KindEvent || tt.point.Tags["event"] != tt.event {
+				t.Errorf("point = %+v, want %s event in events", tt.point, tt.event)
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.point.Fields["properties"].(string)), &got); err != nil {
+				t.Fatalf("properties are not JSON: %v", err)
+			}
+			if len(got) != len(tt.properties) {
+				t.Errorf("properties = %v, want %v", got, tt.properties)
+			}
+			for k, want := range tt.properties {
+				if got[k] != want {
+					t.Errorf("properties[%q] = %v, want %v", k, got[k], want)
+				}
+			}
+		})
+	}
+
+	line := points[0].String()
+	if !strings.HasPrefix(line, `events,event=category_failed properties="{\"category_id\":12,`) {
+		t.Errorf("line = %q", line)
+	}
+}
+
+func TestRecordEventInvalid(t *testing.T) {
+	c := newTestCollector("http://localhost:8086", http.DefaultClient)
+
+	err := c.RecordEvent("bad", map[string]interface{}{"ch": make(chan int)})
+	if !errors.Is(err, ErrInvalidPoint) {
+		t.Errorf("RecordEvent() error = %v, want ErrInvalidPoint", err)
+	}
+	if got := c.Dropped(); got != 1 {
+		t.Errorf("Dropped() = %d, want 1", got)
+	}
+}
+
+func TestRecordEventLiveCount(t *testing.T) {
+	c := newTestCollector("", nil)
+	c.RecordEvent("anomaly_detected", nil)
+	c.RecordEvent("anomaly_detected", nil)
+
+	var sb strings.Builder
+	c.live.WriteTo(&sb)
+	if want := `scraper_events_total{event="anomaly_detected"} 2`; !strings.Contains(sb.String(), want) {
+		t.Errorf("output missing %q:\n%s", want, sb.String())
+	}
+	if len(c.metrics) != 0 {
+		t.Errorf("events should not be buffered without a URL, got %d", len(c.metrics))
+	}
+}
+
+func TestEventSinks(t *testing.T) {
+	event := Point{
+		Measurement: "events",
+		Tags:        map[string]string{"event": "run_started"},
+		Fields:      map[string]interface{}{"properties": `{"a":"x|y"}`},
+		Kind:        KindEvent,
+	}
+
+	if got, want := statsdLines(event), `_e{11,11}:run_started|{"a":"x|y"}`; len(got) != 1 || got[0] != want {
+		t.Errorf("statsdLines() = %q, want %q", got, want)
+	}
+	if got := otlpMetrics(event); len(got) != 0 {
+		t.Errorf("otlpMetrics() = %+v, want no metrics for an event", got)
+	}
+}
@@ -0,0 +1,207 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Defaults for the Options left at zero
+const (
+	DefaultBufferSize    = 10000
+	DefaultBatchSize     = 500
+	DefaultFlushInterval = 30 * time.Second
+)
+
+const (
+	defaultRetryBackoff = 500 * time.Millisecond
+
+	// maxSendAttempts bounds how often one batch is sent before it goes
+	// back into the buffer for the next flush
+	maxSendAttempts = 4
+)
+
+// Start flushes in the background every flush interval, or sooner once a
+// batch worth of metrics is buffered, until ctx is cancelled or Close is
+// called. Errors from background flushes are passed to onError.
+func (c *Collector) Start(ctx context.Context, onError func(error)) {
+	if c.url == "" || c.stop != nil {
+		return
+	}
+
+	interval := c.flushInterval
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+
+	ctx, c.stop = context.WithCancel(ctx)
+	c.stopped = make(chan struct{})
+
+	go func() {
+		defer close(c.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-c.full:
+			}
+			if err := c.Flush(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+}
+
+// Close stops the background flusher, records the total run duration and
+// sends whatever is left in the buffer
+func (c *Collector) Close() error {
+	if c.stop != nil {
+		c.stop()
+		<-c.stopped
+	}
+
+	c.Record("scraper", map[string]string{"metric": "run_duration"}, map[string]interface{}{
+		"duration_ms": c.TotalDuration().Milliseconds(),
+	})
+	err := c.Flush()
+
+	if c.sink != nil {
+		if closeErr := c.sink.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close metrics sink: %w", closeErr)
+		}
+	}
+	return err
+}
+
+// Flush sends the buffered metrics to the sink in batches. It is safe to call
+// from multiple goroutines; concurrent calls send one after the other. A
+// batch that still fails after retries goes back into the buffer, unless the
+// endpoint rejected it outright, in which case it is dropped.
+func (c *Collector) Flush() error {
+	if c.url == "" {
+		return nil
+	}
+
+	sink, err := c.resolveSink()
+	if err != nil {
+		return err
+	}
+
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	for {
+		batch := c.take()
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := c.send(sink, batch); err != nil {
+			return err
+		}
+	}
+}
+
+// Dropped returns how many metrics were discarded because the buffer was
+// full or the endpoint rejected them
+func (c *Collector) Dropped() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+// enqueue buffers a point, dropping it if the buffer is full
+func (c *Collector) enqueue(p Point) {
+	c.mu.Lock()
+	if c.bufferSize > 0 && c.pending() >= c.bufferSize {
+		c.mu.Unlock()
+		c.drop(1)
+		return
+	}
+	c.metrics = append(c.metrics, p)
+	full := c.batchSize > 0 && len(c.metrics) >= c.batchSize
+	c.mu.Unlock()
+
+	if full && c.full != nil {
+		select {
+		case c.full <- struct{}{}:
+		default: // a flush is already pending
+		}
+	}
+}
+
+// take removes up to one batch from the front of the buffer, after adding
+// the aggregates recorded since the last flush to its end
+func (c *Collector) take() []Point {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.drainAggregates(time.Now())
+
+	n := len(c.metrics)
+	if c.batchSize > 0 && n > c.batchSize {
+		n = c.batchSize
+	}
+	batch := make([]Point, n)
+	copy(batch, c.metrics)
+	c.metrics = append(c.metrics[:0], c.metrics[n:]...)
+	return batch
+}
+
+// requeue puts a failed batch back at the front of the buffer, dropping its
+// oldest points if they no longer fit
+func (c *Collector) requeue(batch []Point) {
+	c.mu.Lock()
+	var dropped int
+	if c.bufferSize > 0 {
+		if space := c.bufferSize - c.pending(); len(batch) > space {
+			dropped = len(batch) - max(space, 0)
+			batch = batch[dropped:]
+		}
+	}
+	c.metrics = append(batch, c.metrics...)
+	c.mu.Unlock()
+
+	if dropped > 0 {
+		c.drop(dropped)
+	}
+}
+
+func (c *Collector) drop(n int) {
+	c.mu.Lock()
+	c.dropped += n
+	c.mu.Unlock()
+
+	if c.live != nil {
+		c.live.add("metrics_dropped", nil, float64(n))
+	}
+}
+
+// send writes one batch to the sink, retrying with exponential backoff
+// unless the sink reports a permanent failure
+func (c *Collector) send(sink Sink, batch []Point) error {
+	backoff := c.retryBackoff
+
+	var err error
+	for attempt := 1; attempt <= maxSendAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		err = sink.Write(batch)
+		if err == nil {
+			return nil
+		}
+		if isPermanent(err) {
+			c.drop(len(batch))
+			return err
+		}
+	}
+
+	c.requeue(batch)
+	return err
+}
@@ -0,0 +1,222 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingServer answers with the given status codes in turn (200 once they
+// run out) and keeps the bodies it accepted
+type recordingServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests int
+	bodies   []string
+}
+
+func newRecordingServer(t *testing.T, statuses ...int) *recordingServer {
+	t.Helper()
+	rs := &recordingServer{statuses: statuses}
+	rs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		rs.requests++
+		status := http.StatusNoContent
+		if len(rs.statuses) > 0 {
+			status, rs.statuses = rs.statuses[0], rs.statuses[1:]
+		}
+		if status < 300 {
+			rs.bodies = append(rs.bodies, string(body))
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rs.Close)
+	return rs
+}
+
+func (rs *recordingServer) stats() (int, []string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.requests, append([]string(nil), rs.bodies...)
+}
+
+func newTestCollector(url string, client *http.Client) *Collector {
+	return &Collector{
+		url:       url,
+		client:    client,
+		startTime: time.Now(),
+		live:      newRegistry(),
+		full:      make(chan struct{}, 1),
+	}
+}
+
+// testPoints builds one point per measurement, rendered as "<name> v=<n>i 1"
+func testPoints(measurements ...string) []Point {
+	points := make([]Point, len(measurements))
+	for i, m := range measurements {
+		points[i] = Point{Measurement: m, Fields: map[string]interface{}{"v": i + 1}, Time: time.Unix(0, 1)}
+	}
+	return points
+}
+
+// pointLines renders points in line protocol
+func pointLines(points []Point) []string {
+	lines := make([]string, len(points))
+	for i, p := range points {
+		lines[i] = p.String()
+	}
+	return lines
+}
+
+func TestBoundedBuffer(t *testing.T) {
+	c := newTestCollector("http://localhost:8086", http.DefaultClient)
+	c.bufferSize = 3
+
+	for i := range 5 {
+		c.Record("items", nil, map[string]interface{}{"v": i})
+	}
+
+	if len(c.metrics) != 3 {
+		t.Errorf("buffered %d metrics, want 3", len(c.metrics))
+	}
+	if got := c.Dropped(); got != 2 {
+		t.Errorf("Dropped() = %d, want 2", got)
+	}
+}
+
+func TestFlushBatches(t *testing.T) {
+	server := newRecordingServer(t)
+	c := newTestCollector(server.URL, server.Client())
+	c.batchSize = 2
+	c.metrics = testPoints("a", "b", "c", "d", "e")
+
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	_, bodies := server.stats()
+	want := []string{"a v=1i 1\nb v=2i 1\n", "c v=3i 1\nd v=4i 1\n", "e v=5i 1\n"}
+	if fmt.Sprint(bodies) != fmt.Sprint(want) {
+		t.Errorf("bodies = %q, want %q", bodies, want)
+	}
+}
+
+func TestFlushRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantErr      bool
+		wantRequests int
+		wantBuffered int
+		wantDropped  int
+	}{
+		{"succeeds first time", nil, false, 1, 0, 0},
+		{"retries server errors", []int{503, 500}, false, 3, 0, 0},
+		{"retries rate limiting", []int{429}, false, 2, 0, 0},
+		{"requeues after retries run out", []int{503, 503, 503, 503}, true, 4, 2, 0},
+		{"drops rejected batch", []int{400}, true, 1, 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newRecordingServer(t, tt.statuses...)
+			c := newTestCollector(server.URL, server.Client())
+			c.retryBackoff = time.Millisecond
+			c.metrics = testPoints("a", "b")
+
+			err := c.Flush()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Flush() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			requests, _ := server.stats()
+			if requests != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", requests, tt.wantRequests)
+			}
+			if len(c.metrics) != tt.wantBuffered {
+				t.Errorf("buffered = %d, want %d", len(c.metrics), tt.wantBuffered)
+			}
+			if got := c.Dropped(); got != tt.wantDropped {
+				t.Errorf("Dropped() = %d, want %d", got, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestRequeueKeepsNewestWithinBound(t *testing.T) {
+	c := newTestCollector("http://localhost:8086", http.DefaultClient)
+	c.bufferSize = 3
+	c.metrics = testPoints("new1", "new2")
+
+	c.requeue(testPoints("old1", "old2", "old3"))
+
+	var got []string
+	for _, p := range c.metrics {
+		got = append(got, p.Measurement)
+	}
+	if want := []string{"old3", "new1", "new2"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("metrics = %v, want %v", got, want)
+	}
+	if got := c.Dropped(); got != 2 {
+		t.Errorf("Dropped() = %d, want 2", got)
+	}
+}
+
+func TestBackgroundFlush(t *testing.T) {
+	server := newRecordingServer(t)
+	c := newTestCollector(server.URL, server.Client())
+	c.batchSize = 10
+	c.flushInterval = time.Hour // only the batch threshold can trigger a flush
+
+	var flushErrors atomic.Int32
+	c.Start(context.Background(), func(error) { flushErrors.Add(1) })
+
+	// Record from several goroutines while the flusher runs
+	var wg sync.WaitGroup
+	for g := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 25 {
+				c.Record("items", nil, map[string]interface{}{"v": g*100 + i})
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The batch threshold must trigger a flush without waiting for Close
+	deadline := time.Now().Add(time.Second)
+	for requests, _ := server.stats(); requests == 0; requests, _ = server.stats() {
+		if time.Now().After(deadline) {
+			t.Fatal("no background flush after reaching the batch size")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	_, bodies := server.stats()
+	lines := 0
+	for _, body := range bodies {
+		lines += strings.Count(body, "\n")
+	}
+	// 100 recorded metrics plus run_duration from Close
+	if lines != 101 {
+		t.Errorf("sent %d lines, want 101", lines)
+	}
+	if n := flushErrors.Load(); n != 0 {
+		t.Errorf("background flush errors = %d, want 0", n)
+	}
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// maxReservoirSize bounds the durations kept per histogram series between
+// flushes. Past it, percentiles are estimated from a uniform sample.
+const maxReservoirSize = 1024
+
+// Percentiles are the quantiles sent with every histogram
+var Percentiles = []float64{0.5, 0.95, 0.99}
+
+// RecordHistogram records a latency in a histogram. Each flush sends one
+// point per series with the bucket counts for LatencyBuckets, the
+// percentiles, and the count, sum, min and max.
+func (c *Collector) RecordHistogram(name string, duration time.Duration, tags map[string]string) {
+	if c.live != nil {
+		c.live.observe(name, tags, duration.Seconds(), LatencyBuckets)
+	}
+	c.aggregate(aggHistogram, name, tags, func(a *aggregate) {
+		if a.buckets == nil {
+			a.buckets = make([]int64, len(LatencyBuckets))
+		}
+		if i, _ := slices.BinarySearch(LatencyBuckets, duration.Seconds()); i < len(LatencyBuckets) {
+			a.buckets[i]++
+		}
+
+		if a.samples == 0 || duration < a.min {
+			a.min = duration
+		}
+		if duration > a.max {
+			a.max = duration
+		}
+		a.sum += duration
+		a.samples++
+
+		// Reservoir sampling keeps every duration equally likely to be in
+		// the sample, however many are recorded
+		if len(a.reservoir) < maxReservoirSize {
+			a.reservoir = append(a.reservoir, duration)
+		} else if i := rand.Int64N(a.samples); i < maxReservoirSize {
+			a.reservoir[i] = duration
+		}
+	})
+}
+
+// histogramFields renders a histogram as cumulative bucket counts named after
+// their upper bound ("le_250ms", ..., "le_inf"), percentiles ("p50_ms", ...)
+// and the count, sum, min and max
+func (a *aggregate) histogramFields() map[string]interface{} {
+	fields := map[string]interface{}{
+		"count":  a.samples,
+		"sum_ms": a.sum.Milliseconds(),
+		"min_ms": a.min.Milliseconds(),
+		"max_ms": a.max.Milliseconds(),
+		"le_inf": a.samples,
+	}
+
+	var cumulative int64
+	for i, bound := range LatencyBuckets {
+		cumulative += a.buckets[i]
+		fields[bucketField(bound)] = cumulative
+	}
+
+	sorted := slices.Clone(a.reservoir)
+	slices.Sort(sorted)
+	for _, q := range Percentiles {
+		fields[percentileField(q)] = percentile(sorted, q).Milliseconds()
+	}
+	return fields
+}
+
+// bucketField names the field holding the count of durations up to bound
+// seconds
+func bucketField(bound float64) string {
+	return "le_" + strconv.FormatInt(int64(math.Round(bound*1000)), 10) + "ms"
+}
+
+// percentileField names the field holding quantile q, e.g. "p95_ms"
+func percentileField(q float64) string {
+	return "p" + strconv.FormatFloat(q*100, 'f', -1, 64) + "_ms"
+}
+
+// percentile returns the nearest-rank quantile q of sorted durations
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(q * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
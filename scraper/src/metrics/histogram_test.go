@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordHistogram(t *testing.T) {
+	c := newTestCollector("http://localhost:8086", http.DefaultClient)
+
+	// 1ms .. 100ms: 5 fall in le_5ms, 10 in le_10ms, ...
+	for i := 1; i <= 100; i++ {
+		c.RecordHistogram("request", time.Duration(i)*time.Millisecond, map[string]string{"endpoint": "a"})
+	}
+
+	points := c.take()
+	if len(points) != 1 {
+		t.Fatalf("expected 1 point, got %d", len(points))
+	}
+	p := points[0]
+	if p.Kind != KindHistogram {
+		t.Errorf("Kind = %v, want KindHistogram", p.Kind)
+	}
+
+	tests := []struct {
+		field string
+		want  int64
+	}{
+		{"count", 100},
+		{"sum_ms", 5050},
+		{"min_ms", 1},
+		{"max_ms", 100},
+		{"p50_ms", 50},
+		{"p95_ms", 95},
+		{"p99_ms", 99},
+		{"le_5ms", 5},
+		{"le_10ms", 10},
+		{"le_50ms", 50},
+		{"le_100ms", 100},
+		{"le_120000ms", 100},
+		{"le_inf", 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			if got := p.Fields[tt.field]; got != tt.want {
+				t.Errorf("%s = %v, want %d", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistogramReservoirBounded(t *testing.T) {
+	c := newTestCollector("http://localhost:8086", http.DefaultClient)
+
+	for range 10 * maxReservoirSize {
+		c.RecordHistogram("request", time.Second, nil)
+	}
+
+	c.mu.Lock()
+	for _, a := range c.aggregates {
+		if len(a.reservoir) != maxReservoirSize {
+			t.Errorf("reservoir holds %d durations, want %d", len(a.reservoir), maxReservoirSize)
+		}
+	}
+	c.mu.Unlock()
+
+	p := c.take()[0]
+	if p.Fields["count"] != int64(10*maxReservoirSize) {
+		t.Errorf("count = %v, want %d", p.Fields["count"], 10*maxReservoirSize)
+	}
+	if p.Fields["p99_ms"] != int64(1000) {
+		t.Errorf("p99_ms = %v, want 1000", p.Fields["p99_ms"])
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4}
+
+	tests := []struct {
+		name   string
+		values []time.Duration
+		q      float64
+		want   time.Duration
+	}{
+		{"empty", nil, 0.5, 0},
+		{"median rounds up the rank", sorted, 0.5, 2},
+		{"high quantile", sorted, 0.99, 4},
+		{"zero quantile is the minimum", sorted, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.values, tt.q); got != tt.want {
+				t.Errorf("percentile(%v, %v) = %v, want %v", tt.values, tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistogramPrometheusBuckets(t *testing.T) {
+	c := newTestCollector("", nil)
+	c.RecordHistogram("ekalathi_request", 30*time.Millisecond, map[string]string{"endpoint": "fetch-regions", "status": "200"})
+
+	var sb strings.Builder
+	c.live.WriteTo(&sb)
+	out := sb.String()
+
+	for _, want := range []string{
+		`scraper_ekalathi_request_duration_seconds_bucket{endpoint="fetch-regions",status="200",le="0.025"} 0`,
+		`scraper_ekalathi_request_duration_seconds_bucket{endpoint="fetch-regions",status="200",le="0.05"} 1`,
+		`scraper_ekalathi_request_duration_seconds_count{endpoint="fetch-regions",status="200"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPoint is wrapped by every error for a point that cannot be
+// written in line protocol
+var ErrInvalidPoint = errors.New("invalid point")
+
+// maxStringField is the longest string field value InfluxDB accepts
+const maxStringField = 64 * 1024
+
+var (
+	measurementEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// EncodeLine renders p in InfluxDB line protocol, without a trailing newline:
+//
+//	measurement,tag1=a,tag2=b field1=1i,field2="x" 1700000000000000000
+//
+// Tags and fields are sorted by key so the same point always encodes to the
+// same line. Tags with an empty value are left out, since InfluxDB rejects
+// them. A zero Time leaves out the timestamp so the server assigns one.
+func EncodeLine(p Point) (string, error) {
+	line, err := AppendLine(nil, p)
+	return string(line), err
+}
+
+// AppendLine appends p in line protocol to dst, see EncodeLine. On error dst
+// is returned unchanged.
+func AppendLine(dst []byte, p Point) ([]byte, error) {
+	if err := validatePoint(p); err != nil {
+		return dst, err
+	}
+	start := len(dst)
+
+	dst = append(dst, measurementEscaper.Replace(p.Measurement)...)
+
+	for _, k := range slices.Sorted(maps.Keys(p.Tags)) {
+		if p.Tags[k] == "" {
+			continue
+		}
+		dst = append(dst, ',')
+		dst = append(dst, escapeTag(k)...)
+		dst = append(dst, '=')
+		dst = append(dst, escapeTag(p.Tags[k])...)
+	}
+
+	for i, k := range slices.Sorted(maps.Keys(p.Fields)) {
+		if i == 0 {
+			dst = append(dst, ' ')
+		} else {
+			dst = append(dst, ',')
+		}
+		value, err := formatField(p.Fields[k])
+		if err != nil {
+			return dst[:start], fmt.Errorf("%w: field %q: %v", ErrInvalidPoint, k, err)
+		}
+		dst = append(dst, escapeTag(k)...)
+		dst = append(dst, '=')
+		dst = append(dst, value...)
+	}
+
+	if !p.Time.IsZero() {
+		dst = append(dst, ' ')
+		dst = strconv.AppendInt(dst, p.Time.UnixNano(), 10)
+	}
+	return dst, nil
+}
+
+// encodeLines renders points one per line, leaving out any that cannot be
+// encoded. Record and the typed helpers reject those before they are
+// buffered, so none are expected here.
+func encodeLines(points []Point) []byte {
+	var buf []byte
+	for _, p := range points {
+		line, err := AppendLine(buf, p)
+		if err != nil {
+			continue
+		}
+		buf = append(line, '\n')
+	}
+	return buf
+}
+
+// validatePoint checks the names in p. Field values are checked as they are
+// formatted.
+func validatePoint(p Point) error {
+	if p.Measurement == "" {
+		return fmt.Errorf("%w: empty measurement", ErrInvalidPoint)
+	}
+	if len(p.Fields) == 0 {
+		return fmt.Errorf("%w: %q has no fields", ErrInvalidPoint, p.Measurement)
+	}
+	if err := checkName("measurement", p.Measurement); err != nil {
+		return err
+	}
+	if err := validateTags(p.Tags); err != nil {
+		return err
+	}
+	for k := range p.Fields {
+		if err := checkName("field key", k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateTags(tags map[string]string) error {
+	for k, v := range tags {
+		if err := checkName("tag key", k); err != nil {
+			return err
+		}
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("%w: tag %q has a newline in its value", ErrInvalidPoint, k)
+		}
+	}
+	return nil
+}
+
+func checkName(kind, name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: empty %s", ErrInvalidPoint, kind)
+	}
+	if strings.ContainsAny(name, "\r\n") {
+		return fmt.Errorf("%w: %s %q has a newline", ErrInvalidPoint, kind, name)
+	}
+	return nil
+}
+
+// escapeTag escapes a tag key, tag value or field key
+func escapeTag(s string) string {
+	return tagEscaper.Replace(s)
+}
+
+// formatField renders a field value: integers with an "i" suffix, unsigned
+// integers with "u", floats in the shortest form that parses back to the same
+// value, and strings quoted
+func formatField(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case int:
+		return strconv.FormatInt(int64(val), 10) + "i", nil
+	case int8:
+		return strconv.FormatInt(int64(val), 10) + "i", nil
+	case int16:
+		return strconv.FormatInt(int64(val), 10) + "i", nil
+	case int32:
+		return strconv.FormatInt(int64(val), 10) + "i", nil
+	case int64:
+		return strconv.FormatInt(val, 10) + "i", nil
+	case uint:
+		return strconv.FormatUint(uint64(val), 10) + "u", nil
+	case uint8:
+		return strconv.FormatUint(uint64(val), 10) + "u", nil
+	case uint16:
+		return strconv.FormatUint(uint64(val), 10) + "u", nil
+	case uint32:
+		return strconv.FormatUint(uint64(val), 10) + "u", nil
+	case uint64:
+		return strconv.FormatUint(val, 10) + "u", nil
+	case float32:
+		return formatFloatField(float64(val), 32)
+	case float64:
+		return formatFloatField(val, 64)
+	case string:
+		if len(val) > maxStringField {
+			return "", fmt.Errorf("string of %d bytes exceeds %d", len(val), maxStringField)
+		}
+		return `"` + stringEscaper.Replace(val) + `"`, nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	default:
+		return "", fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+func formatFloatField(v float64, bitSize int) (string, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "", fmt.Errorf("%v is not representable", v)
+	}
+	return strconv.FormatFloat(v, 'g', -1, bitSize), nil
+}
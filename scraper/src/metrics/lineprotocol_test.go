@@ -0,0 +1,199 @@
+package metrics
+
+import (
+	"errors"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func TestEscapeTag(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain string", "hello", "hello"},
+		{"spaces escaped", "hello world", `hello\ world`},
+		{"commas escaped", "a,b", `a\,b`},
+		{"equals escaped", "a=b", `a\=b`},
+		{"multiple special chars", "a=b, c d", `a\=b\,\ c\ d`},
+		{"backslash escaped", `a\b`, `a\\b`},
+		{"quotes kept", `"a"`, `"a"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := escapeTag(tt.input)
+			if got != tt.want {
+				t.Errorf("escapeTag(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatField(t *testing.T) {
+	tenth, fifth := 0.1, 0.2
+
+	tests := []struct {
+		name    string
+		input   interface{}
+		want    string
+		wantErr bool
+	}{
+		{"int", 123, "123i", false},
+		{"int64", int64(456), "456i", false},
+		{"negative int32", int32(-7), "-7i", false},
+		{"uint", uint(8), "8u", false},
+		{"max uint64", uint64(math.MaxUint64), "18446744073709551615u", false},
+		{"float64", 3.14, "3.14", false},
+		{"float64 whole number", 3.0, "3", false},
+		{"float64 keeps precision", tenth + fifth, "0.30000000000000004", false},
+		{"float64 large", 1e21, "1e+21", false},
+		{"float32", float32(0.1), "0.1", false},
+		{"string", "hello", `"hello"`, false},
+		{"string escaped", `say "hi" \o/`, `"say \"hi\" \\o/"`, false},
+		{"bool true", true, "true", false},
+		{"bool false", false, "false", false},
+		{"NaN", math.NaN(), "", true},
+		{"infinity", math.Inf(1), "", true},
+		{"oversized string", strings.Repeat("x", maxStringField+1), "", true},
+		{"unsupported type", time.Second, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatField(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("formatField(%v) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("formatField(%v) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// goldenPoints are encoded, one line each, into testdata/lineprotocol.golden
+var goldenPoints = []Point{
+	{Measurement: "cpu", Fields: map[string]interface{}{"usage": 42}, Time: time.Unix(0, 1000)},
+	{
+		Measurement: "cpu",
+		Tags:        map[string]string{"region": "eu", "host": "a", "az": "1"},
+		Fields:      map[string]interface{}{"user": 0.5, "system": 0.25, "idle": int64(99), "count": uint32(3)},
+		Time:        time.Unix(1700000000, 123456789),
+	},
+	{Measurement: "no timestamp", Fields: map[string]interface{}{"v": true}},
+	{
+		Measurement: "weird,measurement name",
+		Tags:        map[string]string{"tag key": "a=b,c", "path": `C:\data`, "empty": ""},
+		Fields:      map[string]interface{}{"field,key=": `quote " and \ backslash`},
+		Time:        time.Unix(0, 1),
+	},
+	{
+		Measurement: "scraper",
+		Tags:        map[string]string{"metric": "fetch_products", "phase": "products"},
+		Fields:      map[string]interface{}{"duration_ms": int64(150), "min_ms": int64(100), "max_ms": int64(200), "sum_ms": int64(300), "samples": int64(2)},
+		Time:        time.Unix(1700000000, 0),
+	},
+	{Measurement: "floats", Fields: map[string]interface{}{"tiny": 1e-7, "third": 1.0 / 3, "neg": -2.5}, Time: time.Unix(0, 1)},
+}
+
+func TestEncodeLineGolden(t *testing.T) {
+	var sb strings.Builder
+	for _, p := range goldenPoints {
+		// Encoding must not depend on map iteration order
+		first, err := EncodeLine(p)
+		if err != nil {
+			t.Fatalf("EncodeLine(%+v) error = %v", p, err)
+		}
+		for range 20 {
+			if again, _ := EncodeLine(p); again != first {
+				t.Fatalf("EncodeLine is not deterministic: %q then %q", first, again)
+			}
+		}
+		sb.WriteString(first)
+		sb.WriteString("\n")
+	}
+
+	golden := filepath.Join("testdata", "lineprotocol.golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(sb.String()), 0o644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if got := sb.String(); got != string(want) {
+		t.Errorf("encoded lines differ from %s (run with -update to accept):\ngot:\n%s\nwant:\n%s", golden, got, want)
+	}
+}
+
+func TestEncodeLineInvalid(t *testing.T) {
+	fields := map[string]interface{}{"v": 1}
+
+	tests := []struct {
+		name  string
+		point Point
+	}{
+		{"empty measurement", Point{Fields: fields}},
+		{"no fields", Point{Measurement: "cpu"}},
+		{"empty fields", Point{Measurement: "cpu", Fields: map[string]interface{}{}}},
+		{"newline in measurement", Point{Measurement: "cpu\nmem", Fields: fields}},
+		{"empty tag key", Point{Measurement: "cpu", Tags: map[string]string{"": "a"}, Fields: fields}},
+		{"newline in tag value", Point{Measurement: "cpu", Tags: map[string]string{"host": "a\nb"}, Fields: fields}},
+		{"empty field key", Point{Measurement: "cpu", Fields: map[string]interface{}{"": 1}}},
+		{"NaN field", Point{Measurement: "cpu", Fields: map[string]interface{}{"v": math.NaN()}}},
+		{"unsupported field", Point{Measurement: "cpu", Fields: map[string]interface{}{"v": []int{1}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := EncodeLine(tt.point)
+			if !errors.Is(err, ErrInvalidPoint) {
+				t.Errorf("EncodeLine() error = %v, want ErrInvalidPoint", err)
+			}
+			if line != "" {
+				t.Errorf("EncodeLine() = %q, want nothing on error", line)
+			}
+		})
+	}
+}
+
+func TestEncodeLines(t *testing.T) {
+	points := []Point{
+		{Measurement: "a", Fields: map[string]interface{}{"v": 1}, Time: time.Unix(0, 1)},
+		{Measurement: "bad", Fields: map[string]interface{}{"v": math.Inf(-1)}, Time: time.Unix(0, 1)},
+		{Measurement: "b", Fields: map[string]interface{}{"v": 2}, Time: time.Unix(0, 1)},
+	}
+
+	if got, want := string(encodeLines(points)), "a v=1i 1\nb v=2i 1\n"; got != want {
+		t.Errorf("encodeLines() = %q, want %q", got, want)
+	}
+}
+
+func TestRecordRejectsInvalidPoints(t *testing.T) {
+	c := newTestCollector("http://localhost:8086", nil)
+
+	if err := c.Record("cpu", nil, nil); !errors.Is(err, ErrInvalidPoint) {
+		t.Errorf("Record() without fields error = %v, want ErrInvalidPoint", err)
+	}
+	c.RecordGauge("ratio", math.NaN(), nil)
+	c.RecordCount("requests", 1, map[string]string{"path": "a\nb"})
+
+	if points := c.take(); len(points) != 0 {
+		t.Errorf("invalid points should not be buffered, got %v", points)
+	}
+	if got := c.Dropped(); got != 3 {
+		t.Errorf("Dropped() = %d, want 3", got)
+	}
+}
@@ -1,157 +1,179 @@
 package metrics
 
 import (
-	"bytes"
-	"fmt"
+	"context"
+	"math"
 	"net/http"
-	"os"
-	"strings"
+	"sync"
 	"time"
 )
 
-// Collector collects metrics and pushes them to Telegraf
+// Collector collects metrics and pushes them to a Sink chosen by the scheme
+// of Options.URL, Telegraf over HTTP by default. It is safe for concurrent
+// use. The typed helpers also update live values exposed in the Prometheus
+// format by Handler, whether or not pushing is enabled.
 type Collector struct {
 	url       string
 	client    *http.Client
 	startTime time.Time
-	metrics   []string
+	live      *registry
+
+	sinkOnce sync.Once
+	sink     Sink
+	sinkErr  error
+
+	// Buffered points and pre-aggregated series, bounded together by
+	// bufferSize. Once bufferSize are waiting, new ones are dropped and
+	// counted.
+	mu         sync.Mutex
+	metrics    []Point
+	aggregates map[string]*aggregate
+	dropped    int
+	bufferSize int
+	batchSize  int
+
+	// Background flushing, see Start
+	flushMu       sync.Mutex
+	flushInterval time.Duration
+	retryBackoff  time.Duration
+	full          chan struct{}
+	stop          context.CancelFunc
+	stopped       chan struct{}
 }
 
-// New creates a new metrics collector
-// Uses METRICS_URL env var, falls back to disabled if not set
-func New() *Collector {
-	url := os.Getenv("METRICS_URL")
-	return &Collector{
-		url:       url,
-		client:    &http.Client{Timeout: 10 * time.Second},
-		startTime: time.Now(),
-		metrics:   make([]string, 0),
-	}
+// Options configures a Collector. Zero sizes and intervals use the defaults.
+type Options struct {
+	URL           string // sink URL, see NewSink; empty disables pushing
+	BufferSize    int
+	BatchSize     int
+	FlushInterval time.Duration
 }
 
-// Record adds a metric to the batch
-// Uses InfluxDB line protocol: measurement,tag=value field=value timestamp
-func (c *Collector) Record(measurement string, tags map[string]string, fields map[string]interface{}) {
-	if c.url == "" {
-		return // Metrics disabled
+// NewWithOptions creates a collector from opts. An invalid URL is reported
+// by Flush.
+func NewWithOptions(opts Options) *Collector {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
 	}
-
-	var sb strings.Builder
-	sb.WriteString(measurement)
-
-	// Add tags
-	for k, v := range tags {
-		sb.WriteString(",")
-		sb.WriteString(k)
-		sb.WriteString("=")
-		sb.WriteString(escapeTag(v))
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultFlushInterval
 	}
 
-	sb.WriteString(" ")
+	c := &Collector{
+		url:           opts.URL,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		startTime:     time.Now(),
+		live:          newRegistry(),
+		metrics:       make([]Point, 0),
+		bufferSize:    opts.BufferSize,
+		batchSize:     opts.BatchSize,
+		flushInterval: opts.FlushInterval,
+		retryBackoff:  defaultRetryBackoff,
+		full:          make(chan struct{}, 1),
+	}
+	if opts.URL != "" {
+		c.resolveSink()
+	}
+	return c
+}
 
-	// Add fields
-	first := true
-	for k, v := range fields {
-		if !first {
-			sb.WriteString(",")
+// resolveSink creates the sink for the collector's URL on first use
+func (c *Collector) resolveSink() (Sink, error) {
+	c.sinkOnce.Do(func() {
+		if c.sink == nil {
+			c.sink, c.sinkErr = NewSink(c.url, c.client)
 		}
-		first = false
-		sb.WriteString(k)
-		sb.WriteString("=")
-		sb.WriteString(formatField(v))
-	}
+	})
+	return c.sink, c.sinkErr
+}
 
-	// Add timestamp (nanoseconds)
-	sb.WriteString(" ")
-	sb.WriteString(fmt.Sprintf("%d", time.Now().UnixNano()))
+// Record adds a metric to the batch
+// Modelled on InfluxDB line protocol: measurement,tag=value field=value timestamp
+// Points that cannot be encoded are counted as dropped and the error wrapping
+// ErrInvalidPoint is returned.
+func (c *Collector) Record(measurement string, tags map[string]string, fields map[string]interface{}) error {
+	if c.url == "" {
+		return nil // Metrics disabled
+	}
 
-	c.metrics = append(c.metrics, sb.String())
+	p := Point{Measurement: measurement, Tags: tags, Fields: fields, Time: time.Now()}
+	if _, err := AppendLine(nil, p); err != nil {
+		c.drop(1)
+		return err
+	}
+	c.enqueue(p)
+	return nil
 }
 
 // RecordDuration records a duration metric
 func (c *Collector) RecordDuration(name string, duration time.Duration, tags map[string]string) {
-	c.Record("scraper", mergeTags(tags, map[string]string{"metric": name}), map[string]interface{}{
-		"duration_ms": duration.Milliseconds(),
+	if c.live != nil {
+		c.live.observe(name, tags, duration.Seconds(), DurationBuckets)
+	}
+	c.aggregate(aggDuration, name, tags, func(a *aggregate) {
+		if a.samples == 0 || duration < a.min {
+			a.min = duration
+		}
+		if duration > a.max {
+			a.max = duration
+		}
+		a.sum += duration
+		a.samples++
 	})
 }
 
 // RecordCount records a count metric
 func (c *Collector) RecordCount(name string, count int, tags map[string]string) {
-	c.Record("scraper", mergeTags(tags, map[string]string{"metric": name}), map[string]interface{}{
-		"count": count,
+	if c.live != nil {
+		c.live.add(name, tags, float64(count))
+	}
+	c.aggregate(aggCount, name, tags, func(a *aggregate) {
+		a.count += int64(count)
 	})
 }
 
 // RecordGauge records a gauge metric
 func (c *Collector) RecordGauge(name string, value float64, tags map[string]string) {
-	c.Record("scraper", mergeTags(tags, map[string]string{"metric": name}), map[string]interface{}{
-		"value": value,
-	})
-}
-
-// TotalDuration returns the total duration since collector creation
-func (c *Collector) TotalDuration() time.Duration {
-	return time.Since(c.startTime)
-}
-
-// Flush sends all collected metrics to Telegraf
-func (c *Collector) Flush() error {
-	if c.url == "" || len(c.metrics) == 0 {
-		return nil
+	if c.live != nil {
+		c.live.set(name, tags, value)
 	}
-
-	// Record total run duration
-	c.Record("scraper", map[string]string{"metric": "run_duration"}, map[string]interface{}{
-		"duration_ms": c.TotalDuration().Milliseconds(),
-	})
-
-	body := strings.Join(c.metrics, "\n")
-	req, err := http.NewRequest("POST", c.url, bytes.NewBufferString(body))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		// Prometheus can show these but line protocol cannot carry them
+		if c.url != "" {
+			c.drop(1)
+		}
+		return
 	}
-	req.Header.Set("Content-Type", "text/plain")
+	c.aggregate(aggGauge, name, tags, func(a *aggregate) {
+		a.value = value
+	})
+}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send metrics: %w", err)
+// GaugeFunc exposes a gauge whose value is read from fn on every scrape of
+// Handler. It is not pushed to Telegraf.
+func (c *Collector) GaugeFunc(name string, tags map[string]string, fn func() float64) {
+	if c.live != nil {
+		c.live.setFunc(name, tags, fn)
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("metrics endpoint returned status %d", resp.StatusCode)
+// Handler serves the live metrics in the Prometheus text format
+func (c *Collector) Handler() http.Handler {
+	if c.live == nil {
+		c.live = newRegistry()
 	}
-
-	// Clear metrics after successful push
-	c.metrics = c.metrics[:0]
-	return nil
+	return c.live
 }
 
-// Helper functions
-
-func escapeTag(s string) string {
-	s = strings.ReplaceAll(s, " ", "\\ ")
-	s = strings.ReplaceAll(s, ",", "\\,")
-	s = strings.ReplaceAll(s, "=", "\\=")
-	return s
+// TotalDuration returns the total duration since collector creation
+func (c *Collector) TotalDuration() time.Duration {
+	return time.Since(c.startTime)
 }
 
-func formatField(v interface{}) string {
-	switch val := v.(type) {
-	case int:
-		return fmt.Sprintf("%di", val)
-	case int64:
-		return fmt.Sprintf("%di", val)
-	case float64:
-		return fmt.Sprintf("%f", val)
-	case string:
-		return fmt.Sprintf("%q", val)
-	case bool:
-		return fmt.Sprintf("%t", val)
-	default:
-		return fmt.Sprintf("%v", val)
-	}
-}
+// Helper functions
 
 func mergeTags(base, additional map[string]string) map[string]string {
 	result := make(map[string]string)
@@ -9,53 +9,6 @@ import (
 	"time"
 )
 
-func TestEscapeTag(t *testing.T) {
-	tests := []struct {
-		name  string
-		input string
-		want  string
-	}{
-		{"plain string", "hello", "hello"},
-		{"spaces escaped", "hello world", `hello\ world`},
-		{"commas escaped", "a,b", `a\,b`},
-		{"equals escaped", "a=b", `a\=b`},
-		{"multiple special chars", "a=b, c d", `a\=b\,\ c\ d`},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := escapeTag(tt.input)
-			if got != tt.want {
-				t.Errorf("escapeTag(%q) = %q, want %q", tt.input, got, tt.want)
-			}
-		})
-	}
-}
-
-func TestFormatField(t *testing.T) {
-	tests := []struct {
-		name  string
-		input interface{}
-		want  string
-	}{
-		{"int", 123, "123i"},
-		{"int64", int64(456), "456i"},
-		{"float64", 3.14, "3.140000"},
-		{"string", "hello", `"hello"`},
-		{"bool true", true, "true"},
-		{"bool false", false, "false"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := formatField(tt.input)
-			if got != tt.want {
-				t.Errorf("formatField(%v) = %q, want %q", tt.input, got, tt.want)
-			}
-		})
-	}
-}
-
 func TestMergeTags(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -110,7 +63,7 @@ func TestRecord(t *testing.T) {
 	t.Run("with URL set, metric line appended", func(t *testing.T) {
 		c := &Collector{
 			url:     "http://localhost:8086",
-			metrics: make([]string, 0),
+			metrics: make([]Point, 0),
 		}
 
 		c.Record("cpu", map[string]string{"host": "server1"}, map[string]interface{}{
@@ -121,7 +74,7 @@ func TestRecord(t *testing.T) {
 			t.Fatalf("expected 1 metric, got %d", len(c.metrics))
 		}
 
-		line := c.metrics[0]
+		line := c.metrics[0].String()
 		if !strings.HasPrefix(line, "cpu,host=server1 ") {
 			t.Errorf("metric line should start with measurement and tags, got %q", line)
 		}
@@ -138,7 +91,7 @@ func TestRecord(t *testing.T) {
 	t.Run("with empty URL, no-op", func(t *testing.T) {
 		c := &Collector{
 			url:     "",
-			metrics: make([]string, 0),
+			metrics: make([]Point, 0),
 		}
 
 		c.Record("cpu", map[string]string{"host": "server1"}, map[string]interface{}{
@@ -154,16 +107,17 @@ func TestRecord(t *testing.T) {
 func TestRecordDuration(t *testing.T) {
 	c := &Collector{
 		url:     "http://localhost:8086",
-		metrics: make([]string, 0),
+		metrics: make([]Point, 0),
 	}
 
 	c.RecordDuration("fetch_products", 150*time.Millisecond, nil)
 
-	if len(c.metrics) != 1 {
-		t.Fatalf("expected 1 metric, got %d", len(c.metrics))
+	lines := pointLines(c.take())
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 metric, got %d", len(lines))
 	}
 
-	line := c.metrics[0]
+	line := lines[0]
 	if !strings.Contains(line, "scraper,") {
 		t.Errorf("measurement should be 'scraper', got %q", line)
 	}
@@ -178,16 +132,17 @@ func TestRecordDuration(t *testing.T) {
 func TestRecordCount(t *testing.T) {
 	c := &Collector{
 		url:     "http://localhost:8086",
-		metrics: make([]string, 0),
+		metrics: make([]Point, 0),
 	}
 
 	c.RecordCount("products_scraped", 42, nil)
 
-	if len(c.metrics) != 1 {
-		t.Fatalf("expected 1 metric, got %d", len(c.metrics))
+	lines := pointLines(c.take())
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 metric, got %d", len(lines))
 	}
 
-	line := c.metrics[0]
+	line := lines[0]
 	if !strings.Contains(line, "scraper,") {
 		t.Errorf("measurement should be 'scraper', got %q", line)
 	}
@@ -202,23 +157,24 @@ func TestRecordCount(t *testing.T) {
 func TestRecordGauge(t *testing.T) {
 	c := &Collector{
 		url:     "http://localhost:8086",
-		metrics: make([]string, 0),
+		metrics: make([]Point, 0),
 	}
 
 	c.RecordGauge("error_rate", 0.05, nil)
 
-	if len(c.metrics) != 1 {
-		t.Fatalf("expected 1 metric, got %d", len(c.metrics))
+	lines := pointLines(c.take())
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 metric, got %d", len(lines))
 	}
 
-	line := c.metrics[0]
+	line := lines[0]
 	if !strings.Contains(line, "scraper,") {
 		t.Errorf("measurement should be 'scraper', got %q", line)
 	}
 	if !strings.Contains(line, "metric=error_rate") {
 		t.Errorf("should contain metric tag, got %q", line)
 	}
-	if !strings.Contains(line, "value=0.050000") {
+	if !strings.Contains(line, "value=0.05") {
 		t.Errorf("should contain value field, got %q", line)
 	}
 }
@@ -239,7 +195,10 @@ func TestFlush(t *testing.T) {
 			url:       server.URL,
 			client:    server.Client(),
 			startTime: time.Now(),
-			metrics:   []string{"cpu,host=a usage=42i 1000", "mem,host=a free=1024i 1000"},
+			metrics: []Point{
+				{Measurement: "cpu", Tags: map[string]string{"host": "a"}, Fields: map[string]interface{}{"usage": 42}, Time: time.Unix(0, 1000)},
+				{Measurement: "mem", Tags: map[string]string{"host": "a"}, Fields: map[string]interface{}{"free": 1024}, Time: time.Unix(0, 1000)},
+			},
 		}
 
 		err := c.Flush()
@@ -268,7 +227,7 @@ func TestFlush(t *testing.T) {
 	t.Run("empty URL returns nil, no HTTP call", func(t *testing.T) {
 		c := &Collector{
 			url:     "",
-			metrics: []string{"cpu usage=42i 1000"},
+			metrics: testPoints("cpu"),
 		}
 
 		err := c.Flush()
@@ -280,7 +239,7 @@ func TestFlush(t *testing.T) {
 	t.Run("no metrics returns nil, no HTTP call", func(t *testing.T) {
 		c := &Collector{
 			url:     "http://localhost:8086",
-			metrics: make([]string, 0),
+			metrics: make([]Point, 0),
 		}
 
 		err := c.Flush()
@@ -299,7 +258,7 @@ func TestFlush(t *testing.T) {
 			url:       server.URL,
 			client:    server.Client(),
 			startTime: time.Now(),
-			metrics:   []string{"cpu usage=42i 1000"},
+			metrics:   testPoints("cpu"),
 		}
 
 		err := c.Flush()
@@ -312,13 +271,11 @@ func TestFlush(t *testing.T) {
 	})
 }
 
-func TestNewCollectorDisabledWithoutEnv(t *testing.T) {
-	t.Setenv("METRICS_URL", "")
-
-	c := New()
+func TestNewCollectorDisabledWithoutURL(t *testing.T) {
+	c := NewWithOptions(Options{})
 
 	if c.url != "" {
-		t.Errorf("url should be empty without METRICS_URL, got %q", c.url)
+		t.Errorf("url should be empty without a URL, got %q", c.url)
 	}
 
 	// Record should be a no-op
@@ -0,0 +1,230 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// namespace prefixes every metric exposed to Prometheus
+const namespace = "scraper"
+
+// DurationBuckets are the histogram buckets, in seconds, for durations
+// recorded with RecordDuration. They span single requests up to multi-hour
+// runs.
+var DurationBuckets = []float64{0.1, 0.5, 1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200, 14400}
+
+// LatencyBuckets are the histogram buckets, in seconds, for latencies
+// recorded with RecordHistogram. They suit single HTTP requests.
+var LatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// registry holds the live values exposed at /metrics. Unlike the line
+// protocol batch it is never cleared, so counters keep growing across runs
+// in a long-running process.
+type registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// family is one metric name with all its label combinations
+type family struct {
+	name   string
+	typ    string
+	help   string
+	series map[string]*series // keyed by the rendered label set
+}
+
+type series struct {
+	labels  string // rendered as {a="1",b="2"}, empty without labels
+	value   float64
+	fn      func() float64
+	bounds  []float64 // histogram only: upper bucket bounds
+	buckets []uint64  // histogram only, not cumulative
+	sum     float64
+	count   uint64
+}
+
+func newRegistry() *registry {
+	return &registry{families: make(map[string]*family)}
+}
+
+func (r *registry) add(name string, tags map[string]string, delta float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.series(name+"_total", typeCounter, "Total "+name+" recorded by the scraper", tags).value += delta
+}
+
+func (r *registry) set(name string, tags map[string]string, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.series(name, typeGauge, "Last "+name+" value recorded by the scraper", tags).value = value
+}
+
+func (r *registry) setFunc(name string, tags map[string]string, fn func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.series(name, typeGauge, "Current "+name+" of the scraper", tags).fn = fn
+}
+
+// observe adds a value to a histogram. The buckets of the first observation
+// of a series are kept for its lifetime.
+func (r *registry) observe(name string, tags map[string]string, value float64, buckets []float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.series(name+"_duration_seconds", typeHistogram, "Duration of "+name+" in seconds", tags)
+	if s.bounds == nil {
+		s.bounds = buckets
+		s.buckets = make([]uint64, len(buckets))
+	}
+	if i, _ := slices.BinarySearch(s.bounds, value); i < len(s.bounds) {
+		s.buckets[i]++
+	}
+	s.sum += value
+	s.count++
+}
+
+// series returns the series for name and tags, creating it if needed. The
+// caller must hold r.mu.
+func (r *registry) series(name, typ, help string, tags map[string]string) *series {
+	name = namespace + "_" + sanitizeName(name)
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, typ: typ, help: help, series: make(map[string]*series)}
+		r.families[name] = f
+	}
+	labels := formatLabels(tags)
+	if f.typ != typ {
+		// Name already taken by another type; drop the value rather than
+		// expose an invalid family
+		return &series{labels: labels}
+	}
+	s, ok := f.series[labels]
+	if !ok {
+		s = &series{labels: labels}
+		f.series[labels] = s
+	}
+	return s
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format,
+// sorted by name and labels so the output is stable
+func (r *registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var sb strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(r.families)) {
+		f := r.families[name]
+		fmt.Fprintf(&sb, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(&sb, "# TYPE %s %s\n", f.name, f.typ)
+		for _, labels := range slices.Sorted(maps.Keys(f.series)) {
+			s := f.series[labels]
+			if f.typ == typeHistogram {
+				writeHistogram(&sb, f.name, s)
+				continue
+			}
+			value := s.value
+			if s.fn != nil {
+				value = s.fn()
+			}
+			fmt.Fprintf(&sb, "%s%s %s\n", f.name, s.labels, formatFloat(value))
+		}
+	}
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+func writeHistogram(sb *strings.Builder, name string, s *series) {
+	var cumulative uint64
+	for i, upper := range s.bounds {
+		cumulative += s.buckets[i]
+		fmt.Fprintf(sb, "%s_bucket%s %d\n", name, withLabel(s.labels, "le", formatFloat(upper)), cumulative)
+	}
+	fmt.Fprintf(sb, "%s_bucket%s %d\n", name, withLabel(s.labels, "le", "+Inf"), s.count)
+	fmt.Fprintf(sb, "%s_sum%s %s\n", name, s.labels, formatFloat(s.sum))
+	fmt.Fprintf(sb, "%s_count%s %d\n", name, s.labels, s.count)
+}
+
+// ServeHTTP serves the registry in the Prometheus text format
+func (r *registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// formatLabels renders tags as a sorted Prometheus label set
+func formatLabels(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, k := range slices.Sorted(maps.Keys(tags)) {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(sanitizeName(k))
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabelValue(tags[k]))
+		sb.WriteString(`"`)
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// withLabel appends one label to an already rendered label set
+func withLabel(labels, name, value string) string {
+	label := name + `="` + value + `"`
+	if labels == "" {
+		return "{" + label + "}"
+	}
+	return labels[:len(labels)-1] + "," + label + "}"
+}
+
+// sanitizeName replaces characters Prometheus does not allow in metric and
+// label names with underscores
+func sanitizeName(s string) string {
+	var sb strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9' && i > 0:
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
+
+func escapeLabelValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return s
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
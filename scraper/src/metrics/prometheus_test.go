@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusExposition(t *testing.T) {
+	// Pushing disabled: live metrics must still be recorded
+	c := &Collector{live: newRegistry()}
+
+	c.RecordCount("prices", 40, nil)
+	c.RecordCount("prices", 2, nil)
+	c.RecordCount("errors", 1, map[string]string{"phase": "run"})
+	c.RecordGauge("queue_depth", 7, nil)
+	c.RecordDuration("products", 2*time.Second, nil)
+	c.RecordDuration("products", 45*time.Second, nil)
+	c.GaugeFunc("progress_done", nil, func() float64 { return 12 })
+
+	rec := httptest.NewRecorder()
+	c.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+
+	want := `# HELP scraper_errors_total Total errors recorded by the scraper
+# TYPE scraper_errors_total counter
+scraper_errors_total{phase="run"} 1
+# HELP scraper_prices_total Total prices recorded by the scraper
+# TYPE scraper_prices_total counter
+scraper_prices_total 42
+# HELP scraper_products_duration_seconds Duration of products in seconds
+# TYPE scraper_products_duration_seconds histogram
+scraper_products_duration_seconds_bucket{le="0.1"} 0
+scraper_products_duration_seconds_bucket{le="0.5"} 0
+scraper_products_duration_seconds_bucket{le="1"} 0
+scraper_products_duration_seconds_bucket{le="5"} 1
+scraper_products_duration_seconds_bucket{le="15"} 1
+scraper_products_duration_seconds_bucket{le="30"} 1
+scraper_products_duration_seconds_bucket{le="60"} 2
+scraper_products_duration_seconds_bucket{le="300"} 2
+scraper_products_duration_seconds_bucket{le="900"} 2
+scraper_products_duration_seconds_bucket{le="1800"} 2
+scraper_products_duration_seconds_bucket{le="3600"} 2
+scraper_products_duration_seconds_bucket{le="7200"} 2
+scraper_products_duration_seconds_bucket{le="14400"} 2
+scraper_products_duration_seconds_bucket{le="+Inf"} 2
+scraper_products_duration_seconds_sum 47
+scraper_products_duration_seconds_count 2
+# HELP scraper_progress_done Current progress_done of the scraper
+# TYPE scraper_progress_done gauge
+scraper_progress_done 12
+# HELP scraper_queue_depth Last queue_depth value recorded by the scraper
+# TYPE scraper_queue_depth gauge
+scraper_queue_depth 7
+`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("exposition mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	if len(c.metrics) != 0 {
+		t.Errorf("line protocol batch should stay empty without a URL, got %d", len(c.metrics))
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		tags map[string]string
+		want string
+	}{
+		{"no tags", nil, ""},
+		{"sorted", map[string]string{"b": "2", "a": "1"}, `{a="1",b="2"}`},
+		{"escaped value", map[string]string{"name": "say \"hi\"\\\n"}, `{name="say \"hi\"\\\n"}`},
+		{"sanitized name", map[string]string{"region-id": "3"}, `{region_id="3"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatLabels(tt.tags); got != tt.want {
+				t.Errorf("formatLabels(%v) = %q, want %q", tt.tags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"prices", "prices"},
+		{"failed_items", "failed_items"},
+		{"fetch-products", "fetch_products"},
+		{"9lives", "_lives"},
+		{"a.b c", "a_b_c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := sanitizeName(tt.input); got != tt.want {
+				t.Errorf("sanitizeName(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
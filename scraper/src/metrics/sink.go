@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Kind tells sinks how to interpret the fields of a point
+type Kind int
+
+const (
+	KindUntyped   Kind = iota // fields passed to Record as they are
+	KindCounter               // "count": the total counted since the last flush
+	KindGauge                 // "value": the last value recorded
+	KindDuration              // duration summary in milliseconds, see aggregate.fields
+	KindHistogram             // latency histogram and percentiles, see histogramFields
+	KindEvent                 // "properties": JSON object, see RecordEvent
+)
+
+// Point is one buffered metric waiting to be sent to a sink
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]interface{}
+	Time        time.Time
+	Kind        Kind
+}
+
+// String renders the point in InfluxDB line protocol, or describes why it
+// cannot be
+func (p Point) String() string {
+	line, err := EncodeLine(p)
+	if err != nil {
+		return err.Error()
+	}
+	return line
+}
+
+// Sink sends batches of points to a metrics backend
+type Sink interface {
+	// Write sends one batch. Errors are retried unless marked permanent.
+	Write(points []Point) error
+	Close() error
+}
+
+// NewSink picks a sink from the scheme of rawURL:
+//
+//	http://, https://       InfluxDB line protocol over HTTP (Telegraf)
+//	statsd://host:port      StatsD over UDP, with DogStatsD tags
+//	otlp://, otlps://       OpenTelemetry OTLP/HTTP with JSON encoding
+//	stdout://               line protocol on standard output
+//	file:///path            line protocol appended to a file
+func NewSink(rawURL string, client *http.Client) (Sink, error) {
+	u, err := parseSinkURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return &influxSink{url: rawURL, client: client}, nil
+	case "statsd", "udp":
+		return newStatsdSink(u.Host)
+	case "otlp", "otlps":
+		return newOTLPSink(u, client), nil
+	case "stdout":
+		return &writerSink{w: os.Stdout}, nil
+	case "file":
+		return newFileSink(u.Path)
+	default:
+		return nil, fmt.Errorf("unsupported metrics URL scheme %q", u.Scheme)
+	}
+}
+
+var (
+	errStatsdAddr = errors.New("statsd metrics URL needs a host and port, e.g. statsd://localhost:8125")
+	errFilePath   = errors.New("file metrics URL needs a path, e.g. file:///tmp/metrics.lp")
+)
+
+// CheckURL reports whether rawURL names a sink NewSink can create, without
+// connecting to it or opening files
+func CheckURL(rawURL string) error {
+	_, err := parseSinkURL(rawURL)
+	return err
+}
+
+func parseSinkURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metrics URL: %w", err)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "otlp", "otlps", "stdout":
+	case "statsd", "udp":
+		if u.Host == "" {
+			return nil, errStatsdAddr
+		}
+	case "file":
+		if u.Path == "" {
+			return nil, errFilePath
+		}
+	default:
+		return nil, fmt.Errorf("unsupported metrics URL scheme %q", u.Scheme)
+	}
+	return u, nil
+}
+
+// permanentError marks a failure that retrying the same batch won't fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// metricName joins the measurement, the "metric" tag and a field into a
+// dotted name for sinks without fields, and returns the remaining tags
+func metricName(p Point, field string) (string, map[string]string) {
+	parts := []string{p.Measurement}
+	tags := p.Tags
+	if metric, ok := p.Tags["metric"]; ok {
+		parts = append(parts, metric)
+		tags = make(map[string]string, len(p.Tags)-1)
+		for k, v := range p.Tags {
+			if k != "metric" {
+				tags[k] = v
+			}
+		}
+	}
+	if field != "" {
+		parts = append(parts, field)
+	}
+	return strings.Join(parts, "."), tags
+}
+
+// toFloat converts a numeric field value, reporting false for other types
+func toFloat(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case float64:
+		return val, true
+	case bool:
+		if val {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
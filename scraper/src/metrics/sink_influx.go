@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+)
+
+// influxSink posts InfluxDB line protocol over HTTP, e.g. to Telegraf's
+// http_listener_v2 input
+type influxSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *influxSink) Write(points []Point) error {
+	body := encodeLines(points)
+	if len(body) == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return permanent(fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header.Set("Content-Type", "text/plain")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+func (s *influxSink) Close() error {
+	return nil
+}
+
+// checkStatus turns an error response into an error, permanent unless the
+// status suggests trying again later
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+	err := fmt.Errorf("metrics endpoint returned status %d", resp.StatusCode)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return err
+	}
+	return permanent(err)
+}
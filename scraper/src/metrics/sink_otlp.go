@@ -0,0 +1,299 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// otlpSink posts points to an OpenTelemetry collector over OTLP/HTTP using
+// the JSON encoding. Counters become delta sums, durations become summaries
+// with their min and max as the 0 and 1 quantiles, histograms become delta
+// histograms and everything else becomes gauges. Events have no metric
+// representation and are left out.
+type otlpSink struct {
+	url         string
+	client      *http.Client
+	serviceName string
+}
+
+func newOTLPSink(u *url.URL, client *http.Client) *otlpSink {
+	endpoint := *u
+	endpoint.Scheme = "http"
+	if u.Scheme == "otlps" {
+		endpoint.Scheme = "https"
+	}
+	if endpoint.Path == "" || endpoint.Path == "/" {
+		endpoint.Path = "/v1/metrics"
+	}
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "scraper"
+	}
+	return &otlpSink{url: endpoint.String(), client: client, serviceName: serviceName}
+}
+
+func (s *otlpSink) Write(points []Point) error {
+	request := s.request(points)
+	if len(request.ResourceMetrics[0].ScopeMetrics[0].Metrics) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return permanent(fmt.Errorf("failed to encode metrics: %w", err))
+	}
+
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return permanent(fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+func (s *otlpSink) Close() error {
+	return nil
+}
+
+// The types below follow the protobuf JSON mapping of the OTLP metrics
+// ExportMetricsServiceRequest. 64-bit integers are encoded as strings.
+
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name      string         `json:"name"`
+	Unit      string         `json:"unit,omitempty"`
+	Sum       *otlpSum       `json:"sum,omitempty"`
+	Gauge     *otlpGauge     `json:"gauge,omitempty"`
+	Summary   *otlpSummary   `json:"summary,omitempty"`
+	Histogram *otlpHistogram `json:"histogram,omitempty"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpSummary struct {
+	DataPoints []otlpSummaryDataPoint `json:"dataPoints"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	TimeUnixNano string          `json:"timeUnixNano"`
+	AsInt        string          `json:"asInt,omitempty"`
+	AsDouble     *float64        `json:"asDouble,omitempty"`
+}
+
+type otlpSummaryDataPoint struct {
+	Attributes     []otlpAttribute     `json:"attributes,omitempty"`
+	TimeUnixNano   string              `json:"timeUnixNano"`
+	Count          string              `json:"count"`
+	Sum            float64             `json:"sum"`
+	QuantileValues []otlpQuantileValue `json:"quantileValues"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes     []otlpAttribute `json:"attributes,omitempty"`
+	TimeUnixNano   string          `json:"timeUnixNano"`
+	Count          string          `json:"count"`
+	Sum            float64         `json:"sum"`
+	Min            float64         `json:"min"`
+	Max            float64         `json:"max"`
+	BucketCounts   []string        `json:"bucketCounts"`
+	ExplicitBounds []float64       `json:"explicitBounds"`
+}
+
+type otlpQuantileValue struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+type otlpAttribute struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// aggregationTemporalityDelta: counters hold the count since the last flush
+const aggregationTemporalityDelta = 1
+
+func (s *otlpSink) request(points []Point) otlpRequest {
+	var metrics []otlpMetric
+	for _, p := range points {
+		metrics = append(metrics, otlpMetrics(p)...)
+	}
+
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			{Key: "service.name", Value: otlpAnyValue{StringValue: s.serviceName}},
+		}},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "github.com/pheever/cy-price-watchdog/scraper/src/metrics"},
+			Metrics: metrics,
+		}},
+	}}}
+}
+
+func otlpMetrics(p Point) []otlpMetric {
+	ts := strconv.FormatInt(p.Time.UnixNano(), 10)
+
+	switch p.Kind {
+	case KindCounter:
+		name, tags := metricName(p, "")
+		count, _ := toFloat(p.Fields["count"])
+		return []otlpMetric{{
+			Name: name,
+			Sum: &otlpSum{
+				DataPoints: []otlpNumberDataPoint{{
+					Attributes:   otlpAttributes(tags),
+					TimeUnixNano: ts,
+					AsInt:        strconv.FormatInt(int64(count), 10),
+				}},
+				AggregationTemporality: aggregationTemporalityDelta,
+				IsMonotonic:            true,
+			},
+		}}
+	case KindDuration:
+		name, tags := metricName(p, "duration")
+		samples, _ := toFloat(p.Fields["samples"])
+		sum, _ := toFloat(p.Fields["sum_ms"])
+		minMs, _ := toFloat(p.Fields["min_ms"])
+		maxMs, _ := toFloat(p.Fields["max_ms"])
+		return []otlpMetric{{
+			Name: name,
+			Unit: "ms",
+			Summary: &otlpSummary{DataPoints: []otlpSummaryDataPoint{{
+				Attributes:   otlpAttributes(tags),
+				TimeUnixNano: ts,
+				Count:        strconv.FormatInt(int64(samples), 10),
+				Sum:          sum,
+				QuantileValues: []otlpQuantileValue{
+					{Quantile: 0, Value: minMs},
+					{Quantile: 1, Value: maxMs},
+				},
+			}}},
+		}}
+	case KindHistogram:
+		return []otlpMetric{otlpHistogramMetric(p, ts)}
+	case KindEvent:
+		return nil
+	}
+
+	// Gauges and untyped points: one gauge per numeric field
+	var metrics []otlpMetric
+	for _, field := range slices.Sorted(maps.Keys(p.Fields)) {
+		value, ok := toFloat(p.Fields[field])
+		if !ok {
+			continue
+		}
+		name, tags := metricName(p, field)
+		if p.Kind == KindGauge {
+			name, tags = metricName(p, "")
+		}
+		metrics = append(metrics, otlpMetric{
+			Name: name,
+			Gauge: &otlpGauge{DataPoints: []otlpNumberDataPoint{{
+				Attributes:   otlpAttributes(tags),
+				TimeUnixNano: ts,
+				AsDouble:     &value,
+			}}},
+		})
+	}
+	return metrics
+}
+
+// otlpHistogramMetric turns the cumulative "le_" fields of a histogram point
+// back into the per-bucket counts OTLP expects, with bounds in milliseconds
+func otlpHistogramMetric(p Point, ts string) otlpMetric {
+	name, tags := metricName(p, "")
+	count, _ := toFloat(p.Fields["count"])
+	sum, _ := toFloat(p.Fields["sum_ms"])
+	minMs, _ := toFloat(p.Fields["min_ms"])
+	maxMs, _ := toFloat(p.Fields["max_ms"])
+
+	bounds := make([]float64, len(LatencyBuckets))
+	counts := make([]string, 0, len(LatencyBuckets)+1)
+	var previous float64
+	for i, bound := range LatencyBuckets {
+		bounds[i] = bound * 1000
+		cumulative, _ := toFloat(p.Fields[bucketField(bound)])
+		counts = append(counts, strconv.FormatInt(int64(cumulative-previous), 10))
+		previous = cumulative
+	}
+	counts = append(counts, strconv.FormatInt(int64(count-previous), 10))
+
+	return otlpMetric{
+		Name: name,
+		Unit: "ms",
+		Histogram: &otlpHistogram{
+			DataPoints: []otlpHistogramDataPoint{{
+				Attributes:     otlpAttributes(tags),
+				TimeUnixNano:   ts,
+				Count:          strconv.FormatInt(int64(count), 10),
+				Sum:            sum,
+				Min:            minMs,
+				Max:            maxMs,
+				BucketCounts:   counts,
+				ExplicitBounds: bounds,
+			}},
+			AggregationTemporality: aggregationTemporalityDelta,
+		},
+	}
+}
+
+func otlpAttributes(tags map[string]string) []otlpAttribute {
+	attrs := make([]otlpAttribute, 0, len(tags))
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		attrs = append(attrs, otlpAttribute{Key: k, Value: otlpAnyValue{StringValue: tags[k]}})
+	}
+	return attrs
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxDatagramSize keeps StatsD packets under a typical MTU so they are not
+// fragmented
+const maxDatagramSize = 1432
+
+// statsdSink sends points over UDP in the StatsD format, with tags in the
+// DogStatsD "|#key:value" extension understood by Telegraf, Datadog and the
+// Prometheus statsd_exporter. Counters are sent as counts ("|c"); everything
+// else, including the duration summary fields, as gauges ("|g"). Events are
+// sent as DogStatsD events with their properties as the text.
+type statsdSink struct {
+	conn net.Conn
+}
+
+func newStatsdSink(addr string) (*statsdSink, error) {
+	if addr == "" {
+		return nil, errStatsdAddr
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open statsd connection: %w", err)
+	}
+	return &statsdSink{conn: conn}, nil
+}
+
+func (s *statsdSink) Write(points []Point) error {
+	var packet strings.Builder
+	for _, p := range points {
+		for _, line := range statsdLines(p) {
+			if packet.Len() > 0 && packet.Len()+1+len(line) > maxDatagramSize {
+				if err := s.send(packet.String()); err != nil {
+					return err
+				}
+				packet.Reset()
+			}
+			if packet.Len() > 0 {
+				packet.WriteByte('\n')
+			}
+			packet.WriteString(line)
+		}
+	}
+	if packet.Len() == 0 {
+		return nil
+	}
+	return s.send(packet.String())
+}
+
+func (s *statsdSink) send(packet string) error {
+	if _, err := s.conn.Write([]byte(packet)); err != nil {
+		return fmt.Errorf("failed to send statsd packet: %w", err)
+	}
+	return nil
+}
+
+func (s *statsdSink) Close() error {
+	return s.conn.Close()
+}
+
+// statsdLines renders one StatsD line per numeric field of a point, sorted
+// by field name
+func statsdLines(p Point) []string {
+	if p.Kind == KindEvent {
+		return []string{statsdEvent(p)}
+	}
+
+	var lines []string
+	for _, field := range slices.Sorted(maps.Keys(p.Fields)) {
+		value, ok := toFloat(p.Fields[field])
+		if !ok {
+			continue
+		}
+
+		metricType := "g"
+		name, tags := metricName(p, field)
+		switch p.Kind {
+		case KindCounter:
+			metricType = "c"
+			name, tags = metricName(p, "")
+		case KindGauge:
+			name, tags = metricName(p, "")
+		}
+
+		line := sanitizeStatsd(name) + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + metricType
+		if len(tags) > 0 {
+			pairs := make([]string, 0, len(tags))
+			for _, k := range slices.Sorted(maps.Keys(tags)) {
+				pairs = append(pairs, sanitizeStatsd(k)+":"+sanitizeStatsd(tags[k]))
+			}
+			line += "|#" + strings.Join(pairs, ",")
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// statsdEvent renders an event as _e{<title length>,<text length>}:title|text.
+// The JSON text never holds a raw newline, and the lengths let it hold pipes.
+func statsdEvent(p Point) string {
+	title := p.Tags["event"]
+	text, _ := p.Fields["properties"].(string)
+	return fmt.Sprintf("_e{%d,%d}:%s|%s", len(title), len(text), title, text)
+}
+
+// sanitizeStatsd replaces the characters that delimit StatsD lines and tags
+func sanitizeStatsd(s string) string {
+	return strings.NewReplacer(":", "_", "|", "_", "@", "_", ",", "_", "#", "_", "\n", "_", " ", "_").Replace(s)
+}
@@ -0,0 +1,292 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{"http is influx", "http://localhost:8086/write", "*metrics.influxSink", false},
+		{"https is influx", "https://metrics.example.com", "*metrics.influxSink", false},
+		{"statsd", "statsd://127.0.0.1:8125", "*metrics.statsdSink", false},
+		{"statsd needs a host", "statsd://", "", true},
+		{"otlp", "otlp://localhost:4318", "*metrics.otlpSink", false},
+		{"otlps", "otlps://collector.example.com", "*metrics.otlpSink", false},
+		{"stdout", "stdout://", "*metrics.writerSink", false},
+		{"file", "file://" + filepath.Join(t.TempDir(), "metrics.lp"), "*metrics.writerSink", false},
+		{"unknown scheme", "kafka://localhost:9092", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckURL(tt.url); (err != nil) != tt.wantErr {
+				t.Errorf("CheckURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+
+			sink, err := NewSink(tt.url, http.DefaultClient)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSink(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer sink.Close()
+			if got := fmt.Sprintf("%T", sink); got != tt.want {
+				t.Errorf("NewSink(%q) = %s, want %s", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInfluxSinkStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantErr       bool
+		wantPermanent bool
+	}{
+		{"accepted", http.StatusNoContent, false, false},
+		{"rate limited", http.StatusTooManyRequests, true, false},
+		{"server error", http.StatusBadGateway, true, false},
+		{"bad request", http.StatusBadRequest, true, true},
+		{"unauthorized", http.StatusUnauthorized, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			sink := &influxSink{url: server.URL, client: server.Client()}
+			err := sink.Write(testPoints("cpu"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if isPermanent(err) != tt.wantPermanent {
+				t.Errorf("isPermanent(%v) = %v, want %v", err, isPermanent(err), tt.wantPermanent)
+			}
+		})
+	}
+}
+
+func TestStatsdLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		point Point
+		want  []string
+	}{
+		{
+			"counter",
+			Point{Measurement: "scraper", Kind: KindCounter,
+				Tags:   map[string]string{"metric": "requests", "status": "ok"},
+				Fields: map[string]interface{}{"count": int64(3)}},
+			[]string{"scraper.requests:3|c|#status:ok"},
+		},
+		{
+			"gauge",
+			Point{Measurement: "scraper", Kind: KindGauge,
+				Tags:   map[string]string{"metric": "queue_depth"},
+				Fields: map[string]interface{}{"value": 2.5}},
+			[]string{"scraper.queue_depth:2.5|g"},
+		},
+		{
+			"duration summary",
+			Point{Measurement: "scraper", Kind: KindDuration,
+				Tags:   map[string]string{"metric": "fetch"},
+				Fields: map[string]interface{}{"duration_ms": int64(20), "max_ms": int64(30), "samples": int64(2)}},
+			[]string{"scraper.fetch.duration_ms:20|g", "scraper.fetch.max_ms:30|g", "scraper.fetch.samples:2|g"},
+		},
+		{
+			"untyped skips non-numeric fields",
+			Point{Measurement: "run", Tags: map[string]string{"phase": "a:b"},
+				Fields: map[string]interface{}{"items": 4, "status": "ok"}},
+			[]string{"run.items:4|g|#phase:a_b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := statsdLines(tt.point)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("statsdLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsdSinkWrite(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	sink, err := newStatsdSink(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("newStatsdSink() error = %v", err)
+	}
+	defer sink.Close()
+
+	if err := sink.Write(testPoints("a", "b")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	buf := make([]byte, maxDatagramSize)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom() error = %v", err)
+	}
+	if got, want := string(buf[:n]), "a.v:1|g\nb.v:2|g"; got != want {
+		t.Errorf("packet = %q, want %q", got, want)
+	}
+}
+
+func TestOTLPSink(t *testing.T) {
+	var got otlpRequest
+	var path, contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("invalid OTLP JSON: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("OTEL_SERVICE_NAME", "scraper-test")
+	sink, err := NewSink(strings.Replace(server.URL, "http://", "otlp://", 1), server.Client())
+	if err != nil {
+		t.Fatalf("NewSink() error = %v", err)
+	}
+
+	ts := time.Unix(0, 1000)
+	err = sink.Write([]Point{
+		{Measurement: "scraper", Kind: KindCounter, Time: ts,
+			Tags: map[string]string{"metric": "requests"}, Fields: map[string]interface{}{"count": int64(3)}},
+		{Measurement: "scraper", Kind: KindDuration, Time: ts,
+			Tags:   map[string]string{"metric": "fetch"},
+			Fields: map[string]interface{}{"samples": int64(2), "sum_ms": int64(50), "min_ms": int64(20), "max_ms": int64(30)}},
+		{Measurement: "scraper", Kind: KindGauge, Time: ts,
+			Tags: map[string]string{"metric": "queue_depth", "phase": "prices"}, Fields: map[string]interface{}{"value": 2.5}},
+	})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if path != "/v1/metrics" {
+		t.Errorf("path = %q, want /v1/metrics", path)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+
+	rm := got.ResourceMetrics[0]
+	if service := rm.Resource.Attributes[0].Value.StringValue; service != "scraper-test" {
+		t.Errorf("service.name = %q, want scraper-test", service)
+	}
+
+	metrics := rm.ScopeMetrics[0].Metrics
+	if len(metrics) != 3 {
+		t.Fatalf("expected 3 metrics, got %d", len(metrics))
+	}
+
+	counter := metrics[0]
+	if counter.Name != "scraper.requests" || counter.Sum == nil || counter.Sum.DataPoints[0].AsInt != "3" {
+		t.Errorf("counter = %+v, want sum scraper.requests of 3", counter)
+	}
+	if counter.Sum != nil && (!counter.Sum.IsMonotonic || counter.Sum.AggregationTemporality != aggregationTemporalityDelta) {
+		t.Errorf("counter should be a monotonic delta sum, got %+v", counter.Sum)
+	}
+
+	summary := metrics[1]
+	if summary.Name != "scraper.fetch.duration" || summary.Unit != "ms" || summary.Summary == nil {
+		t.Fatalf("duration = %+v, want summary scraper.fetch.duration in ms", summary)
+	}
+	dp := summary.Summary.DataPoints[0]
+	if dp.Count != "2" || dp.Sum != 50 || dp.QuantileValues[0].Value != 20 || dp.QuantileValues[1].Value != 30 {
+		t.Errorf("summary data point = %+v", dp)
+	}
+	if dp.TimeUnixNano != "1000" {
+		t.Errorf("timeUnixNano = %q, want 1000", dp.TimeUnixNano)
+	}
+
+	gauge := metrics[2]
+	if gauge.Name != "scraper.queue_depth" || gauge.Gauge == nil || *gauge.Gauge.DataPoints[0].AsDouble != 2.5 {
+		t.Errorf("gauge = %+v, want scraper.queue_depth of 2.5", gauge)
+	}
+	if attrs := gauge.Gauge.DataPoints[0].Attributes; len(attrs) != 1 || attrs[0].Key != "phase" {
+		t.Errorf("gauge attributes = %+v, want only phase", attrs)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.lp")
+	for range 2 {
+		sink, err := NewSink("file://"+path, nil)
+		if err != nil {
+			t.Fatalf("NewSink() error = %v", err)
+		}
+		if err := sink.Write(testPoints("a", "b")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read metrics file: %v", err)
+	}
+	if want := strings.Repeat("a v=1i 1\nb v=2i 1\n", 2); string(data) != want {
+		t.Errorf("file = %q, want %q", data, want)
+	}
+}
+
+func TestOTLPHistogram(t *testing.T) {
+	c := newTestCollector("http://localhost:8086", http.DefaultClient)
+	c.RecordHistogram("request", 3*time.Millisecond, nil)
+	c.RecordHistogram("request", 40*time.Millisecond, nil)
+	c.RecordHistogram("request", 200*time.Second, nil)
+
+	metrics := otlpMetrics(c.take()[0])
+	if len(metrics) != 1 || metrics[0].Histogram == nil {
+		t.Fatalf("expected one histogram, got %+v", metrics)
+	}
+	dp := metrics[0].Histogram.DataPoints[0]
+
+	if len(dp.BucketCounts) != len(dp.ExplicitBounds)+1 {
+		t.Fatalf("%d bucket counts for %d bounds", len(dp.BucketCounts), len(dp.ExplicitBounds))
+	}
+	if dp.ExplicitBounds[0] != 5 {
+		t.Errorf("first bound = %v ms, want 5", dp.ExplicitBounds[0])
+	}
+	want := map[int]string{0: "1", 3: "1", len(dp.BucketCounts) - 1: "1"}
+	for i, got := range dp.BucketCounts {
+		if w, ok := want[i]; ok && got != w || !ok && got != "0" {
+			t.Errorf("bucketCounts = %v, want one each in buckets 0, 3 and +Inf", dp.BucketCounts)
+			break
+		}
+	}
+	if dp.Count != "3" || dp.Min != 3 || dp.Max != 200000 {
+		t.Errorf("data point = %+v", dp)
+	}
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// writerSink writes line protocol to a writer, one point per line. It backs
+// the stdout and file sinks used for local debugging.
+type writerSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+func newFileSink(path string) (*writerSink, error) {
+	if path == "" {
+		return nil, errFilePath
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metrics file: %w", err)
+	}
+	return &writerSink{w: f, closer: f}, nil
+}
+
+func (s *writerSink) Write(points []Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.w.Write(encodeLines(points)); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
+
+func (s *writerSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
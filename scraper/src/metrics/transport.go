@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// InstrumentTransport wraps next so every request is recorded with
+// RecordHistogram under name, tagged with the endpoint returned by endpoint
+// and the response status ("error" when no response arrived). The latency
+// runs until the response body is read to the end or closed. A nil next
+// means http.DefaultTransport and a nil endpoint tags the URL path.
+func (c *Collector) InstrumentTransport(next http.RoundTripper, name string, endpoint func(*http.Request) string) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if endpoint == nil {
+		endpoint = func(req *http.Request) string { return req.URL.Path }
+	}
+	return &instrumentedTransport{next: next, c: c, name: name, endpoint: endpoint}
+}
+
+type instrumentedTransport struct {
+	next     http.RoundTripper
+	c        *Collector
+	name     string
+	endpoint func(*http.Request) string
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	tags := map[string]string{"endpoint": t.endpoint(req)}
+	if err != nil {
+		tags["status"] = "error"
+		t.c.RecordHistogram(t.name, time.Since(start), tags)
+		return resp, err
+	}
+
+	tags["status"] = strconv.Itoa(resp.StatusCode)
+	resp.Body = &timedBody{ReadCloser: resp.Body, done: func() {
+		t.c.RecordHistogram(t.name, time.Since(start), tags)
+	}}
+	return resp, nil
+}
+
+// timedBody calls done once, when the body is read to the end or closed
+type timedBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
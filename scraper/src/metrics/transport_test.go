@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestInstrumentTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		transport  http.RoundTripper
+		path       string
+		wantStatus string
+	}{
+		{"success", nil, "/found", "200"},
+		{"error status", nil, "/missing", "404"},
+		{"transport error", failingTransport{}, "/found", "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCollector("http://localhost:8086", http.DefaultClient)
+			client := &http.Client{Transport: c.InstrumentTransport(tt.transport, "upstream_request", nil)}
+
+			resp, err := client.Get(server.URL + tt.path)
+			if err == nil {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+
+			points := c.take()
+			if len(points) != 1 {
+				t.Fatalf("expected 1 point, got %d", len(points))
+			}
+			tags := points[0].Tags
+			if tags["metric"] != "upstream_request" || tags["endpoint"] != tt.path || tags["status"] != tt.wantStatus {
+				t.Errorf("tags = %v, want endpoint %s and status %s", tags, tt.path, tt.wantStatus)
+			}
+			if points[0].Fields["count"] != int64(1) {
+				t.Errorf("count = %v, want 1 even when the body is both drained and closed", points[0].Fields["count"])
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/metrics"
+)
+
+// throughputWindow is how far back the ETA looks when measuring throughput,
+// so it follows the current pace rather than the average since the start
+const throughputWindow = 2 * time.Minute
+
+// Progress tracks what the current run is doing. Phases update it as they
+// work through their queues; the health server reads it to detect stalled
+// runs and to report progress and an ETA.
+type Progress struct {
+	mu             sync.Mutex
+	now            func() time.Time
+	running        bool
+	phase          string
+	total          int
+	done           int
+	retrying       int
+	failed         int
+	startedAt      time.Time
+	phaseStartedAt time.Time
+	lastProgress   time.Time
+	samples        []progressSample
+}
+
+// progressSample records how many items were finished at a point in time
+type progressSample struct {
+	at       time.Time
+	finished int
+}
+
+// ProgressSnapshot is a point-in-time copy of Progress
+type ProgressSnapshot struct {
+	Running             bool       `json:"running"`
+	Phase               string     `json:"phase,omitempty"`
+	Total               int        `json:"total"`
+	Done                int        `json:"done"`
+	Retrying            int        `json:"retrying"`
+	Failed              int        `json:"failed"`
+	StartedAt           time.Time  `json:"startedAt"`
+	PhaseStartedAt      time.Time  `json:"phaseStartedAt"`
+	LastProgress        time.Time  `json:"lastProgress"`
+	ItemsPerSecond      float64    `json:"itemsPerSecond"`
+	ETA                 string     `json:"eta,omitempty"`
+	EstimatedCompletion *time.Time `json:"estimatedCompletion,omitempty"`
+}
+
+func NewProgress() *Progress {
+	return &Progress{now: time.Now}
+}
+
+// StartRun resets the tracker at the start of a run
+func (p *Progress) StartRun() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	p.running = true
+	p.startedAt = now
+	p.resetPhase("", 0, now)
+}
+
+// FinishRun marks the run as ended, keeping its final counts
+func (p *Progress) FinishRun() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running = false
+	p.lastProgress = p.now()
+}
+
+// StartPhase records that a new phase has begun with total items to process
+func (p *Progress) StartPhase(phase string, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resetPhase(phase, total, p.now())
+}
+
+// SetTotal updates the item count of the current phase once it is known
+func (p *Progress) SetTotal(total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total = total
+}
+
+// Done records an item completed after the given number of retries
+func (p *Progress) Done(retries int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.finish(retries)
+}
+
+// Fail records an item given up on after the given number of retries
+func (p *Progress) Fail(retries int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failed++
+	p.finish(retries)
+}
+
+// Retry records an item put back on the queue; retries is its retry count
+// including this one, so an item only counts as retrying once
+func (p *Progress) Retry(retries int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if retries == 1 {
+		p.retrying++
+	}
+	p.lastProgress = p.now()
+}
+
+// Snapshot returns a copy of the current state with the ETA for the phase
+func (p *Progress) Snapshot() ProgressSnapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	snap := ProgressSnapshot{
+		Running:        p.running,
+		Phase:          p.phase,
+		Total:          p.total,
+		Done:           p.done,
+		Retrying:       p.retrying,
+		Failed:         p.failed,
+		StartedAt:      p.startedAt,
+		PhaseStartedAt: p.phaseStartedAt,
+		LastProgress:   p.lastProgress,
+	}
+
+	now := p.now()
+	p.trimSamples(now)
+	if len(p.samples) < 2 {
+		return snap
+	}
+
+	first, last := p.samples[0], p.samples[len(p.samples)-1]
+	elapsed := now.Sub(first.at).Seconds()
+	if elapsed <= 0 || last.finished == first.finished {
+		return snap
+	}
+	snap.ItemsPerSecond = float64(last.finished-first.finished) / elapsed
+
+	remaining := p.total - p.done - p.failed
+	if p.running && remaining > 0 {
+		eta := time.Duration(float64(remaining) / snap.ItemsPerSecond * float64(time.Second))
+		completion := now.Add(eta).UTC()
+		snap.ETA = eta.Round(time.Second).String()
+		snap.EstimatedCompletion = &completion
+	}
+	return snap
+}
+
+func (p *Progress) resetPhase(phase string, total int, now time.Time) {
+	p.phase = phase
+	p.total = total
+	p.done = 0
+	p.retrying = 0
+	p.failed = 0
+	p.phaseStartedAt = now
+	p.lastProgress = now
+	p.samples = []progressSample{{at: now}}
+}
+
+func (p *Progress) finish(retries int) {
+	if retries > 0 && p.retrying > 0 {
+		p.retrying--
+	}
+	now := p.now()
+	p.lastProgress = now
+	p.samples = append(p.samples, progressSample{at: now, finished: p.done + p.failed})
+	p.trimSamples(now)
+}
+
+// trimSamples drops samples older than the throughput window, always keeping
+// two so that slow phases still get a rate
+func (p *Progress) trimSamples(now time.Time) {
+	cutoff := now.Add(-throughputWindow)
+	i := 0
+	for i+2 < len(p.samples) && p.samples[i].at.Before(cutoff) {
+		i++
+	}
+	p.samples = p.samples[i:]
+}
+
+// registerProgressMetrics exposes the progress of the current phase as live
+// gauges on the metrics endpoint
+func registerProgressMetrics(c *metrics.Collector, p *Progress) {
+	gauges := map[string]func(ProgressSnapshot) float64{
+		"progress_total":            func(s ProgressSnapshot) float64 { return float64(s.Total) },
+		"progress_done":             func(s ProgressSnapshot) float64 { return float64(s.Done) },
+		"progress_retrying":         func(s ProgressSnapshot) float64 { return float64(s.Retrying) },
+		"progress_failed":           func(s ProgressSnapshot) float64 { return float64(s.Failed) },
+		"progress_items_per_second": func(s ProgressSnapshot) float64 { return s.ItemsPerSecond },
+		"run_in_progress": func(s ProgressSnapshot) float64 {
+			if s.Running {
+				return 1
+			}
+			return 0
+		},
+	}
+	for name, value := range gauges {
+		c.GaugeFunc(name, nil, func() float64 { return value(p.Snapshot()) })
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock lets tests move the progress tracker's time forward
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestProgress() (*Progress, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)}
+	p := NewProgress()
+	p.now = clock.Now
+	return p, clock
+}
+
+func TestProgressCounts(t *testing.T) {
+	p, _ := newTestProgress()
+	p.StartRun()
+	p.StartPhase(phasePrices, 5)
+
+	p.Done(0)
+	p.Retry(1) // item A queued for retry
+	p.Retry(1) // item B queued for retry
+	p.Retry(2) // item A retried again, still one item
+	p.Done(2)  // item A succeeds
+	p.Fail(1)  // item B gives up
+
+	snap := p.Snapshot()
+	if snap.Total != 5 || snap.Done != 2 || snap.Retrying != 0 || snap.Failed != 1 {
+		t.Errorf("total/done/retrying/failed = %d/%d/%d/%d, want 5/2/0/1",
+			snap.Total, snap.Done, snap.Retrying, snap.Failed)
+	}
+
+	p.Retry(1)
+	if got := p.Snapshot().Retrying; got != 1 {
+		t.Errorf("Retrying = %d, want 1", got)
+	}
+
+	p.StartPhase(phaseAggregates, 2)
+	snap = p.Snapshot()
+	if snap.Phase != phaseAggregates || snap.Done != 0 || snap.Retrying != 0 || snap.Failed != 0 {
+		t.Errorf("phase not reset: %+v", snap)
+	}
+}
+
+func TestProgressETA(t *testing.T) {
+	tests := []struct {
+		name     string
+		steps    []time.Duration // time between completed items
+		total    int
+		finish   bool
+		wantRate float64
+		wantETA  string
+	}{
+		{
+			name:     "steady pace",
+			steps:    []time.Duration{time.Second, time.Second, time.Second, time.Second},
+			total:    10,
+			wantRate: 1,
+			wantETA:  "6s",
+		},
+		{
+			name:     "uses recent throughput only",
+			steps:    []time.Duration{10 * time.Minute, time.Second, time.Second, time.Second},
+			total:    10,
+			wantRate: 1,
+			wantETA:  "6s",
+		},
+		{
+			name:    "no items finished yet",
+			steps:   nil,
+			total:   10,
+			wantETA: "",
+		},
+		{
+			name:     "run finished",
+			steps:    []time.Duration{time.Second, time.Second},
+			total:    10,
+			finish:   true,
+			wantRate: 1,
+			wantETA:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, clock := newTestProgress()
+			p.StartRun()
+			p.StartPhase(phasePrices, tt.total)
+			for _, step := range tt.steps {
+				clock.Advance(step)
+				p.Done(0)
+			}
+			if tt.finish {
+				p.FinishRun()
+			}
+
+			snap := p.Snapshot()
+			if diff := snap.ItemsPerSecond - tt.wantRate; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("ItemsPerSecond = %v, want %v", snap.ItemsPerSecond, tt.wantRate)
+			}
+			if snap.ETA != tt.wantETA {
+				t.Errorf("ETA = %q, want %q", snap.ETA, tt.wantETA)
+			}
+			if (snap.EstimatedCompletion != nil) != (tt.wantETA != "") {
+				t.Errorf("EstimatedCompletion = %v, want set only with an ETA", snap.EstimatedCompletion)
+			}
+		})
+	}
+}
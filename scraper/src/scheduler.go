@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// schedule yields the next time a job should run after t
+type schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// intervalSchedule runs a job at a fixed interval after the previous run ends
+type intervalSchedule time.Duration
+
+func (i intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
+// parseSchedule accepts either a standard five-field cron expression (or a
+// descriptor such as @daily) or a fixed interval, but not both
+func parseSchedule(cronExpr string, interval time.Duration) (schedule, error) {
+	switch {
+	case cronExpr != "" && interval > 0:
+		return nil, errors.New("set either a cron expression or an interval, not both")
+	case cronExpr != "":
+		sched, err := cron.ParseStandard(cronExpr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", cronExpr, err)
+		}
+		return sched, nil
+	case interval > 0:
+		return intervalSchedule(interval), nil
+	default:
+		return nil, errors.New("a cron expression or an interval is required")
+	}
+}
+
+// SchedulerStatus is the scheduler state exposed on the health server
+type SchedulerStatus struct {
+	Running      bool       `json:"running"`
+	Runs         int        `json:"runs"`
+	Failures     int        `json:"failures"`
+	LastRunStart *time.Time `json:"lastRunStart,omitempty"`
+	LastRunEnd   *time.Time `json:"lastRunEnd,omitempty"`
+	LastRunError string     `json:"lastRunError,omitempty"`
+	NextRun      *time.Time `json:"nextRun,omitempty"`
+}
+
+// Scheduler runs a job on a schedule until its context is cancelled. Runs
+// never overlap: the next run is scheduled only once the current one ends,
+// so ticks that fall inside a long run are skipped.
+type Scheduler struct {
+	schedule schedule
+	job      func(ctx context.Context) error
+	logger   *slog.Logger
+
+	mu     sync.Mutex
+	status SchedulerStatus
+}
+
+func NewScheduler(sched schedule, logger *slog.Logger, job func(ctx context.Context) error) *Scheduler {
+	return &Scheduler{
+		schedule: sched,
+		job:      job,
+		logger:   logger,
+	}
+}
+
+// Start blocks, running the job on schedule until ctx is cancelled. With
+// runNow set the first run starts immediately instead of at the first tick.
+func (sc *Scheduler) Start(ctx context.Context, runNow bool) {
+	next := time.Now()
+	if !runNow {
+		next = sc.schedule.Next(next)
+	}
+
+	for {
+		sc.setNextRun(next)
+		sc.logger.Info("next scheduled run", "at", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			sc.setNextRun(time.Time{})
+			return
+		case <-timer.C:
+		}
+
+		sc.runOnce(ctx)
+		next = sc.schedule.Next(time.Now())
+	}
+}
+
+// Status returns a snapshot of the scheduler state
+func (sc *Scheduler) Status() SchedulerStatus {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.status
+}
+
+// ServeHTTP reports the scheduler status as JSON
+func (sc *Scheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sc.Status())
+}
+
+func (sc *Scheduler) runOnce(ctx context.Context) {
+	start := time.Now().UTC()
+	sc.mu.Lock()
+	sc.status.Running = true
+	sc.status.LastRunStart = &start
+	sc.status.NextRun = nil
+	sc.mu.Unlock()
+
+	sc.logger.Info("scheduled run starting")
+	err := sc.job(ctx)
+
+	end := time.Now().UTC()
+	sc.mu.Lock()
+	sc.status.Running = false
+	sc.status.Runs++
+	sc.status.LastRunEnd = &end
+	sc.status.LastRunError = ""
+	if err != nil {
+		sc.status.Failures++
+		sc.status.LastRunError = err.Error()
+	}
+	sc.mu.Unlock()
+
+	if err != nil {
+		sc.logger.Error("scheduled run failed", "error", err, "duration", end.Sub(start).String())
+		return
+	}
+	sc.logger.Info("scheduled run finished", "duration", end.Sub(start).String())
+}
+
+func (sc *Scheduler) setNextRun(next time.Time) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if next.IsZero() {
+		sc.status.NextRun = nil
+		return
+	}
+	next = next.UTC()
+	sc.status.NextRun = &next
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	base := time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		cronExpr string
+		interval time.Duration
+		wantNext time.Time
+		wantErr  bool
+	}{
+		{"cron every 6 hours", "0 */6 * * *", 0, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC), false},
+		{"cron descriptor", "@daily", 0, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), false},
+		{"interval", "", 2 * time.Hour, base.Add(2 * time.Hour), false},
+		{"invalid cron", "not a cron", 0, time.Time{}, true},
+		{"both set", "@daily", time.Hour, time.Time{}, true},
+		{"neither set", "", 0, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sched, err := parseSchedule(tt.cronExpr, tt.interval)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := sched.Next(base); !got.Equal(tt.wantNext) {
+				t.Errorf("Next(%v) = %v, want %v", base, got, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestSchedulerNoOverlap(t *testing.T) {
+	var running, maxRunning, runs atomic.Int32
+
+	scheduler := NewScheduler(intervalSchedule(5*time.Millisecond), slog.New(slog.DiscardHandler), func(ctx context.Context) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		runs.Add(1)
+		time.Sleep(20 * time.Millisecond) // longer than the interval
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	scheduler.Start(ctx, true)
+
+	if got := maxRunning.Load(); got != 1 {
+		t.Errorf("max concurrent runs = %d, want 1", got)
+	}
+	if got := runs.Load(); got < 2 {
+		t.Errorf("runs = %d, want at least 2", got)
+	}
+
+	status := scheduler.Status()
+	if status.Running {
+		t.Error("scheduler should not be running after Start returns")
+	}
+	if status.Runs != int(runs.Load()) {
+		t.Errorf("status.Runs = %d, want %d", status.Runs, runs.Load())
+	}
+	if status.NextRun != nil {
+		t.Errorf("status.NextRun should be cleared after stop, got %v", status.NextRun)
+	}
+}
+
+func TestSchedulerStatus(t *testing.T) {
+	scheduler := NewScheduler(intervalSchedule(time.Hour), slog.New(slog.DiscardHandler), func(ctx context.Context) error {
+		return errors.New("boom")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		scheduler.Start(ctx, true)
+		close(done)
+	}()
+
+	// Wait for the first run to finish and the next one to be scheduled
+	deadline := time.Now().Add(time.Second)
+	for scheduler.Status().Runs == 0 || scheduler.Status().NextRun == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("scheduler did not complete a run")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	rec := httptest.NewRecorder()
+	scheduler.ServeHTTP(rec, httptest.NewRequest("GET", "/schedule", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var status SchedulerStatus
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("failed to decode status: %v", err)
+	}
+	if status.Runs != 1 || status.Failures != 1 {
+		t.Errorf("runs/failures = %d/%d, want 1/1", status.Runs, status.Failures)
+	}
+	if status.LastRunError != "boom" {
+		t.Errorf("LastRunError = %q, want %q", status.LastRunError, "boom")
+	}
+	if status.LastRunStart == nil || status.LastRunEnd == nil {
+		t.Error("last run start and end should be set")
+	}
+	if status.NextRun == nil || status.NextRun.Before(*status.LastRunEnd) {
+		t.Errorf("NextRun = %v, want after last run end", status.NextRun)
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"slices"
+)
+
+// Scope limits a run to a subset of categories, products and regions, all
+// identified by their eKalathi IDs. An empty include list means everything;
+// excludes always win over includes.
+type Scope struct {
+	IncludeCategories []int `json:"includeCategories,omitempty"`
+	ExcludeCategories []int `json:"excludeCategories,omitempty"`
+	IncludeProducts   []int `json:"includeProducts,omitempty"`
+	ExcludeProducts   []int `json:"excludeProducts,omitempty"`
+	IncludeRegions    []int `json:"includeRegions,omitempty"`
+	ExcludeRegions    []int `json:"excludeRegions,omitempty"`
+}
+
+// IsZero reports whether the scope covers everything
+func (sc Scope) IsZero() bool {
+	return len(sc.IncludeCategories) == 0 && len(sc.ExcludeCategories) == 0 &&
+		len(sc.IncludeProducts) == 0 && len(sc.ExcludeProducts) == 0 &&
+		len(sc.IncludeRegions) == 0 && len(sc.ExcludeRegions) == 0
+}
+
+// AllowsCategory reports whether a category is in scope
+func (sc Scope) AllowsCategory(id int) bool {
+	return sc.allowsCategoryPath(id)
+}
+
+// AllowsProduct reports whether a product is in scope
+func (sc Scope) AllowsProduct(id int) bool {
+	return allows(sc.IncludeProducts, sc.ExcludeProducts, id)
+}
+
+// AllowsRegion reports whether a region is in scope
+func (sc Scope) AllowsRegion(id int) bool {
+	return allows(sc.IncludeRegions, sc.ExcludeRegions, id)
+}
+
+// allowsCategoryPath checks a category together with its ancestors: it is in
+// scope if any of them is included and none of them is excluded
+func (sc Scope) allowsCategoryPath(ids ...int) bool {
+	included := len(sc.IncludeCategories) == 0
+	for _, id := range ids {
+		if slices.Contains(sc.ExcludeCategories, id) {
+			return false
+		}
+		if slices.Contains(sc.IncludeCategories, id) {
+			included = true
+		}
+	}
+	return included
+}
+
+// withSubcategories returns a copy of the scope where including or excluding
+// a parent category also includes or excludes its subcategories. parents
+// maps each subcategory's external ID to its parent's.
+func (sc Scope) withSubcategories(parents map[int]int) Scope {
+	expand := func(ids []int) []int {
+		if len(ids) == 0 {
+			return ids
+		}
+		expanded := slices.Clone(ids)
+		for child, parent := range parents {
+			if slices.Contains(ids, parent) && !slices.Contains(expanded, child) {
+				expanded = append(expanded, child)
+			}
+		}
+		return expanded
+	}
+
+	sc.IncludeCategories = expand(sc.IncludeCategories)
+	sc.ExcludeCategories = expand(sc.ExcludeCategories)
+	return sc
+}
+
+func allows(include, exclude []int, id int) bool {
+	if slices.Contains(exclude, id) {
+		return false
+	}
+	return len(include) == 0 || slices.Contains(include, id)
+}
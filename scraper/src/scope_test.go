@@ -0,0 +1,93 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestScopeAllows(t *testing.T) {
+	tests := []struct {
+		name  string
+		scope Scope
+		check func(Scope, int) bool
+		id    int
+		want  bool
+	}{
+		{"zero scope allows category", Scope{}, Scope.AllowsCategory, 1, true},
+		{"zero scope allows product", Scope{}, Scope.AllowsProduct, 1, true},
+		{"zero scope allows region", Scope{}, Scope.AllowsRegion, 1, true},
+		{"included category", Scope{IncludeCategories: []int{1, 2}}, Scope.AllowsCategory, 2, true},
+		{"category not included", Scope{IncludeCategories: []int{1, 2}}, Scope.AllowsCategory, 3, false},
+		{"excluded category", Scope{ExcludeCategories: []int{3}}, Scope.AllowsCategory, 3, false},
+		{"exclude wins over include", Scope{IncludeProducts: []int{5}, ExcludeProducts: []int{5}}, Scope.AllowsProduct, 5, false},
+		{"product not excluded", Scope{ExcludeProducts: []int{5}}, Scope.AllowsProduct, 6, true},
+		{"included region", Scope{IncludeRegions: []int{7}}, Scope.AllowsRegion, 7, true},
+		{"region not included", Scope{IncludeRegions: []int{7}}, Scope.AllowsRegion, 8, false},
+		{"excluded region", Scope{ExcludeRegions: []int{8}}, Scope.AllowsRegion, 8, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.check(tt.scope, tt.id); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeAllowsCategoryPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		scope Scope
+		path  []int
+		want  bool
+	}{
+		{"zero scope", Scope{}, []int{10, 1}, true},
+		{"parent included", Scope{IncludeCategories: []int{1}}, []int{10, 1}, true},
+		{"subcategory included", Scope{IncludeCategories: []int{10}}, []int{10, 1}, true},
+		{"sibling included", Scope{IncludeCategories: []int{11}}, []int{10, 1}, false},
+		{"parent excluded", Scope{ExcludeCategories: []int{1}}, []int{10, 1}, false},
+		{"subcategory excluded under included parent", Scope{IncludeCategories: []int{1}, ExcludeCategories: []int{10}}, []int{10, 1}, false},
+		{"top-level category without parent", Scope{IncludeCategories: []int{1}}, []int{1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scope.allowsCategoryPath(tt.path...); got != tt.want {
+				t.Errorf("allowsCategoryPath(%v) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeWithSubcategories(t *testing.T) {
+	parents := map[int]int{10: 1, 11: 1, 20: 2}
+
+	scope := Scope{
+		IncludeCategories: []int{1},
+		ExcludeCategories: []int{2},
+		IncludeProducts:   []int{99},
+	}
+	got := scope.withSubcategories(parents)
+
+	include := slices.Sorted(slices.Values(got.IncludeCategories))
+	if !slices.Equal(include, []int{1, 10, 11}) {
+		t.Errorf("IncludeCategories = %v, want [1 10 11]", include)
+	}
+	exclude := slices.Sorted(slices.Values(got.ExcludeCategories))
+	if !slices.Equal(exclude, []int{2, 20}) {
+		t.Errorf("ExcludeCategories = %v, want [2 20]", exclude)
+	}
+	if !slices.Equal(got.IncludeProducts, []int{99}) {
+		t.Errorf("IncludeProducts = %v, want [99]", got.IncludeProducts)
+	}
+
+	// The original scope must not be modified
+	if !slices.Equal(scope.IncludeCategories, []int{1}) {
+		t.Errorf("original IncludeCategories modified: %v", scope.IncludeCategories)
+	}
+
+	if empty := (Scope{}).withSubcategories(parents); !empty.IsZero() {
+		t.Errorf("zero scope should stay zero, got %+v", empty)
+	}
+}
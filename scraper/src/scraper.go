@@ -3,33 +3,38 @@ package main
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
-	"os"
+	"slices"
+	"sync"
 	"time"
 
-	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pheever/cy-price-watchdog/scraper/src/archive"
+	"github.com/pheever/cy-price-watchdog/scraper/src/config"
 	ekalathiapi "github.com/pheever/cy-price-watchdog/scraper/src/ekalathi-api"
 	"github.com/pheever/cy-price-watchdog/scraper/src/metrics"
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var logger *slog.Logger
-
-func init() {
-	logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}))
-}
-
-// Scraper holds the HTTP client and database pool
+// Scraper holds the HTTP client, store, logger and settings
 type Scraper struct {
-	client  *http.Client
-	db      *pgxpool.Pool
-	metrics *metrics.Collector
+	client   *http.Client
+	db       store.Store
+	metrics  *metrics.Collector
+	progress *Progress
+	logger   *slog.Logger
+	scrape   config.Scrape
+	retry    config.Retry
+	dryRun   *dryRun         // set for the duration of a dry run, see forRun
+	drift    *schemaDrift    // set for the duration of a run, see forRun
+	archive  archive.Bucket  // where run responses are archived, if anywhere
+	replay   *archive.Replay // set while reprocessing an archived run
+	replayed *replayedPrices // the prices a replay derived, stored once it has succeeded
 }
 
 // WorkItem represents an item in the retry queue
@@ -38,35 +43,63 @@ type WorkItem[T any] struct {
 	Retries int
 }
 
-const maxRetries = 3
-
-func NewScraper(dbURL string, metricsCollector *metrics.Collector) (*Scraper, error) {
-	pool, err := pgxpool.New(context.Background(), dbURL)
+func NewScraper(cfg *config.Config, metricsCollector *metrics.Collector, logger *slog.Logger) (*Scraper, error) {
+	db, err := store.Open(context.Background(), cfg.Database.URL, store.Options{QueryTracer: dbTracer{}})
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, err
+	}
+
+	var bucket archive.Bucket
+	if cfg.Archive.URL != "" {
+		bucket, err = archive.Open(cfg.Archive.URL, archive.Options{
+			S3Endpoint:        cfg.Archive.S3Endpoint,
+			S3Region:          cfg.Archive.S3Region,
+			S3AccessKeyID:     cfg.Archive.S3AccessKeyID,
+			S3SecretAccessKey: cfg.Archive.S3SecretAccessKey,
+			Client:            &http.Client{Timeout: cfg.HTTP.Timeout},
+		})
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
 
-	if err := pool.Ping(context.Background()); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	client := newHTTPClient(cfg.HTTP)
+	if bucket != nil {
+		client.Transport = &archiveTransport{next: client.Transport, logger: logger}
+	}
+	client.Transport = &tracingTransport{next: client.Transport}
+	if metricsCollector != nil {
+		client.Transport = metricsCollector.InstrumentTransport(client.Transport, "ekalathi_request", ekalathiapi.Endpoint)
 	}
 
 	return &Scraper{
-		client: &http.Client{
-			Timeout: 120 * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConns:          10,
-				IdleConnTimeout:       60 * time.Second,
-				DisableCompression:    false,
-				DisableKeepAlives:     false,
-				MaxIdleConnsPerHost:   5,
-				ResponseHeaderTimeout: 120 * time.Second,
-			},
-		},
-		db:      pool,
-		metrics: metricsCollector,
+		client:   client,
+		db:       db,
+		metrics:  metricsCollector,
+		progress: NewProgress(),
+		logger:   logger,
+		scrape:   cfg.Scrape,
+		retry:    cfg.Retry,
+		archive:  bucket,
 	}, nil
 }
 
+// newHTTPClient returns the client used for all eKalathi requests
+func newHTTPClient(cfg config.HTTP) *http.Client {
+	return &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &http.Transport{
+			MaxIdleConns:          cfg.MaxIdleConns,
+			IdleConnTimeout:       cfg.IdleConnTimeout,
+			DisableCompression:    false,
+			DisableKeepAlives:     false,
+			MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+			ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		},
+	}
+}
+
 func (s *Scraper) updateHeaders(req *http.Request) *http.Request {
 	req.Header.Set("User-Agent", "cy-price-watchdog/1.0")
 	req.Header.Set("Accept", "application/json, text/plain, */*")
@@ -78,18 +111,20 @@ func (s *Scraper) updateHeaders(req *http.Request) *http.Request {
 }
 
 func (s *Scraper) Close() {
-	s.db.Close()
+	if s.db != nil {
+		s.db.Close()
+	}
 }
 
 // --- Category Methods ---
 
-func (s *Scraper) fetchCategories() ([]ekalathiapi.CategoryResponse, error) {
+func (s *Scraper) fetchCategories(ctx context.Context) ([]ekalathiapi.CategoryResponse, error) {
 	req, err := ekalathiapi.GetCategory(ekalathiapi.CategoryRequest{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create category request: %w", err)
 	}
 
-	req = s.updateHeaders(req)
+	req = s.updateHeaders(req.WithContext(ctx))
 
 	resp, err := s.client.Do(req)
 	if err != nil {
@@ -102,7 +137,7 @@ func (s *Scraper) fetchCategories() ([]ekalathiapi.CategoryResponse, error) {
 	}
 
 	var categories []ekalathiapi.CategoryResponse
-	if err := json.NewDecoder(resp.Body).Decode(&categories); err != nil {
+	if err := s.decodeResponse(ctx, ekalathiapi.CategoriesEndpoint, "categories", resp.Body, &categories); err != nil {
 		return nil, fmt.Errorf("failed to parse categories: %w", err)
 	}
 
@@ -110,36 +145,37 @@ func (s *Scraper) fetchCategories() ([]ekalathiapi.CategoryResponse, error) {
 }
 
 func (s *Scraper) upsertCategory(ctx context.Context, externalID int, code, name, nameEnglish string, parentID *string) (string, error) {
-	var id string
-	now := time.Now().UTC()
-
-	err := s.db.QueryRow(ctx, `
-		INSERT INTO "Category" (id, "externalId", code, name, "nameEnglish", "parentId", "createdAt", "updatedAt")
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
-		ON CONFLICT ("externalId") DO UPDATE SET
-			code = EXCLUDED.code,
-			name = EXCLUDED.name,
-			"nameEnglish" = EXCLUDED."nameEnglish",
-			"parentId" = EXCLUDED."parentId",
-			"updatedAt" = EXCLUDED."updatedAt"
-		RETURNING id
-	`, uuid.New().String(), externalID, code, name, nameEnglish, parentID, now).Scan(&id)
-
-	if err != nil {
-		return "", fmt.Errorf("failed to upsert category: %w", err)
+	if s.dryRun != nil {
+		return s.previewUpsert(ctx, store.KindCategory, externalID, name)
 	}
 
-	return id, nil
+	return s.db.UpsertCategory(ctx, store.Category{
+		ExternalID:  externalID,
+		Code:        code,
+		Name:        name,
+		NameEnglish: nameEnglish,
+		ParentID:    parentID,
+	})
 }
 
 func (s *Scraper) scrapeCategories(ctx context.Context) (map[int]string, error) {
-	logger.Info("fetching categories")
-	categories, err := s.fetchCategories()
+	s.logger.Info("fetching categories")
+	ctx, span := startPhaseSpan(ctx, phaseCategories)
+	defer span.End()
+	s.progress.StartPhase(phaseCategories, 0)
+	categories, err := s.fetchCategories(ctx)
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		return nil, fmt.Errorf("failed to fetch categories: %w", err)
 	}
 
-	logger.Info("found parent categories", "count", len(categories))
+	s.logger.Info("found parent categories", "count", len(categories))
+
+	total := len(categories)
+	for _, cat := range categories {
+		total += len(cat.ProductCategoryResponses)
+	}
+	s.progress.SetTotal(total)
 
 	// Map external category ID to internal UUID
 	categoryMap := make(map[int]string)
@@ -147,20 +183,22 @@ func (s *Scraper) scrapeCategories(ctx context.Context) (map[int]string, error)
 	for _, cat := range categories {
 		parentID, err := s.upsertCategory(ctx, cat.ID, cat.Code, cat.Name, cat.NameEnglish, nil)
 		if err != nil {
-			logger.Error("error upserting parent category", "categoryID", cat.ID, "error", err)
+			s.logger.Error("error upserting parent category", "categoryID", cat.ID, "error", err)
 			continue
 		}
 		categoryMap[cat.ID] = parentID
-		logger.Debug("upserted parent category", "name", cat.Name, "nameEnglish", cat.NameEnglish)
+		s.progress.Done(0)
+		s.logger.Debug("upserted parent category", "name", cat.Name, "nameEnglish", cat.NameEnglish)
 
 		for _, subcat := range cat.ProductCategoryResponses {
 			subcatID, err := s.upsertCategory(ctx, subcat.ID, subcat.Code, subcat.Name, subcat.NameEnglish, &parentID)
 			if err != nil {
-				logger.Error("error upserting subcategory", "subcategoryID", subcat.ID, "error", err)
+				s.logger.Error("error upserting subcategory", "subcategoryID", subcat.ID, "error", err)
 				continue
 			}
 			categoryMap[subcat.ID] = subcatID
-			logger.Debug("upserted subcategory", "name", subcat.Name, "nameEnglish", subcat.NameEnglish)
+			s.progress.Done(0)
+			s.logger.Debug("upserted subcategory", "name", subcat.Name, "nameEnglish", subcat.NameEnglish)
 		}
 	}
 
@@ -169,17 +207,17 @@ func (s *Scraper) scrapeCategories(ctx context.Context) (map[int]string, error)
 
 // --- Product Methods ---
 
-func (s *Scraper) fetchProductsPage(categoryID, page int) ([]ekalathiapi.Product, bool, error) {
+func (s *Scraper) fetchProductsPage(ctx context.Context, categoryID, page int) ([]ekalathiapi.Product, bool, error) {
 	req, err := ekalathiapi.GetProducts(ekalathiapi.ProductRequest{
 		CategoryIds: []int{categoryID},
 		Page:        page,
-		Size:        20,
+		Size:        s.scrape.ProductPageSize,
 	})
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to create products request: %w", err)
 	}
 
-	req = s.updateHeaders(req)
+	req = s.updateHeaders(req.WithContext(ctx))
 
 	resp, err := s.client.Do(req)
 	if err != nil {
@@ -192,19 +230,19 @@ func (s *Scraper) fetchProductsPage(categoryID, page int) ([]ekalathiapi.Product
 	}
 
 	var result ekalathiapi.ProductListResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := s.decodeResponse(ctx, ekalathiapi.ProductsEndpoint, "products", resp.Body, &result); err != nil {
 		return nil, false, fmt.Errorf("failed to parse products: %w", err)
 	}
 
 	return result.Content, result.Last, nil
 }
 
-func (s *Scraper) fetchProducts(categoryID int) ([]ekalathiapi.Product, error) {
+func (s *Scraper) fetchProducts(ctx context.Context, categoryID int) ([]ekalathiapi.Product, error) {
 	var allProducts []ekalathiapi.Product
 	page := 0
 
 	for {
-		products, isLast, err := s.fetchProductsPage(categoryID, page)
+		products, isLast, err := s.fetchProductsPage(ctx, categoryID, page)
 		if err != nil {
 			return nil, err
 		}
@@ -217,46 +255,28 @@ func (s *Scraper) fetchProducts(categoryID int) ([]ekalathiapi.Product, error) {
 
 		page++
 		// Rate limiting between pages
-		time.Sleep(100 * time.Millisecond)
+		time.Sleep(s.scrape.PageDelay)
 	}
 
 	return allProducts, nil
 }
 
 func (s *Scraper) upsertProduct(ctx context.Context, externalID int, code, name, nameEnglish string, categoryID string) (string, error) {
-	var id string
-	now := time.Now().UTC()
-
-	err := s.db.QueryRow(ctx, `
-		INSERT INTO "Product" (id, "externalId", code, name, "nameEnglish", "categoryId", "createdAt", "updatedAt")
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
-		ON CONFLICT ("externalId") DO UPDATE SET
-			code = EXCLUDED.code,
-			name = EXCLUDED.name,
-			"nameEnglish" = EXCLUDED."nameEnglish",
-			"categoryId" = EXCLUDED."categoryId",
-			"updatedAt" = EXCLUDED."updatedAt"
-		RETURNING id
-	`, uuid.New().String(), externalID, code, name, nameEnglish, categoryID, now).Scan(&id)
-
-	if err != nil {
-		return "", fmt.Errorf("failed to upsert product: %w", err)
+	if s.dryRun != nil {
+		return s.previewUpsert(ctx, store.KindProduct, externalID, name)
 	}
 
-	return id, nil
+	return s.db.UpsertProduct(ctx, store.Product{
+		ExternalID:  externalID,
+		Code:        code,
+		Name:        name,
+		NameEnglish: nameEnglish,
+		CategoryID:  categoryID,
+	})
 }
 
 func (s *Scraper) getCategoryIDByName(ctx context.Context, categoryName string) (string, error) {
-	var id string
-	err := s.db.QueryRow(ctx, `
-		SELECT id FROM "Category" WHERE name = $1 LIMIT 1
-	`, categoryName).Scan(&id)
-
-	if err != nil {
-		return "", fmt.Errorf("failed to find category by name %s: %w", categoryName, err)
-	}
-
-	return id, nil
+	return s.db.CategoryIDByName(ctx, categoryName)
 }
 
 // categoryItem holds both external and internal IDs for queue processing
@@ -265,19 +285,31 @@ type categoryItem struct {
 	InternalID string
 }
 
-func (s *Scraper) scrapeProducts(ctx context.Context, categoryMap map[int]string) (map[int]string, error) {
-	logger.Info("fetching products")
+func (s *Scraper) scrapeProducts(ctx context.Context, categoryMap map[int]string, scope Scope) (map[int]string, error) {
+	s.logger.Info("fetching products")
+	ctx, span := startPhaseSpan(ctx, phaseProducts)
+	defer span.End()
 
 	// Map external product ID to internal UUID
 	productMap := make(map[int]string)
 
-	// Build initial queue from categoryMap
+	// Map internal category UUID back to external ID, to check the scope of
+	// the category each product resolves to
+	categoryExtIDs := make(map[string]int, len(categoryMap))
+
+	// Build initial queue from the categories in scope
 	queue := make([]WorkItem[categoryItem], 0, len(categoryMap))
 	for extID, intID := range categoryMap {
+		categoryExtIDs[intID] = extID
+		if !scope.AllowsCategory(extID) {
+			continue
+		}
 		queue = append(queue, WorkItem[categoryItem]{
 			Data: categoryItem{ExternalID: extID, InternalID: intID},
 		})
 	}
+	s.progress.StartPhase(phaseProducts, len(queue))
+	categoryCount := len(queue)
 
 	var failedCategories []int
 
@@ -293,56 +325,80 @@ func (s *Scraper) scrapeProducts(ctx context.Context, categoryMap map[int]string
 		extCategoryID := item.Data.ExternalID
 		categoryID := item.Data.InternalID
 
-		products, err := s.fetchProducts(extCategoryID)
+		itemCtx, itemSpan := tracer.Start(ctx, "scrape category", trace.WithAttributes(
+			attrCategoryID.Int(extCategoryID),
+			attrAttempt.Int(item.Retries+1),
+		))
+		products, err := s.fetchProducts(itemCtx, extCategoryID)
 		if err != nil {
-			if item.Retries < maxRetries {
+			endSpan(itemSpan, err)
+			if item.Retries < s.retry.MaxRetries {
 				item.Retries++
 				queue = append(queue, item) // back of the line
-				logger.Warn("retrying category fetch", "categoryID", extCategoryID, "attempt", item.Retries)
+				s.logger.Warn("retrying category fetch", "categoryID", extCategoryID, "attempt", item.Retries)
+				s.progress.Retry(item.Retries)
 			} else {
-				logger.Error("failed to fetch products for category after retries", "categoryID", extCategoryID, "error", err)
+				s.logger.Error("failed to fetch products for category after retries", "categoryID", extCategoryID, "error", err)
 				failedCategories = append(failedCategories, extCategoryID)
+				s.recordFailure(ctx, store.Failure{Phase: phaseProducts, CategoryExternalID: &extCategoryID, Error: err.Error()})
+				s.event(eventCategoryFailed, map[string]interface{}{
+					"category_id": extCategoryID,
+					"retries":     item.Retries,
+					"error":       err.Error(),
+				})
+				s.progress.Fail(item.Retries)
 			}
 			continue
 		}
 
-		logger.Info("found products in category", "count", len(products), "categoryID", extCategoryID)
+		s.logger.Info("found products in category", "count", len(products), "categoryID", extCategoryID)
+		itemSpan.SetAttributes(attribute.Int("ekalathi.products", len(products)))
 
 		for _, product := range products {
+			if !scope.AllowsProduct(product.ProductMasterId) {
+				continue
+			}
+
 			// Use category name from product to find correct category
 			prodCategoryID := categoryID
 			if product.ProductCategoryName != "" {
-				if foundID, err := s.getCategoryIDByName(ctx, product.ProductCategoryName); err == nil {
+				if foundID, err := s.getCategoryIDByName(itemCtx, product.ProductCategoryName); err == nil {
 					prodCategoryID = foundID
 				}
 			}
+			if extID, ok := categoryExtIDs[prodCategoryID]; ok && !scope.AllowsCategory(extID) {
+				continue
+			}
 
-			productID, err := s.upsertProduct(ctx, product.ProductMasterId, product.Code, product.Name, product.ProductCategoryNameEnglish, prodCategoryID)
+			productID, err := s.upsertProduct(itemCtx, product.ProductMasterId, product.Code, product.Name, product.ProductCategoryNameEnglish, prodCategoryID)
 			if err != nil {
-				logger.Error("error upserting product", "productID", product.ProductMasterId, "error", err)
+				s.logger.Error("error upserting product", "productID", product.ProductMasterId, "error", err)
 				continue
 			}
 			productMap[product.ProductMasterId] = productID
 		}
+		itemSpan.End()
+		s.progress.Done(item.Retries)
 	}
 
 	if len(failedCategories) > 0 {
-		logger.Warn("some categories failed after all retries", "count", len(failedCategories), "categoryIDs", failedCategories)
+		s.logger.Warn("some categories failed after all retries", "count", len(failedCategories), "categoryIDs", failedCategories)
 	}
+	s.checkFailureRate(phaseProducts, len(failedCategories), categoryCount)
 
-	logger.Info("scraped unique products", "count", len(productMap))
+	s.logger.Info("scraped unique products", "count", len(productMap))
 	return productMap, nil
 }
 
 // --- Region Methods ---
 
-func (s *Scraper) fetchRegions() ([]ekalathiapi.RegionResponse, error) {
+func (s *Scraper) fetchRegions(ctx context.Context) ([]ekalathiapi.RegionResponse, error) {
 	req, err := ekalathiapi.GetRegions(ekalathiapi.RegionRequest{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create regions request: %w", err)
 	}
 
-	req = s.updateHeaders(req)
+	req = s.updateHeaders(req.WithContext(ctx))
 
 	resp, err := s.client.Do(req)
 	if err != nil {
@@ -355,7 +411,7 @@ func (s *Scraper) fetchRegions() ([]ekalathiapi.RegionResponse, error) {
 	}
 
 	var regions []ekalathiapi.RegionResponse
-	if err := json.NewDecoder(resp.Body).Decode(&regions); err != nil {
+	if err := s.decodeResponse(ctx, ekalathiapi.RegionsEndpoint, "regions", resp.Body, &regions); err != nil {
 		return nil, fmt.Errorf("failed to parse regions: %w", err)
 	}
 
@@ -364,10 +420,10 @@ func (s *Scraper) fetchRegions() ([]ekalathiapi.RegionResponse, error) {
 
 // --- Store Methods ---
 
-func (s *Scraper) fetchRetailBranchesPage(productID, regionID, page int) ([]ekalathiapi.RetailBranchResponse, bool, error) {
+func (s *Scraper) fetchRetailBranchesPage(ctx context.Context, productID, regionID, page int) ([]ekalathiapi.RetailBranchResponse, bool, error) {
 	req, err := ekalathiapi.GetBranches(ekalathiapi.RetailBranchRequest{
 		Page:      page,
-		Size:      10,
+		Size:      s.scrape.BranchPageSize,
 		ProductId: productID,
 		RegionIds: []int{regionID},
 	})
@@ -375,7 +431,7 @@ func (s *Scraper) fetchRetailBranchesPage(productID, regionID, page int) ([]ekal
 		return nil, false, fmt.Errorf("failed to create branches request: %w", err)
 	}
 
-	req = s.updateHeaders(req)
+	req = s.updateHeaders(req.WithContext(ctx))
 
 	resp, err := s.client.Do(req)
 
@@ -395,19 +451,19 @@ func (s *Scraper) fetchRetailBranchesPage(productID, regionID, page int) ([]ekal
 	}
 
 	var result ekalathiapi.RetailBranchListResponse
-	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&result); err != nil {
+	if err := s.decodeResponse(ctx, ekalathiapi.RetailBranchesEndpoint, "branches", bytes.NewReader(body), &result); err != nil {
 		return nil, false, fmt.Errorf("failed to parse branches (body length: %d): %w", len(body), err)
 	}
 
 	return result.Content, result.Last, nil
 }
 
-func (s *Scraper) fetchRetailBranches(productID, regionID int) ([]ekalathiapi.RetailBranchResponse, error) {
+func (s *Scraper) fetchRetailBranches(ctx context.Context, productID, regionID int) ([]ekalathiapi.RetailBranchResponse, error) {
 	var allBranches []ekalathiapi.RetailBranchResponse
 	page := 0
 
 	for {
-		branches, isLast, err := s.fetchRetailBranchesPage(productID, regionID, page)
+		branches, isLast, err := s.fetchRetailBranchesPage(ctx, productID, regionID, page)
 		if err != nil {
 			return nil, err
 		}
@@ -419,46 +475,49 @@ func (s *Scraper) fetchRetailBranches(productID, regionID int) ([]ekalathiapi.Re
 		}
 
 		page++
-		time.Sleep(100 * time.Millisecond)
+		time.Sleep(s.scrape.PageDelay)
 	}
 
 	return allBranches, nil
 }
 
 func (s *Scraper) upsertStore(ctx context.Context, externalID int, name, chain, district, location string) (string, error) {
-	var id string
-	now := time.Now().UTC()
-
-	err := s.db.QueryRow(ctx, `
-		INSERT INTO "Store" (id, "externalId", name, chain, district, location, "createdAt", "updatedAt")
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
-		ON CONFLICT ("externalId") DO UPDATE SET
-			name = EXCLUDED.name,
-			chain = EXCLUDED.chain,
-			district = COALESCE(EXCLUDED.district, "Store".district),
-			location = EXCLUDED.location,
-			"updatedAt" = EXCLUDED."updatedAt"
-		RETURNING id
-	`, uuid.New().String(), externalID, name, chain, district, location, now).Scan(&id)
-
-	if err != nil {
-		return "", fmt.Errorf("failed to upsert store: %w", err)
+	if s.dryRun != nil {
+		return s.previewUpsert(ctx, store.KindStore, externalID, name)
 	}
 
-	return id, nil
+	return s.db.UpsertStore(ctx, store.Branch{
+		ExternalID: externalID,
+		Name:       name,
+		Chain:      chain,
+		District:   district,
+		Location:   location,
+	})
 }
 
 func (s *Scraper) insertPrice(ctx context.Context, productID, storeID string, price float64) error {
-	_, err := s.db.Exec(ctx, `
-		INSERT INTO "Price" (id, "productId", "storeId", price, "scrapedAt")
-		VALUES ($1, $2, $3, $4, $5)
-	`, uuid.New().String(), productID, storeID, price, time.Now().UTC())
+	if s.dryRun != nil {
+		return s.previewPrice(ctx, productID, storeID, price)
+	}
 
-	if err != nil {
-		return fmt.Errorf("failed to insert price: %w", err)
+	if s.replay != nil {
+		s.replayed.add(store.Price{
+			ProductID: productID,
+			StoreID:   storeID,
+			Price:     price,
+			ScrapedAt: s.replay.LastServed(),
+			RunID:     s.replay.Run().ID,
+		})
+		return nil
 	}
 
-	return nil
+	return s.db.InsertPrice(ctx, store.Price{
+		ProductID: productID,
+		StoreID:   storeID,
+		Price:     price,
+		ScrapedAt: time.Now().UTC(),
+		RunID:     runIDFromContext(ctx),
+	})
 }
 
 // productRegionItem holds product and region info for queue processing
@@ -469,143 +528,446 @@ type productRegionItem struct {
 	RegionName   string
 }
 
-func (s *Scraper) scrapePrices(ctx context.Context, productMap map[int]string, regions []ekalathiapi.RegionResponse) error {
-	logger.Info("fetching prices from retail branches", "productCount", len(productMap), "regionCount", len(regions))
+func (s *Scraper) scrapePrices(ctx context.Context, productMap map[int]string, regions []ekalathiapi.RegionResponse, scope Scope) error {
+	regions = slices.DeleteFunc(slices.Clone(regions), func(r ekalathiapi.RegionResponse) bool {
+		return !scope.AllowsRegion(r.ID)
+	})
 
-	storeMap := make(map[int]string) // cache store IDs
-	priceCount := 0
+	s.logger.Info("fetching prices from retail branches", "productCount", len(productMap), "regionCount", len(regions))
 
-	// Build initial queue: each product x each region
-	queue := make([]WorkItem[productRegionItem], 0, len(productMap)*len(regions))
+	// Each product in scope x each region in scope
+	items := make([]productRegionItem, 0, len(productMap)*len(regions))
 	for extID, intID := range productMap {
+		if !scope.AllowsProduct(extID) {
+			continue
+		}
 		for _, region := range regions {
-			queue = append(queue, WorkItem[productRegionItem]{
-				Data: productRegionItem{
-					ProductExtID: extID,
-					ProductIntID: intID,
-					RegionID:     region.ID,
-					RegionName:   region.Name,
-				},
+			items = append(items, productRegionItem{
+				ProductExtID: extID,
+				ProductIntID: intID,
+				RegionID:     region.ID,
+				RegionName:   region.Name,
 			})
 		}
 	}
 
-	var failedItems []productRegionItem
+	return s.scrapePriceItems(ctx, items)
+}
+
+// scrapePriceItems fetches branch prices for each product-region pair, with
+// up to scrape.concurrency pairs in flight. The workers share one rate
+// limit: a pair starts at most every scrape.item_delay, however many run.
+func (s *Scraper) scrapePriceItems(ctx context.Context, items []productRegionItem) error {
+	ctx, span := startPhaseSpan(ctx, phasePrices)
+	defer span.End()
+	s.progress.StartPhase(phasePrices, len(items))
+	state := &priceScrape{stores: make(map[int]string), limiter: newRateLimiter(s.scrape.ItemDelay)}
 
-	// Process queue with deferred retries
+	queue := make([]WorkItem[productRegionItem], 0, len(items))
+	for _, item := range items {
+		queue = append(queue, WorkItem[productRegionItem]{Data: item})
+	}
+
+	// Process queue in rounds; failed items are retried in the next round,
+	// after every other item
 	for len(queue) > 0 {
+		queue = s.scrapePriceRound(ctx, queue, state)
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
+	}
 
-		item := queue[0]
-		queue = queue[1:]
+	failedItems, priceCount := state.failed, state.prices
+	if len(failedItems) > 0 {
+		s.logger.Warn("some product-region combinations failed after all retries", "count", len(failedItems))
+		s.metrics.RecordCount("failed_items", len(failedItems), map[string]string{"phase": "prices"})
+	}
+	s.checkFailureRate(phasePrices, len(failedItems), len(items))
+	if priceCount == 0 && len(items) > len(failedItems) {
+		s.event(eventAnomalyDetected, map[string]interface{}{
+			"kind":  "no_prices",
+			"phase": phasePrices,
+			"items": len(items),
+		})
+	}
 
-		branches, err := s.fetchRetailBranches(item.Data.ProductExtID, item.Data.RegionID)
-		if err != nil {
-			if item.Retries < maxRetries {
-				item.Retries++
-				queue = append(queue, item) // back of the line
-				logger.Warn("retrying branch fetch", "productID", item.Data.ProductExtID, "regionID", item.Data.RegionID, "attempt", item.Retries)
-			} else {
-				logger.Error("failed to fetch branches after retries", "productID", item.Data.ProductExtID, "regionID", item.Data.RegionID, "error", err)
-				failedItems = append(failedItems, item.Data)
-			}
-			continue
-		}
+	s.metrics.RecordCount("prices", priceCount, nil)
+	s.metrics.RecordCount("stores", len(state.stores), nil)
+	s.logger.Info("inserted price records", "priceCount", priceCount, "storeCount", len(state.stores))
+	return nil
+}
 
-		for _, branch := range branches {
-			// Upsert store if not cached
-			storeID, exists := storeMap[branch.ID]
-			if !exists {
-				location := branch.PostalAddress
-				if branch.BranchLatitude != "" && branch.BranchLongitude != "" {
-					location = fmt.Sprintf("%s (%s, %s)", branch.PostalAddress, branch.BranchLatitude, branch.BranchLongitude)
-				}
+// priceScrape is the state the workers of the prices phase share
+type priceScrape struct {
+	limiter *rateLimiter
 
-				storeID, err = s.upsertStore(ctx, branch.ID, branch.Name, branch.CompanyName, item.Data.RegionName, location)
-				if err != nil {
-					logger.Error("error upserting store", "storeID", branch.ID, "error", err)
-					continue
-				}
-				storeMap[branch.ID] = storeID
+	mu     sync.Mutex
+	stores map[int]string // cached store IDs by eKalathi branch ID
+	prices int
+	failed []productRegionItem
+	retry  []WorkItem[productRegionItem]
+}
+
+// rateLimiter spaces out requests made from any number of goroutines
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // earliest time of the next request
+}
+
+func newRateLimiter(interval time.Duration) *rateLimiter {
+	return &rateLimiter{interval: interval}
+}
+
+// wait blocks until interval has passed since the previous caller's turn,
+// or ctx is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	at := time.Now()
+	if l.next.After(at) {
+		at = l.next
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// scrapePriceRound scrapes every item in queue and returns the ones to retry
+func (s *Scraper) scrapePriceRound(ctx context.Context, queue []WorkItem[productRegionItem], state *priceScrape) []WorkItem[productRegionItem] {
+	slots := make(chan struct{}, max(s.scrape.Concurrency, 1))
+	var wg sync.WaitGroup
+
+	for _, item := range queue {
+		if ctx.Err() != nil {
+			break
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			s.scrapePriceItem(ctx, item, state)
+		}()
+	}
+	wg.Wait()
+
+	retry := state.retry
+	state.retry = nil
+	return retry
+}
+
+// scrapePriceItem stores the branch prices of one product-region pair
+func (s *Scraper) scrapePriceItem(ctx context.Context, item WorkItem[productRegionItem], state *priceScrape) {
+	itemCtx, itemSpan := tracer.Start(ctx, "scrape prices", trace.WithAttributes(
+		attrProductID.Int(item.Data.ProductExtID),
+		attrRegionID.Int(item.Data.RegionID),
+		attrAttempt.Int(item.Retries+1),
+	))
+	if err := state.limiter.wait(itemCtx); err != nil {
+		endSpan(itemSpan, err)
+		return
+	}
+	branches, err := s.fetchRetailBranches(itemCtx, item.Data.ProductExtID, item.Data.RegionID)
+	if err != nil {
+		endSpan(itemSpan, err)
+		if ctx.Err() != nil {
+			return
+		}
+		if item.Retries < s.retry.MaxRetries {
+			item.Retries++
+			state.mu.Lock()
+			state.retry = append(state.retry, item) // back of the line
+			state.mu.Unlock()
+			s.logger.Warn("retrying branch fetch", "productID", item.Data.ProductExtID, "regionID", item.Data.RegionID, "attempt", item.Retries)
+			s.progress.Retry(item.Retries)
+		} else {
+			s.logger.Error("failed to fetch branches after retries", "productID", item.Data.ProductExtID, "regionID", item.Data.RegionID, "error", err)
+			state.mu.Lock()
+			state.failed = append(state.failed, item.Data)
+			state.mu.Unlock()
+			s.recordFailure(ctx, store.Failure{
+				Phase:             phasePrices,
+				ProductExternalID: &item.Data.ProductExtID,
+				RegionID:          &item.Data.RegionID,
+				RegionName:        &item.Data.RegionName,
+				Error:             err.Error(),
+			})
+			s.progress.Fail(item.Retries)
+		}
+		return
+	}
+
+	inserted := 0
+	for _, branch := range branches {
+		// Upsert store if not cached
+		state.mu.Lock()
+		storeID, exists := state.stores[branch.ID]
+		state.mu.Unlock()
+		if !exists {
+			location := branch.PostalAddress
+			if branch.BranchLatitude != "" && branch.BranchLongitude != "" {
+				location = fmt.Sprintf("%s (%s, %s)", branch.PostalAddress, branch.BranchLatitude, branch.BranchLongitude)
 			}
 
-			// Insert price record
-			if err := s.insertPrice(ctx, item.Data.ProductIntID, storeID, branch.RetailerProductPrice); err != nil {
-				logger.Error("error inserting price", "productID", item.Data.ProductExtID, "storeID", branch.ID, "error", err)
+			storeID, err = s.upsertStore(itemCtx, branch.ID, branch.Name, branch.CompanyName, item.Data.RegionName, location)
+			if err != nil {
+				s.logger.Error("error upserting store", "storeID", branch.ID, "error", err)
 				continue
 			}
-			priceCount++
+			state.mu.Lock()
+			state.stores[branch.ID] = storeID
+			state.mu.Unlock()
 		}
 
-		// Rate limiting to be respectful to the API
-		time.Sleep(200 * time.Millisecond)
+		// Insert price record
+		if err := s.insertPrice(itemCtx, item.Data.ProductIntID, storeID, branch.RetailerProductPrice); err != nil {
+			s.logger.Error("error inserting price", "productID", item.Data.ProductExtID, "storeID", branch.ID, "error", err)
+			continue
+		}
+		inserted++
 	}
+	state.mu.Lock()
+	state.prices += inserted
+	state.mu.Unlock()
 
-	if len(failedItems) > 0 {
-		logger.Warn("some product-region combinations failed after all retries", "count", len(failedItems))
-		s.metrics.RecordCount("failed_items", len(failedItems), map[string]string{"phase": "prices"})
-	}
+	itemSpan.SetAttributes(attribute.Int("ekalathi.branches", len(branches)))
+	itemSpan.End()
+	s.progress.Done(item.Retries)
+}
 
-	s.metrics.RecordCount("prices", priceCount, nil)
-	s.metrics.RecordCount("stores", len(storeMap), nil)
-	logger.Info("inserted price records", "priceCount", priceCount, "storeCount", len(storeMap))
+// --- Main Run Method ---
+
+// Scrape phases, in the order Run executes them
+const (
+	phaseRegions    = "regions"
+	phaseCategories = "categories"
+	phaseProducts   = "products"
+	phasePrices     = "prices"
+	phaseAggregates = "aggregates"
+)
+
+var allPhases = []string{phaseRegions, phaseCategories, phaseProducts, phasePrices, phaseAggregates}
+
+// RunOptions narrows a scrape run to a subset of phases and a scope. The
+// zero value runs every phase over everything.
+type RunOptions struct {
+	Phases []string `json:"phases,omitempty"` // empty means all phases
+	Scope  Scope    `json:"scope"`
+	DryRun bool     `json:"dryRun,omitempty"` // fetch everything but write nothing, see dryRun
+}
+
+// Validate checks that every requested phase exists
+func (o RunOptions) Validate() error {
+	for _, phase := range o.Phases {
+		if !slices.Contains(allPhases, phase) {
+			return fmt.Errorf("unknown phase %q (want one of %v)", phase, allPhases)
+		}
+	}
 	return nil
 }
 
-// --- Main Run Method ---
+func (o RunOptions) runs(phase string) bool {
+	return len(o.Phases) == 0 || slices.Contains(o.Phases, phase)
+}
 
-func (s *Scraper) Run(ctx context.Context) error {
-	logger.Info("starting scraper")
+// Run scrapes eKalathi and stores the results. Phases that are skipped but
+// whose output a later phase needs are loaded from the database instead.
+func (s *Scraper) Run(ctx context.Context, opts RunOptions) (err error) {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	s, ctx = s.forRun(ctx)
+	ctx, finishArchive := s.startArchive(ctx, opts)
+	defer func() { finishArchive(err) }()
+	if opts.DryRun {
+		s.dryRun = &dryRun{}
+		defer func() {
+			s.logger.Info("dry run summary", "summary", s.dryRun.Summary())
+		}()
+	}
+	s.logger.Info("starting scraper", "phases", opts.Phases, "scope", opts.Scope, "dryRun", opts.DryRun)
+	runStart := time.Now()
+	s.progress.StartRun()
+	defer s.progress.FinishRun()
+
+	ctx, span := tracer.Start(ctx, "scraper.run", trace.WithAttributes(
+		attribute.String("scraper.run_id", runIDFromContext(ctx)),
+		attribute.StringSlice("scraper.phases", opts.Phases),
+	))
+	s.event(eventRunStarted, map[string]interface{}{"phases": opts.Phases, "scope": opts.Scope, "dry_run": opts.DryRun})
+	defer func() {
+		endSpan(span, err)
+		properties := map[string]interface{}{
+			"status":      "success",
+			"duration_ms": time.Since(runStart).Milliseconds(),
+		}
+		if err != nil {
+			properties["status"] = "error"
+			properties["error"] = err.Error()
+		}
+		s.event(eventRunCompleted, properties)
+	}()
+	defer s.reportDrift()
 
-	// Step 1: Fetch regions (districts)
-	startRegions := time.Now()
-	regions, err := s.fetchRegions()
-	if err != nil {
-		return fmt.Errorf("failed to fetch regions: %w", err)
+	// Step 1: Fetch regions (districts), needed by the prices phase
+	var regions []ekalathiapi.RegionResponse
+	if opts.runs(phaseRegions) || opts.runs(phasePrices) {
+		startRegions := time.Now()
+		s.progress.StartPhase(phaseRegions, 1)
+		regionsCtx, regionsSpan := startPhaseSpan(ctx, phaseRegions)
+		var err error
+		regions, err = s.fetchRegions(regionsCtx)
+		endSpan(regionsSpan, err)
+		if err != nil {
+			return fmt.Errorf("failed to fetch regions: %w", err)
+		}
+		s.progress.Done(0)
+		s.metrics.RecordDuration("regions", time.Since(startRegions), nil)
+		s.metrics.RecordCount("regions", len(regions), nil)
+		s.phaseCompleted(phaseRegions, startRegions)
+		s.logger.Info("fetched regions", "count", len(regions))
 	}
-	s.metrics.RecordDuration("regions", time.Since(startRegions), nil)
-	s.metrics.RecordCount("regions", len(regions), nil)
-	logger.Info("fetched regions", "count", len(regions))
 
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
 
 	// Step 2: Scrape categories
-	startCategories := time.Now()
-	categoryMap, err := s.scrapeCategories(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to scrape categories: %w", err)
+	var categoryMap map[int]string
+	switch {
+	case opts.runs(phaseCategories):
+		startCategories := time.Now()
+		var err error
+		categoryMap, err = s.scrapeCategories(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to scrape categories: %w", err)
+		}
+		s.metrics.RecordDuration("categories", time.Since(startCategories), nil)
+		s.metrics.RecordCount("categories", len(categoryMap), nil)
+		s.phaseCompleted(phaseCategories, startCategories)
+	case opts.runs(phaseProducts):
+		var err error
+		categoryMap, err = s.loadCategoryMap(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load categories: %w", err)
+		}
 	}
-	s.metrics.RecordDuration("categories", time.Since(startCategories), nil)
-	s.metrics.RecordCount("categories", len(categoryMap), nil)
 
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
 
 	// Step 3: Scrape products
-	startProducts := time.Now()
-	productMap, err := s.scrapeProducts(ctx, categoryMap)
-	if err != nil {
-		return fmt.Errorf("failed to scrape products: %w", err)
+	var productMap map[int]string
+	switch {
+	case opts.runs(phaseProducts):
+		startProducts := time.Now()
+		scope, err := s.withSubcategories(ctx, opts.Scope)
+		if err != nil {
+			return err
+		}
+		productMap, err = s.scrapeProducts(ctx, categoryMap, scope)
+		if err != nil {
+			return fmt.Errorf("failed to scrape products: %w", err)
+		}
+		s.metrics.RecordDuration("products", time.Since(startProducts), nil)
+		s.metrics.RecordCount("products", len(productMap), nil)
+		s.phaseCompleted(phaseProducts, startProducts)
+	case opts.runs(phasePrices):
+		var err error
+		productMap, err = s.loadProductMap(ctx, opts.Scope)
+		if err != nil {
+			return fmt.Errorf("failed to load products: %w", err)
+		}
 	}
-	s.metrics.RecordDuration("products", time.Since(startProducts), nil)
-	s.metrics.RecordCount("products", len(productMap), nil)
 
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
 
 	// Step 4: Scrape prices from retail branches (per region)
-	startPrices := time.Now()
-	if err := s.scrapePrices(ctx, productMap, regions); err != nil {
-		return fmt.Errorf("failed to scrape prices: %w", err)
+	if opts.runs(phasePrices) {
+		startPrices := time.Now()
+		if err := s.scrapePrices(ctx, productMap, regions, opts.Scope); err != nil {
+			return fmt.Errorf("failed to scrape prices: %w", err)
+		}
+		s.metrics.RecordDuration("prices", time.Since(startPrices), nil)
+		s.phaseCompleted(phasePrices, startPrices)
 	}
-	s.metrics.RecordDuration("prices", time.Since(startPrices), nil)
 
-	logger.Info("scraping completed successfully")
+	// Step 5: Refresh daily aggregates for the days this run inserted prices
+	// into. A dry run inserted none, and a replay has only buffered its own:
+	// Reprocess refreshes them once they are stored.
+	if (opts.runs(phasePrices) || opts.runs(phaseAggregates)) && !opts.DryRun && s.replay == nil {
+		startAggregates := time.Now()
+		if err := s.refreshAggregates(ctx, runStart, time.Now()); err != nil {
+			return fmt.Errorf("failed to refresh aggregates: %w", err)
+		}
+		s.metrics.RecordDuration("aggregates", time.Since(startAggregates), nil)
+		s.phaseCompleted(phaseAggregates, startAggregates)
+	}
+
+	s.logger.Info("scraping completed successfully")
 	return nil
 }
+
+// loadCategoryMap maps external category IDs to internal IDs from the
+// categories already stored
+func (s *Scraper) loadCategoryMap(ctx context.Context) (map[int]string, error) {
+	return s.db.CategoryIDs(ctx)
+}
+
+// loadCategoryParents maps each stored subcategory's external ID to its
+// parent's external ID
+func (s *Scraper) loadCategoryParents(ctx context.Context) (map[int]int, error) {
+	return s.db.CategoryParents(ctx)
+}
+
+// withSubcategories expands the categories scope includes or excludes with
+// their stored subcategories, which scrapeProducts checks each product's
+// category against
+func (s *Scraper) withSubcategories(ctx context.Context, scope Scope) (Scope, error) {
+	if len(scope.IncludeCategories) == 0 && len(scope.ExcludeCategories) == 0 {
+		return scope, nil
+	}
+	parents, err := s.loadCategoryParents(ctx)
+	if err != nil {
+		return Scope{}, fmt.Errorf("failed to load category hierarchy: %w", err)
+	}
+	return scope.withSubcategories(parents), nil
+}
+
+// loadProductMap maps external product IDs to internal IDs for the stored
+// products in scope. A product is in a category's scope if its category or
+// that category's parent is.
+func (s *Scraper) loadProductMap(ctx context.Context, scope Scope) (map[int]string, error) {
+	products, err := s.db.ProductCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	productMap := make(map[int]string)
+	for _, p := range products {
+		path := []int{p.CategoryExternalID}
+		if p.ParentExternalID != nil {
+			path = append(path, *p.ParentExternalID)
+		}
+		if scope.AllowsProduct(p.ExternalID) && scope.allowsCategoryPath(path...) {
+			productMap[p.ExternalID] = p.ID
+		}
+	}
+
+	return productMap, nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pheever/cy-price-watchdog/scraper/src/config"
+	ekalathiapi "github.com/pheever/cy-price-watchdog/scraper/src/ekalathi-api"
+	"github.com/pheever/cy-price-watchdog/scraper/src/metrics"
+	"github.com/pheever/cy-price-watchdog/scraper/src/store"
+)
+
+// handlerTransport serves requests with an http.Handler instead of the network
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+// newTestScraper returns a scraper writing to db whose eKalathi requests are
+// answered by api
+func newTestScraper(t *testing.T, db store.Store, api http.HandlerFunc) *Scraper {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Scrape.PageDelay = 0
+	cfg.Scrape.ItemDelay = 0
+	cfg.Retry.MaxRetries = 1
+	return &Scraper{
+		client:   &http.Client{Transport: handlerTransport{api}},
+		db:       db,
+		metrics:  metrics.NewWithOptions(metrics.Options{}),
+		progress: NewProgress(),
+		logger:   slog.New(slog.DiscardHandler),
+		scrape:   cfg.Scrape,
+		retry:    cfg.Retry,
+	}
+}
+
+// branchesAPI answers branch list requests with branches by region ID, and
+// fails for regions it has none for
+func branchesAPI(branches map[string][]ekalathiapi.RetailBranchResponse) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		content, ok := branches[r.URL.Query().Get("regionIds")]
+		if ekalathiapi.Endpoint(r) != ekalathiapi.RetailBranchesEndpoint || !ok {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(ekalathiapi.RetailBranchListResponse{Content: content, Last: true})
+	}
+}
+
+// seedProduct stores a category with one product and returns the product ID
+func seedProduct(t *testing.T, db store.Store, externalID int) string {
+	t.Helper()
+	ctx := context.Background()
+	categoryID, err := db.UpsertCategory(ctx, store.Category{ExternalID: 1, Name: "Dairy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	productID, err := db.UpsertProduct(ctx, store.Product{ExternalID: externalID, Name: "Milk", CategoryID: categoryID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return productID
+}
+
+func TestScrapePriceItems(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemory()
+	productID := seedProduct(t, db, 10)
+
+	s := newTestScraper(t, db, branchesAPI(map[string][]ekalathiapi.RetailBranchResponse{
+		"1": {
+			{ID: 100, Name: "A1", CompanyName: "Alpha", RetailerProductPrice: 1.2},
+			{ID: 200, Name: "B1", CompanyName: "Beta", RetailerProductPrice: 1.4},
+		},
+	}))
+
+	items := []productRegionItem{
+		{ProductExtID: 10, ProductIntID: productID, RegionID: 1, RegionName: "Nicosia"},
+		{ProductExtID: 10, ProductIntID: productID, RegionID: 2, RegionName: "Limassol"},
+	}
+	if err := s.scrapePriceItems(ctx, items); err != nil {
+		t.Fatalf("scrapePriceItems() error = %v", err)
+	}
+
+	if prices := db.Prices(); len(prices) != 2 {
+		t.Errorf("stored %d prices, want 2: %+v", len(prices), prices)
+	}
+	storeID, err := db.LookupID(ctx, store.KindStore, 100)
+	if err != nil {
+		t.Fatalf("store 100 was not stored: %v", err)
+	}
+	if latest, _ := db.LatestPrice(ctx, productID, storeID); latest.Price != 1.2 {
+		t.Errorf("price at A1 = %v, want 1.2", latest.Price)
+	}
+
+	failures, _ := db.Failures(ctx)
+	if len(failures) != 1 || *failures[0].RegionID != 2 || *failures[0].ProductExternalID != 10 {
+		t.Errorf("failures = %+v, want region 2 recorded after retries", failures)
+	}
+	if snap := s.progress.Snapshot(); snap.Done != 1 || snap.Failed != 1 {
+		t.Errorf("progress = %d done, %d failed, want 1 and 1", snap.Done, snap.Failed)
+	}
+}
+
+func TestScrapePriceItemsRateLimit(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemory()
+	productID := seedProduct(t, db, 10)
+
+	branches := map[string][]ekalathiapi.RetailBranchResponse{}
+	var items []productRegionItem
+	for region := 1; region <= 8; region++ {
+		branches[strconv.Itoa(region)] = []ekalathiapi.RetailBranchResponse{{ID: 100, Name: "A1", CompanyName: "Alpha", RetailerProductPrice: 1.2}}
+		items = append(items, productRegionItem{ProductExtID: 10, ProductIntID: productID, RegionID: region})
+	}
+
+	var mu sync.Mutex
+	var requests []time.Time
+	api := branchesAPI(branches)
+	s := newTestScraper(t, db, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, time.Now())
+		mu.Unlock()
+		api(w, r)
+	})
+	s.scrape.Concurrency = 4
+	s.scrape.ItemDelay = 20 * time.Millisecond
+
+	if err := s.scrapePriceItems(ctx, items); err != nil {
+		t.Fatalf("scrapePriceItems() error = %v", err)
+	}
+
+	// Four workers share one limit, so the requests are as far apart as a
+	// single worker's
+	slices.SortFunc(requests, time.Time.Compare)
+	if len(requests) != len(items) {
+		t.Fatalf("%d requests, want %d", len(requests), len(items))
+	}
+	for i := 1; i < len(requests); i++ {
+		if gap := requests[i].Sub(requests[i-1]); gap < 15*time.Millisecond {
+			t.Errorf("request %d came %v after the previous one, want at least the 20ms item delay", i+1, gap)
+		}
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	l := newRateLimiter(time.Hour)
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatalf("first wait() error = %v, want none", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx); err != context.Canceled {
+		t.Errorf("wait() after cancel = %v, want context.Canceled", err)
+	}
+}
+
+func TestScrapePriceItemsDryRun(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemory()
+	productID := seedProduct(t, db, 10)
+
+	// A1 is known with a price of 1.0; B1 is new
+	s := newTestScraper(t, db, branchesAPI(map[string][]ekalathiapi.RetailBranchResponse{
+		"1": {{ID: 100, Name: "A1", CompanyName: "Alpha", RetailerProductPrice: 1.0}},
+	}))
+	if err := s.scrapePriceItems(ctx, []productRegionItem{{ProductExtID: 10, ProductIntID: productID, RegionID: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	s = newTestScraper(t, db, branchesAPI(map[string][]ekalathiapi.RetailBranchResponse{
+		"1": {
+			{ID: 100, Name: "A1", CompanyName: "Alpha", RetailerProductPrice: 1.1},
+			{ID: 200, Name: "B1", CompanyName: "Beta", RetailerProductPrice: 1.4},
+		},
+	}))
+	s.dryRun = &dryRun{}
+	if err := s.scrapePriceItems(ctx, []productRegionItem{
+		{ProductExtID: 10, ProductIntID: productID, RegionID: 1},
+		{ProductExtID: 10, ProductIntID: productID, RegionID: 2},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if prices := db.Prices(); len(prices) != 1 {
+		t.Errorf("a dry run stored prices: %+v", prices)
+	}
+	if _, err := db.LookupID(ctx, store.KindStore, 200); err == nil {
+		t.Error("a dry run stored a new store")
+	}
+	if failures, _ := db.Failures(ctx); len(failures) != 0 {
+		t.Errorf("a dry run recorded failures: %+v", failures)
+	}
+
+	summary := s.dryRun.Summary()
+	if summary.Stores != (DryRunCounts{New: 1, Existing: 1}) {
+		t.Errorf("stores = %+v, want 1 new and 1 existing", summary.Stores)
+	}
+	if summary.Prices != (DryRunPriceCounts{New: 1, Changed: 1}) {
+		t.Errorf("prices = %+v, want 1 new and 1 changed", summary.Prices)
+	}
+	if len(summary.PriceChanges) != 1 || summary.PriceChanges[0] != (DryRunPriceChange{Product: "Milk", Store: "A1", Previous: 1.0, Price: 1.1}) {
+		t.Errorf("price changes = %+v, want A1 from 1.0 to 1.1", summary.PriceChanges)
+	}
+	if len(summary.NewStores) != 1 || summary.NewStores[0].ExternalID != 200 {
+		t.Errorf("new stores = %+v, want B1", summary.NewStores)
+	}
+}
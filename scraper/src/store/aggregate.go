@@ -0,0 +1,93 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// DailyAggregate is a row of the daily price aggregates. An empty Chain and
+// District means the row covers all stores for that product and day.
+type DailyAggregate struct {
+	ProductID   string
+	Day         time.Time
+	Chain       string
+	District    string
+	MinPrice    float64
+	MaxPrice    float64
+	MeanPrice   float64
+	MedianPrice float64
+	SampleCount int
+}
+
+// priceSample is a price scraped on the day being aggregated, with the chain
+// and district of its store
+type priceSample struct {
+	ProductID string
+	Chain     string
+	District  string
+	Price     float64
+}
+
+// aggregateDay computes the aggregate rows of a day for stores that keep
+// "PriceDaily" in Go rather than SQL: one row per product over all stores,
+// and one per product and chain or district, ordered by product, chain and
+// district
+func aggregateDay(day time.Time, samples []priceSample) []DailyAggregate {
+	type group struct{ productID, chain, district string }
+	groups := make(map[group][]float64)
+	for _, s := range samples {
+		all := group{productID: s.ProductID}
+		groups[all] = append(groups[all], s.Price)
+		if s.Chain != "" {
+			g := group{productID: s.ProductID, chain: s.Chain}
+			groups[g] = append(groups[g], s.Price)
+		}
+		if s.District != "" {
+			g := group{productID: s.ProductID, district: s.District}
+			groups[g] = append(groups[g], s.Price)
+		}
+	}
+
+	rows := make([]DailyAggregate, 0, len(groups))
+	for g, prices := range groups {
+		slices.Sort(prices)
+		sum := 0.0
+		for _, price := range prices {
+			sum += price
+		}
+		rows = append(rows, DailyAggregate{
+			ProductID:   g.productID,
+			Day:         startOfDay(day),
+			Chain:       g.chain,
+			District:    g.district,
+			MinPrice:    prices[0],
+			MaxPrice:    prices[len(prices)-1],
+			MeanPrice:   sum / float64(len(prices)),
+			MedianPrice: median(prices),
+			SampleCount: len(prices),
+		})
+	}
+	sortAggregates(rows)
+	return rows
+}
+
+func sortAggregates(rows []DailyAggregate) {
+	slices.SortFunc(rows, func(a, b DailyAggregate) int {
+		return cmp.Or(
+			cmp.Compare(a.ProductID, b.ProductID),
+			cmp.Compare(a.Chain, b.Chain),
+			cmp.Compare(a.District, b.District),
+		)
+	})
+}
+
+// median interpolates between the middle values of sorted prices, like
+// percentile_cont(0.5)
+func median(sorted []float64) float64 {
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
@@ -0,0 +1,431 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Memory is a Store that keeps everything in memory, for tests and for trial
+// runs that should leave no trace. Its zero value is not usable; create one
+// with NewMemory.
+type Memory struct {
+	mu         sync.Mutex
+	ids        map[Kind]map[int]string // internal IDs by kind and eKalathi ID
+	categories map[string]Category
+	products   map[string]Product
+	stores     map[string]Branch
+	prices     []Price
+	historical map[HistoricalPrice]float64 // prices by product, time and source
+	aggregates map[time.Time][]DailyAggregate
+	failures   []memoryFailure
+}
+
+type memoryFailure struct {
+	Failure
+	resolved bool
+}
+
+// NewMemory returns an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		ids: map[Kind]map[int]string{
+			KindCategory: {},
+			KindProduct:  {},
+			KindStore:    {},
+		},
+		categories: make(map[string]Category),
+		products:   make(map[string]Product),
+		stores:     make(map[string]Branch),
+		historical: make(map[HistoricalPrice]float64),
+		aggregates: make(map[time.Time][]DailyAggregate),
+	}
+}
+
+func (m *Memory) Ping(ctx context.Context) error { return nil }
+
+func (m *Memory) Close() {}
+
+// --- Write Methods ---
+
+// upsertID returns the ID of the row of kind with an eKalathi ID, assigning
+// one for a new row
+func (m *Memory) upsertID(kind Kind, externalID int) string {
+	id, ok := m.ids[kind][externalID]
+	if !ok {
+		id = uuid.New().String()
+		m.ids[kind][externalID] = id
+	}
+	return id
+}
+
+func (m *Memory) UpsertCategory(ctx context.Context, c Category) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.upsertID(KindCategory, c.ExternalID)
+	if c.ParentID != nil {
+		parent := *c.ParentID
+		c.ParentID = &parent
+	}
+	m.categories[id] = c
+	return id, nil
+}
+
+func (m *Memory) UpsertProduct(ctx context.Context, p Product) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.categories[p.CategoryID]; !ok {
+		return "", fmt.Errorf("failed to upsert product: unknown category %q", p.CategoryID)
+	}
+	id := m.upsertID(KindProduct, p.ExternalID)
+	m.products[id] = p
+	return id, nil
+}
+
+func (m *Memory) UpsertStore(ctx context.Context, b Branch) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.upsertID(KindStore, b.ExternalID)
+	m.stores[id] = b
+	return id, nil
+}
+
+func (m *Memory) InsertPrice(ctx context.Context, p Price) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.insertPrice(p)
+}
+
+func (m *Memory) insertPrice(p Price) error {
+	if _, ok := m.products[p.ProductID]; !ok {
+		return fmt.Errorf("failed to insert price: unknown product %q", p.ProductID)
+	}
+	if _, ok := m.stores[p.StoreID]; !ok {
+		return fmt.Errorf("failed to insert price: unknown store %q", p.StoreID)
+	}
+	p.ScrapedAt = p.ScrapedAt.UTC()
+	m.prices = append(m.prices, p)
+	return nil
+}
+
+func (m *Memory) InsertHistoricalPrice(ctx context.Context, h HistoricalPrice) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.products[h.ProductID]; !ok {
+		return false, fmt.Errorf("failed to insert historical price: unknown product %q", h.ProductID)
+	}
+	// Only the product, time and source identify an observation. An undated
+	// one has the zero time, and is replaced when its price changes.
+	key := HistoricalPrice{ProductID: h.ProductID, ObservedAt: h.ObservedAt.UTC(), Source: h.Source}
+	price, ok := m.historical[key]
+	if ok && (!h.ObservedAt.IsZero() || price == h.Price) {
+		return false, nil
+	}
+	m.historical[key] = h.Price
+	return true, nil
+}
+
+func (m *Memory) ReplacePrices(ctx context.Context, runID string, from, until time.Time, prices []Price) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Leave the stored prices alone unless every new one can be inserted
+	kept := slices.DeleteFunc(slices.Clone(m.prices), func(p Price) bool {
+		if p.RunID == "" {
+			return !p.ScrapedAt.Before(from) && p.ScrapedAt.Before(until)
+		}
+		return p.RunID == runID
+	})
+	deleted := int64(len(m.prices) - len(kept))
+
+	stored := m.prices
+	m.prices = kept
+	for _, p := range prices {
+		if err := m.insertPrice(p); err != nil {
+			m.prices = stored
+			return 0, err
+		}
+	}
+	return deleted, nil
+}
+
+// --- Read Methods ---
+
+func (m *Memory) LookupID(ctx context.Context, kind Kind, externalID int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids, ok := m.ids[kind]
+	if !ok {
+		return "", fmt.Errorf("unknown kind %q", kind)
+	}
+	id, ok := ids[externalID]
+	if !ok {
+		return "", fmt.Errorf("failed to look up %s %d: %w", kind, externalID, ErrNotFound)
+	}
+	return id, nil
+}
+
+func (m *Memory) CategoryIDByName(ctx context.Context, name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Prefer the lowest eKalathi ID so repeated lookups agree
+	found, foundExtID := "", 0
+	for id, c := range m.categories {
+		if c.Name == name && (found == "" || c.ExternalID < foundExtID) {
+			found, foundExtID = id, c.ExternalID
+		}
+	}
+	if found == "" {
+		return "", fmt.Errorf("failed to find category by name %s: %w", name, ErrNotFound)
+	}
+	return found, nil
+}
+
+func (m *Memory) CategoryIDs(ctx context.Context) (map[int]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return maps.Clone(m.ids[KindCategory]), nil
+}
+
+func (m *Memory) CategoryParents(ctx context.Context) (map[int]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	parents := make(map[int]int)
+	for _, c := range m.categories {
+		if c.ParentID == nil {
+			continue
+		}
+		if parent, ok := m.categories[*c.ParentID]; ok {
+			parents[c.ExternalID] = parent.ExternalID
+		}
+	}
+	return parents, nil
+}
+
+func (m *Memory) ProductCategories(ctx context.Context) ([]ProductCategory, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []ProductCategory
+	for id, p := range m.products {
+		c := m.categories[p.CategoryID]
+		pc := ProductCategory{ExternalID: p.ExternalID, ID: id, CategoryExternalID: c.ExternalID}
+		if c.ParentID != nil {
+			if parent, ok := m.categories[*c.ParentID]; ok {
+				pc.ParentExternalID = &parent.ExternalID
+			}
+		}
+		result = append(result, pc)
+	}
+	slices.SortFunc(result, func(a, b ProductCategory) int { return cmp.Compare(a.ExternalID, b.ExternalID) })
+	return result, nil
+}
+
+func (m *Memory) LatestPrice(ctx context.Context, productID, storeID string) (LatestPrice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var latest *Price
+	for i, p := range m.prices {
+		if p.ProductID == productID && p.StoreID == storeID && (latest == nil || !p.ScrapedAt.Before(latest.ScrapedAt)) {
+			latest = &m.prices[i]
+		}
+	}
+	if latest == nil {
+		return LatestPrice{}, fmt.Errorf("failed to look up previous price: %w", ErrNotFound)
+	}
+	return LatestPrice{
+		Price:   latest.Price,
+		Product: m.products[productID].Name,
+		Store:   m.stores[storeID].Name,
+	}, nil
+}
+
+func (m *Memory) ExportPrices(ctx context.Context, filter PriceFilter, fn func(PriceRecord) error) error {
+	m.mu.Lock()
+	var records []PriceRecord
+	for _, p := range m.prices {
+		if !filter.From.IsZero() && p.ScrapedAt.Before(filter.From) {
+			continue
+		}
+		if !filter.Until.IsZero() && !p.ScrapedAt.Before(filter.Until) {
+			continue
+		}
+
+		product, store := m.products[p.ProductID], m.stores[p.StoreID]
+		category := m.categories[product.CategoryID]
+		var parent *Category
+		if category.ParentID != nil {
+			if c, ok := m.categories[*category.ParentID]; ok {
+				parent = &c
+			}
+		}
+		if len(filter.Categories) > 0 && !slices.Contains(filter.Categories, category.ExternalID) &&
+			(parent == nil || !slices.Contains(filter.Categories, parent.ExternalID)) {
+			continue
+		}
+		if len(filter.Chains) > 0 && !slices.Contains(filter.Chains, store.Chain) {
+			continue
+		}
+
+		r := PriceRecord{
+			ScrapedAt:           p.ScrapedAt,
+			Price:               p.Price,
+			ProductExternalID:   product.ExternalID,
+			ProductCode:         product.Code,
+			ProductName:         product.Name,
+			ProductNameEnglish:  product.NameEnglish,
+			CategoryExternalID:  category.ExternalID,
+			CategoryCode:        category.Code,
+			CategoryName:        category.Name,
+			CategoryNameEnglish: category.NameEnglish,
+			StoreExternalID:     store.ExternalID,
+			StoreName:           store.Name,
+			Chain:               store.Chain,
+			District:            store.District,
+			Location:            store.Location,
+		}
+		if parent != nil {
+			r.ParentCategoryExternalID = &parent.ExternalID
+			r.ParentCategoryName = &parent.Name
+			r.ParentCategoryNameEnglish = &parent.NameEnglish
+		}
+		records = append(records, r)
+	}
+	// Release the lock before calling fn, which may be slow
+	m.mu.Unlock()
+
+	slices.SortStableFunc(records, func(a, b PriceRecord) int {
+		return cmp.Or(
+			a.ScrapedAt.Compare(b.ScrapedAt),
+			cmp.Compare(a.ProductExternalID, b.ProductExternalID),
+			cmp.Compare(a.StoreExternalID, b.StoreExternalID),
+		)
+	})
+	for _, r := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prices returns every price inserted so far, in insertion order
+func (m *Memory) Prices() []Price {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.prices)
+}
+
+// --- Aggregate Methods ---
+
+func (m *Memory) RefreshDailyAggregates(ctx context.Context, day time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	start := startOfDay(day)
+	end := start.AddDate(0, 0, 1)
+
+	var samples []priceSample
+	for _, p := range m.prices {
+		if p.ScrapedAt.Before(start) || !p.ScrapedAt.Before(end) {
+			continue
+		}
+		store := m.stores[p.StoreID]
+		samples = append(samples, priceSample{ProductID: p.ProductID, Chain: store.Chain, District: store.District, Price: p.Price})
+	}
+	m.aggregates[start] = aggregateDay(start, samples)
+	return nil
+}
+
+// DailyAggregates returns the aggregate rows of a UTC day, ordered by
+// product, chain and district
+func (m *Memory) DailyAggregates(day time.Time) []DailyAggregate {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.Clone(m.aggregates[startOfDay(day)])
+}
+
+// --- Failure Methods ---
+
+func (m *Memory) RecordFailure(ctx context.Context, f Failure) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f.ID = uuid.New().String()
+	f.CreatedAt = time.Now().UTC()
+	m.failures = append(m.failures, memoryFailure{Failure: f})
+	return nil
+}
+
+func (m *Memory) Failures(ctx context.Context) ([]Failure, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var failures []Failure
+	for _, f := range m.failures {
+		if !f.resolved {
+			failures = append(failures, f.Failure)
+		}
+	}
+	return failures, nil
+}
+
+func (m *Memory) ResolveFailures(ctx context.Context, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.failures {
+		if slices.Contains(ids, m.failures[i].ID) {
+			m.failures[i].resolved = true
+		}
+	}
+	return nil
+}
+
+// --- Stats ---
+
+func (m *Memory) Stats(ctx context.Context, since time.Time) (Stats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := Stats{
+		Categories:       int64(len(m.categories)),
+		Products:         int64(len(m.products)),
+		Stores:           int64(len(m.stores)),
+		Prices:           int64(len(m.prices)),
+		HistoricalPrices: int64(len(m.historical)),
+	}
+	for _, p := range m.prices {
+		if !p.ScrapedAt.Before(since) {
+			stats.RecentPrices++
+		}
+		if stats.LatestScrape == nil || p.ScrapedAt.After(*stats.LatestScrape) {
+			latest := p.ScrapedAt
+			stats.LatestScrape = &latest
+		}
+	}
+	for _, f := range m.failures {
+		if !f.resolved {
+			stats.PendingFailures++
+		}
+	}
+	return stats, nil
+}
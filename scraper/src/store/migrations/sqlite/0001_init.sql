@@ -0,0 +1,108 @@
+-- SQLite schema matching the PostgreSQL tables the scraper writes, as
+-- defined in database/prisma/schema.prisma. Timestamps are UTC text with
+-- millisecond precision ("2006-01-02T15:04:05.000Z") so they sort
+-- chronologically; days are "2006-01-02". Prices are rounded on insert to
+-- the scale of the PostgreSQL DECIMAL columns.
+
+CREATE TABLE "Category" (
+    "id" TEXT NOT NULL PRIMARY KEY,
+    "externalId" INTEGER NOT NULL,
+    "code" TEXT NOT NULL,
+    "name" TEXT NOT NULL,
+    "nameEnglish" TEXT NOT NULL,
+    "parentId" TEXT REFERENCES "Category"("id") ON DELETE SET NULL ON UPDATE CASCADE,
+    "coicop" TEXT,
+    "createdAt" TEXT NOT NULL,
+    "updatedAt" TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX "Category_externalId_key" ON "Category"("externalId");
+CREATE INDEX "Category_parentId_idx" ON "Category"("parentId");
+CREATE INDEX "Category_coicop_idx" ON "Category"("coicop");
+
+CREATE TABLE "Product" (
+    "id" TEXT NOT NULL PRIMARY KEY,
+    "externalId" INTEGER NOT NULL,
+    "code" TEXT NOT NULL,
+    "name" TEXT NOT NULL,
+    "nameEnglish" TEXT NOT NULL,
+    "unit" TEXT,
+    "categoryId" TEXT NOT NULL REFERENCES "Category"("id") ON DELETE RESTRICT ON UPDATE CASCADE,
+    "createdAt" TEXT NOT NULL,
+    "updatedAt" TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX "Product_externalId_key" ON "Product"("externalId");
+CREATE INDEX "Product_categoryId_idx" ON "Product"("categoryId");
+CREATE INDEX "Product_name_idx" ON "Product"("name");
+
+CREATE TABLE "Store" (
+    "id" TEXT NOT NULL PRIMARY KEY,
+    "externalId" INTEGER NOT NULL,
+    "name" TEXT NOT NULL,
+    "nameEnglish" TEXT,
+    "chain" TEXT,
+    "district" TEXT,
+    "location" TEXT,
+    "createdAt" TEXT NOT NULL,
+    "updatedAt" TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX "Store_externalId_key" ON "Store"("externalId");
+CREATE INDEX "Store_chain_idx" ON "Store"("chain");
+CREATE INDEX "Store_district_idx" ON "Store"("district");
+
+CREATE TABLE "Price" (
+    "id" TEXT NOT NULL PRIMARY KEY,
+    "productId" TEXT NOT NULL REFERENCES "Product"("id") ON DELETE RESTRICT ON UPDATE CASCADE,
+    "storeId" TEXT NOT NULL REFERENCES "Store"("id") ON DELETE RESTRICT ON UPDATE CASCADE,
+    "price" REAL NOT NULL,
+    "scrapedAt" TEXT NOT NULL
+);
+
+CREATE INDEX "Price_productId_scrapedAt_idx" ON "Price"("productId", "scrapedAt");
+CREATE INDEX "Price_storeId_scrapedAt_idx" ON "Price"("storeId", "scrapedAt");
+CREATE INDEX "Price_scrapedAt_idx" ON "Price"("scrapedAt");
+
+CREATE TABLE "PriceDaily" (
+    "productId" TEXT NOT NULL REFERENCES "Product"("id") ON DELETE RESTRICT ON UPDATE CASCADE,
+    "day" TEXT NOT NULL,
+    "chain" TEXT NOT NULL DEFAULT '',
+    "district" TEXT NOT NULL DEFAULT '',
+    "minPrice" REAL NOT NULL,
+    "maxPrice" REAL NOT NULL,
+    "meanPrice" REAL NOT NULL,
+    "medianPrice" REAL NOT NULL,
+    "sampleCount" INTEGER NOT NULL,
+    "updatedAt" TEXT NOT NULL,
+
+    PRIMARY KEY ("productId", "day", "chain", "district")
+);
+
+CREATE INDEX "PriceDaily_day_idx" ON "PriceDaily"("day");
+
+CREATE TABLE "HistoricalPrice" (
+    "id" TEXT NOT NULL PRIMARY KEY,
+    "productId" TEXT NOT NULL REFERENCES "Product"("id") ON DELETE RESTRICT ON UPDATE CASCADE,
+    "price" REAL NOT NULL,
+    "observedAt" TEXT NOT NULL,
+    "source" TEXT NOT NULL,
+    "createdAt" TEXT NOT NULL
+);
+
+CREATE INDEX "HistoricalPrice_productId_observedAt_idx" ON "HistoricalPrice"("productId", "observedAt");
+CREATE UNIQUE INDEX "HistoricalPrice_productId_observedAt_source_key" ON "HistoricalPrice"("productId", "observedAt", "source");
+
+CREATE TABLE "ScrapeFailure" (
+    "id" TEXT NOT NULL PRIMARY KEY,
+    "phase" TEXT NOT NULL,
+    "categoryExternalId" INTEGER,
+    "productExternalId" INTEGER,
+    "regionId" INTEGER,
+    "regionName" TEXT,
+    "error" TEXT NOT NULL,
+    "createdAt" TEXT NOT NULL,
+    "resolvedAt" TEXT
+);
+
+CREATE INDEX "ScrapeFailure_resolvedAt_idx" ON "ScrapeFailure"("resolvedAt");
@@ -0,0 +1,29 @@
+-- Prices the source gives no date for, such as a product's previous price,
+-- have no "observedAt" and are kept once per product and source. Backfills
+-- used to record them against the day they ran; keep the latest of those.
+CREATE TABLE "HistoricalPrice_new" (
+    "id" TEXT NOT NULL PRIMARY KEY,
+    "productId" TEXT NOT NULL REFERENCES "Product"("id") ON DELETE RESTRICT ON UPDATE CASCADE,
+    "price" REAL NOT NULL,
+    "observedAt" TEXT,
+    "source" TEXT NOT NULL,
+    "createdAt" TEXT NOT NULL
+);
+
+INSERT INTO "HistoricalPrice_new" ("id", "productId", "price", "observedAt", "source", "createdAt")
+SELECT h."id", h."productId", h."price",
+    CASE WHEN h."source" IN ('ekalathi_previous_price', 'ekalathi_start_price') THEN NULL ELSE h."observedAt" END,
+    h."source", h."createdAt"
+FROM "HistoricalPrice" h
+WHERE h."source" NOT IN ('ekalathi_previous_price', 'ekalathi_start_price')
+    OR h."observedAt" = (
+        SELECT MAX(o."observedAt") FROM "HistoricalPrice" o
+        WHERE o."productId" = h."productId" AND o."source" = h."source"
+    );
+
+DROP TABLE "HistoricalPrice";
+ALTER TABLE "HistoricalPrice_new" RENAME TO "HistoricalPrice";
+
+CREATE INDEX "HistoricalPrice_productId_observedAt_idx" ON "HistoricalPrice"("productId", "observedAt");
+CREATE UNIQUE INDEX "HistoricalPrice_productId_observedAt_source_key" ON "HistoricalPrice"("productId", "observedAt", "source");
+CREATE UNIQUE INDEX "HistoricalPrice_productId_source_undated_key" ON "HistoricalPrice"("productId", "source") WHERE "observedAt" IS NULL;
@@ -0,0 +1,5 @@
+-- The run that scraped each price, so that reprocessing an archived run
+-- replaces its prices and nobody else's. Prices stored earlier have none.
+ALTER TABLE "Price" ADD COLUMN "runId" TEXT;
+
+CREATE INDEX "Price_runId_idx" ON "Price"("runId");
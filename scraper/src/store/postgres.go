@@ -0,0 +1,542 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Postgres is a Store backed by a PostgreSQL connection pool
+type Postgres struct {
+	pool *pgxpool.Pool
+}
+
+// OpenPostgres connects to PostgreSQL and checks the connection
+func OpenPostgres(ctx context.Context, dbURL string, opts Options) (*Postgres, error) {
+	poolConfig, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database URL: %w", err)
+	}
+	if opts.QueryTracer != nil {
+		poolConfig.ConnConfig.Tracer = opts.QueryTracer
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return &Postgres{pool: pool}, nil
+}
+
+func (p *Postgres) Ping(ctx context.Context) error {
+	return p.pool.Ping(ctx)
+}
+
+func (p *Postgres) Close() {
+	p.pool.Close()
+}
+
+// --- Write Methods ---
+
+func (p *Postgres) UpsertCategory(ctx context.Context, c Category) (string, error) {
+	var id string
+	now := time.Now().UTC()
+
+	err := p.pool.QueryRow(ctx, `
+		INSERT INTO "Category" (id, "externalId", code, name, "nameEnglish", "parentId", "createdAt", "updatedAt")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT ("externalId") DO UPDATE SET
+			code = EXCLUDED.code,
+			name = EXCLUDED.name,
+			"nameEnglish" = EXCLUDED."nameEnglish",
+			"parentId" = EXCLUDED."parentId",
+			"updatedAt" = EXCLUDED."updatedAt"
+		RETURNING id
+	`, uuid.New().String(), c.ExternalID, c.Code, c.Name, c.NameEnglish, c.ParentID, now).Scan(&id)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to upsert category: %w", err)
+	}
+
+	return id, nil
+}
+
+func (p *Postgres) UpsertProduct(ctx context.Context, pr Product) (string, error) {
+	var id string
+	now := time.Now().UTC()
+
+	err := p.pool.QueryRow(ctx, `
+		INSERT INTO "Product" (id, "externalId", code, name, "nameEnglish", "categoryId", "createdAt", "updatedAt")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT ("externalId") DO UPDATE SET
+			code = EXCLUDED.code,
+			name = EXCLUDED.name,
+			"nameEnglish" = EXCLUDED."nameEnglish",
+			"categoryId" = EXCLUDED."categoryId",
+			"updatedAt" = EXCLUDED."updatedAt"
+		RETURNING id
+	`, uuid.New().String(), pr.ExternalID, pr.Code, pr.Name, pr.NameEnglish, pr.CategoryID, now).Scan(&id)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to upsert product: %w", err)
+	}
+
+	return id, nil
+}
+
+func (p *Postgres) UpsertStore(ctx context.Context, b Branch) (string, error) {
+	var id string
+	now := time.Now().UTC()
+
+	err := p.pool.QueryRow(ctx, `
+		INSERT INTO "Store" (id, "externalId", name, chain, district, location, "createdAt", "updatedAt")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT ("externalId") DO UPDATE SET
+			name = EXCLUDED.name,
+			chain = EXCLUDED.chain,
+			district = COALESCE(EXCLUDED.district, "Store".district),
+			location = EXCLUDED.location,
+			"updatedAt" = EXCLUDED."updatedAt"
+		RETURNING id
+	`, uuid.New().String(), b.ExternalID, b.Name, b.Chain, b.District, b.Location, now).Scan(&id)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to upsert store: %w", err)
+	}
+
+	return id, nil
+}
+
+const insertPriceSQL = `
+	INSERT INTO "Price" (id, "productId", "storeId", price, "scrapedAt", "runId")
+	VALUES ($1, $2, $3, $4, $5, $6)
+`
+
+func (p *Postgres) InsertPrice(ctx context.Context, pr Price) error {
+	_, err := p.pool.Exec(ctx, insertPriceSQL, uuid.New().String(), pr.ProductID, pr.StoreID, pr.Price, pr.ScrapedAt, nullString(pr.RunID))
+
+	if err != nil {
+		return fmt.Errorf("failed to insert price: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) InsertHistoricalPrice(ctx context.Context, h HistoricalPrice) (bool, error) {
+	query := `
+		INSERT INTO "HistoricalPrice" (id, "productId", price, "observedAt", source, "createdAt")
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT ("productId", "observedAt", source) DO NOTHING
+	`
+	var observedAt *time.Time
+	if !h.ObservedAt.IsZero() {
+		observedAt = &h.ObservedAt
+	} else {
+		query = `
+			INSERT INTO "HistoricalPrice" (id, "productId", price, "observedAt", source, "createdAt")
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT ("productId", source) WHERE "observedAt" IS NULL
+			DO UPDATE SET price = EXCLUDED.price, "createdAt" = EXCLUDED."createdAt"
+			WHERE "HistoricalPrice".price <> EXCLUDED.price
+		`
+	}
+	tag, err := p.pool.Exec(ctx, query, uuid.New().String(), h.ProductID, h.Price, observedAt, h.Source, time.Now().UTC())
+
+	if err != nil {
+		return false, fmt.Errorf("failed to insert historical price: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+func (p *Postgres) ReplacePrices(ctx context.Context, runID string, from, until time.Time, prices []Price) (int64, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		DELETE FROM "Price"
+		WHERE "runId" = $1 OR ("runId" IS NULL AND "scrapedAt" >= $2 AND "scrapedAt" < $3)
+	`, runID, from.UTC(), until.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete prices: %w", err)
+	}
+
+	for _, pr := range prices {
+		if _, err := tx.Exec(ctx, insertPriceSQL, uuid.New().String(), pr.ProductID, pr.StoreID, pr.Price, pr.ScrapedAt, nullString(pr.RunID)); err != nil {
+			return 0, fmt.Errorf("failed to insert price: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit prices: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// --- Read Methods ---
+
+func (p *Postgres) LookupID(ctx context.Context, kind Kind, externalID int) (string, error) {
+	switch kind {
+	case KindCategory, KindProduct, KindStore:
+	default:
+		return "", fmt.Errorf("unknown kind %q", kind)
+	}
+
+	var id string
+	err := p.pool.QueryRow(ctx, fmt.Sprintf(`SELECT id FROM %q WHERE "externalId" = $1`, string(kind)), externalID).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s %d: %w", kind, externalID, notFound(err))
+	}
+
+	return id, nil
+}
+
+func (p *Postgres) CategoryIDByName(ctx context.Context, name string) (string, error) {
+	var id string
+	err := p.pool.QueryRow(ctx, `
+		SELECT id FROM "Category" WHERE name = $1 LIMIT 1
+	`, name).Scan(&id)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to find category by name %s: %w", name, notFound(err))
+	}
+
+	return id, nil
+}
+
+func (p *Postgres) CategoryIDs(ctx context.Context) (map[int]string, error) {
+	rows, err := p.pool.Query(ctx, `SELECT "externalId", id FROM "Category"`)
+	if err != nil {
+		return nil, err
+	}
+
+	categoryMap := make(map[int]string)
+	var externalID int
+	var id string
+	_, err = pgx.ForEachRow(rows, []any{&externalID, &id}, func() error {
+		categoryMap[externalID] = id
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return categoryMap, nil
+}
+
+func (p *Postgres) CategoryParents(ctx context.Context) (map[int]int, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT c."externalId", parent."externalId"
+		FROM "Category" c
+		JOIN "Category" parent ON c."parentId" = parent.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	parents := make(map[int]int)
+	var childID, parentID int
+	_, err = pgx.ForEachRow(rows, []any{&childID, &parentID}, func() error {
+		parents[childID] = parentID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return parents, nil
+}
+
+func (p *Postgres) ProductCategories(ctx context.Context) ([]ProductCategory, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT p."externalId", p.id, c."externalId", parent."externalId"
+		FROM "Product" p
+		JOIN "Category" c ON p."categoryId" = c.id
+		LEFT JOIN "Category" parent ON c."parentId" = parent.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (ProductCategory, error) {
+		var pc ProductCategory
+		err := row.Scan(&pc.ExternalID, &pc.ID, &pc.CategoryExternalID, &pc.ParentExternalID)
+		return pc, err
+	})
+}
+
+func (p *Postgres) LatestPrice(ctx context.Context, productID, storeID string) (LatestPrice, error) {
+	var latest LatestPrice
+	err := p.pool.QueryRow(ctx, `
+		SELECT pr.price, p.name, st.name
+		FROM "Price" pr
+		JOIN "Product" p ON pr."productId" = p.id
+		JOIN "Store" st ON pr."storeId" = st.id
+		WHERE pr."productId" = $1 AND pr."storeId" = $2
+		ORDER BY pr."scrapedAt" DESC
+		LIMIT 1
+	`, productID, storeID).Scan(&latest.Price, &latest.Product, &latest.Store)
+
+	if err != nil {
+		return latest, fmt.Errorf("failed to look up previous price: %w", notFound(err))
+	}
+
+	return latest, nil
+}
+
+func (p *Postgres) ExportPrices(ctx context.Context, filter PriceFilter, fn func(PriceRecord) error) error {
+	var from, until *time.Time
+	if !filter.From.IsZero() {
+		from = &filter.From
+	}
+	if !filter.Until.IsZero() {
+		until = &filter.Until
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT pr."scrapedAt", pr.price::float8,
+			p."externalId", p.code, p.name, p."nameEnglish",
+			c."externalId", c.code, c.name, c."nameEnglish",
+			parent."externalId", parent.name, parent."nameEnglish",
+			s."externalId", s.name, COALESCE(s.chain, ''), COALESCE(s.district, ''), COALESCE(s.location, '')
+		FROM "Price" pr
+		JOIN "Product" p ON pr."productId" = p.id
+		JOIN "Category" c ON p."categoryId" = c.id
+		LEFT JOIN "Category" parent ON c."parentId" = parent.id
+		JOIN "Store" s ON pr."storeId" = s.id
+		WHERE ($1::timestamp IS NULL OR pr."scrapedAt" >= $1)
+			AND ($2::timestamp IS NULL OR pr."scrapedAt" < $2)
+			AND (COALESCE(cardinality($3::int[]), 0) = 0 OR c."externalId" = ANY($3) OR parent."externalId" = ANY($3))
+			AND (COALESCE(cardinality($4::text[]), 0) = 0 OR s.chain = ANY($4))
+		ORDER BY pr."scrapedAt", p."externalId", s."externalId"
+	`, from, until, filter.Categories, filter.Chains)
+	if err != nil {
+		return fmt.Errorf("failed to query prices: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r PriceRecord
+		if err := rows.Scan(
+			&r.ScrapedAt, &r.Price,
+			&r.ProductExternalID, &r.ProductCode, &r.ProductName, &r.ProductNameEnglish,
+			&r.CategoryExternalID, &r.CategoryCode, &r.CategoryName, &r.CategoryNameEnglish,
+			&r.ParentCategoryExternalID, &r.ParentCategoryName, &r.ParentCategoryNameEnglish,
+			&r.StoreExternalID, &r.StoreName, &r.Chain, &r.District, &r.Location,
+		); err != nil {
+			return fmt.Errorf("failed to read price: %w", err)
+		}
+		r.ScrapedAt = r.ScrapedAt.UTC()
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query prices: %w", err)
+	}
+	return nil
+}
+
+// --- Aggregate Methods ---
+
+// aggregateGroupings are the breakdowns maintained in "PriceDaily". An empty
+// chain or district means the row covers all stores for that product and day,
+// so stores without one are left out of those breakdowns.
+var aggregateGroupings = []struct {
+	Name     string
+	Chain    string
+	District string
+	Filter   string
+	GroupBy  string
+}{
+	{Name: "product", Chain: "''", District: "''"},
+	{Name: "chain", Chain: "s.chain", District: "''", Filter: "AND COALESCE(s.chain, '') <> ''", GroupBy: ", s.chain"},
+	{Name: "district", Chain: "''", District: "s.district", Filter: "AND COALESCE(s.district, '') <> ''", GroupBy: ", s.district"},
+}
+
+// RefreshDailyAggregates recomputes the "PriceDaily" rows for a single UTC
+// day from the raw "Price" table in one transaction
+func (p *Postgres) RefreshDailyAggregates(ctx context.Context, day time.Time) error {
+	start := startOfDay(day)
+	end := start.AddDate(0, 0, 1)
+	now := time.Now().UTC()
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM "PriceDaily" WHERE day = $1`, start); err != nil {
+		return fmt.Errorf("failed to clear daily aggregates: %w", err)
+	}
+
+	for _, g := range aggregateGroupings {
+		_, err := tx.Exec(ctx, fmt.Sprintf(`
+			INSERT INTO "PriceDaily" ("productId", day, chain, district, "minPrice", "maxPrice", "meanPrice", "medianPrice", "sampleCount", "updatedAt")
+			SELECT
+				p."productId",
+				$1,
+				%s,
+				%s,
+				MIN(p.price),
+				MAX(p.price),
+				AVG(p.price),
+				percentile_cont(0.5) WITHIN GROUP (ORDER BY p.price),
+				COUNT(*),
+				$4
+			FROM "Price" p
+			JOIN "Store" s ON p."storeId" = s.id
+			WHERE p."scrapedAt" >= $2 AND p."scrapedAt" < $3 %s
+			GROUP BY p."productId"%s
+		`, g.Chain, g.District, g.Filter, g.GroupBy), start, start, end, now)
+		if err != nil {
+			return fmt.Errorf("failed to compute %s daily aggregates: %w", g.Name, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit daily aggregates: %w", err)
+	}
+
+	return nil
+}
+
+// DailyAggregates returns the aggregate rows of a UTC day, ordered by
+// product, chain and district
+func (p *Postgres) DailyAggregates(ctx context.Context, day time.Time) ([]DailyAggregate, error) {
+	start := startOfDay(day)
+	rows, err := p.pool.Query(ctx, `
+		SELECT "productId", chain, district, "minPrice"::float8, "maxPrice"::float8, "meanPrice"::float8, "medianPrice"::float8, "sampleCount"
+		FROM "PriceDaily"
+		WHERE day = $1
+		ORDER BY "productId", chain, district
+	`, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aggregates []DailyAggregate
+	for rows.Next() {
+		a := DailyAggregate{Day: start}
+		if err := rows.Scan(&a.ProductID, &a.Chain, &a.District, &a.MinPrice, &a.MaxPrice, &a.MeanPrice, &a.MedianPrice, &a.SampleCount); err != nil {
+			return nil, err
+		}
+		aggregates = append(aggregates, a)
+	}
+
+	return aggregates, rows.Err()
+}
+
+// --- Failure Methods ---
+
+func (p *Postgres) RecordFailure(ctx context.Context, f Failure) error {
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO "ScrapeFailure" (id, phase, "categoryExternalId", "productExternalId", "regionId", "regionName", error, "createdAt")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, uuid.New().String(), f.Phase, f.CategoryExternalID, f.ProductExternalID, f.RegionID, f.RegionName, f.Error, time.Now().UTC())
+
+	if err != nil {
+		return fmt.Errorf("failed to record scrape failure: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) Failures(ctx context.Context) ([]Failure, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, phase, "categoryExternalId", "productExternalId", "regionId", "regionName", error, "createdAt"
+		FROM "ScrapeFailure"
+		WHERE "resolvedAt" IS NULL
+		ORDER BY "createdAt"
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scrape failures: %w", err)
+	}
+
+	failures, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Failure, error) {
+		var f Failure
+		err := row.Scan(&f.ID, &f.Phase, &f.CategoryExternalID, &f.ProductExternalID, &f.RegionID, &f.RegionName, &f.Error, &f.CreatedAt)
+		return f, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scrape failures: %w", err)
+	}
+
+	return failures, nil
+}
+
+func (p *Postgres) ResolveFailures(ctx context.Context, ids []string) error {
+	_, err := p.pool.Exec(ctx, `
+		UPDATE "ScrapeFailure" SET "resolvedAt" = $2 WHERE id = ANY($1)
+	`, ids, time.Now().UTC())
+
+	if err != nil {
+		return fmt.Errorf("failed to resolve scrape failures: %w", err)
+	}
+
+	return nil
+}
+
+// --- Stats ---
+
+func (p *Postgres) Stats(ctx context.Context, since time.Time) (Stats, error) {
+	var stats Stats
+	err := p.pool.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM "Category"),
+			(SELECT COUNT(*) FROM "Product"),
+			(SELECT COUNT(*) FROM "Store"),
+			(SELECT COUNT(*) FROM "Price"),
+			(SELECT COUNT(*) FROM "Price" WHERE "scrapedAt" >= $1),
+			(SELECT COUNT(*) FROM "HistoricalPrice"),
+			(SELECT COUNT(*) FROM "ScrapeFailure" WHERE "resolvedAt" IS NULL),
+			(SELECT MAX("scrapedAt") FROM "Price")
+	`, since).Scan(
+		&stats.Categories,
+		&stats.Products,
+		&stats.Stores,
+		&stats.Prices,
+		&stats.RecentPrices,
+		&stats.HistoricalPrices,
+		&stats.PendingFailures,
+		&stats.LatestScrape,
+	)
+
+	if err != nil {
+		return stats, fmt.Errorf("failed to query stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// notFound maps pgx.ErrNoRows to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// startOfDay truncates t to the start of its UTC day
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
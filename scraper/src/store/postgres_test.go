@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+// openTestPostgres connects to the database named by TEST_DATABASE_URL and
+// empties its tables. The database must be disposable and have the Prisma
+// migrations applied. Without TEST_DATABASE_URL the test is skipped.
+func openTestPostgres(t *testing.T) *Postgres {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	p, err := OpenPostgres(ctx, dbURL, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+
+	if _, err := p.pool.Exec(ctx, `TRUNCATE "PriceDaily", "Price", "HistoricalPrice", "ScrapeFailure", "Store", "Product", "Category" CASCADE`); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPostgresDailyAggregates(t *testing.T) {
+	tests := map[string]func(t *testing.T, s Store){
+		"breakdowns":  testDailyAggregates,
+		"empty chain": testDailyAggregatesEmptyChain,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test(t, openTestPostgres(t))
+		})
+	}
+}
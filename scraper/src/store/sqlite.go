@@ -0,0 +1,685 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations holds the schema, applied in file name order by
+// OpenSQLite. Released files must not change; add a new one instead.
+//
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// Layouts of the timestamps and days stored as text, chosen so that text
+// order is time order
+const (
+	sqliteTimeLayout = "2006-01-02T15:04:05.000Z"
+	sqliteDayLayout  = time.DateOnly
+)
+
+// SQLite is a Store backed by a single SQLite database file, for running the
+// scraper without a PostgreSQL server. It keeps the PostgreSQL schema's
+// tables, keys and rounding; daily aggregates are computed in Go.
+type SQLite struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens or creates the database file at path and brings its schema
+// up to date
+func OpenSQLite(ctx context.Context, path string) (*SQLite, error) {
+	if path == "" {
+		return nil, errSQLitePath
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// SQLite allows one writer at a time; a single connection queues writes
+	// from concurrent workers instead of failing them with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	s := &SQLite{db: db}
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// errSQLitePath is returned for a sqlite URL without a file path
+var errSQLitePath = errors.New("sqlite database URL needs a file path, e.g. sqlite:///var/lib/scraper/prices.db")
+
+// migrate applies the migrations not yet recorded in "_migrations", each in
+// its own transaction
+func (s *SQLite) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS "_migrations" (
+			"name" TEXT NOT NULL PRIMARY KEY,
+			"appliedAt" TEXT NOT NULL
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	names, err := fs.Glob(sqliteMigrations, "migrations/sqlite/*.sql")
+	if err != nil {
+		return err
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		name := filepath.Base(name)
+		var applied int
+		if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM "_migrations" WHERE "name" = ?`, name).Scan(&applied); err != nil {
+			return fmt.Errorf("failed to check migration %s: %w", name, err)
+		}
+		if applied > 0 {
+			continue
+		}
+
+		script, err := sqliteMigrations.ReadFile("migrations/sqlite/" + name)
+		if err != nil {
+			return err
+		}
+		err = s.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO "_migrations" ("name", "appliedAt") VALUES (?, ?)`, name, sqliteTime(time.Now()))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// inTx runs fn in a transaction, committing if it succeeds
+func (s *SQLite) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLite) Close() {
+	s.db.Close()
+}
+
+// --- Write Methods ---
+
+func (s *SQLite) UpsertCategory(ctx context.Context, c Category) (string, error) {
+	var id string
+	now := sqliteTime(time.Now())
+
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO "Category" (id, "externalId", code, name, "nameEnglish", "parentId", "createdAt", "updatedAt")
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?7)
+		ON CONFLICT ("externalId") DO UPDATE SET
+			code = EXCLUDED.code,
+			name = EXCLUDED.name,
+			"nameEnglish" = EXCLUDED."nameEnglish",
+			"parentId" = EXCLUDED."parentId",
+			"updatedAt" = EXCLUDED."updatedAt"
+		RETURNING id
+	`, uuid.New().String(), c.ExternalID, c.Code, c.Name, c.NameEnglish, c.ParentID, now).Scan(&id)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to upsert category: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *SQLite) UpsertProduct(ctx context.Context, p Product) (string, error) {
+	var id string
+	now := sqliteTime(time.Now())
+
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO "Product" (id, "externalId", code, name, "nameEnglish", "categoryId", "createdAt", "updatedAt")
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?7)
+		ON CONFLICT ("externalId") DO UPDATE SET
+			code = EXCLUDED.code,
+			name = EXCLUDED.name,
+			"nameEnglish" = EXCLUDED."nameEnglish",
+			"categoryId" = EXCLUDED."categoryId",
+			"updatedAt" = EXCLUDED."updatedAt"
+		RETURNING id
+	`, uuid.New().String(), p.ExternalID, p.Code, p.Name, p.NameEnglish, p.CategoryID, now).Scan(&id)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to upsert product: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *SQLite) UpsertStore(ctx context.Context, b Branch) (string, error) {
+	var id string
+	now := sqliteTime(time.Now())
+
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO "Store" (id, "externalId", name, chain, district, location, "createdAt", "updatedAt")
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?7)
+		ON CONFLICT ("externalId") DO UPDATE SET
+			name = EXCLUDED.name,
+			chain = EXCLUDED.chain,
+			district = COALESCE(EXCLUDED.district, "Store".district),
+			location = EXCLUDED.location,
+			"updatedAt" = EXCLUDED."updatedAt"
+		RETURNING id
+	`, uuid.New().String(), b.ExternalID, b.Name, b.Chain, b.District, b.Location, now).Scan(&id)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to upsert store: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *SQLite) InsertPrice(ctx context.Context, p Price) error {
+	return insertSQLitePrice(ctx, s.db, p)
+}
+
+// insertSQLitePrice inserts a price with db, either the database or a
+// transaction
+func insertSQLitePrice(ctx context.Context, db interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}, p Price) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO "Price" (id, "productId", "storeId", price, "scrapedAt", "runId")
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, uuid.New().String(), p.ProductID, p.StoreID, roundTo(p.Price, 2), sqliteTime(p.ScrapedAt), nullString(p.RunID))
+
+	if err != nil {
+		return fmt.Errorf("failed to insert price: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLite) InsertHistoricalPrice(ctx context.Context, h HistoricalPrice) (bool, error) {
+	query := `
+		INSERT INTO "HistoricalPrice" (id, "productId", price, "observedAt", source, "createdAt")
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT ("productId", "observedAt", source) DO NOTHING
+	`
+	var observedAt any
+	if !h.ObservedAt.IsZero() {
+		observedAt = sqliteTime(h.ObservedAt)
+	} else {
+		query = `
+			INSERT INTO "HistoricalPrice" (id, "productId", price, "observedAt", source, "createdAt")
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT ("productId", source) WHERE "observedAt" IS NULL
+			DO UPDATE SET price = excluded.price, "createdAt" = excluded."createdAt"
+			WHERE price <> excluded.price
+		`
+	}
+	res, err := s.db.ExecContext(ctx, query, uuid.New().String(), h.ProductID, roundTo(h.Price, 2), observedAt, h.Source, sqliteTime(time.Now()))
+
+	if err != nil {
+		return false, fmt.Errorf("failed to insert historical price: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to insert historical price: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (s *SQLite) ReplacePrices(ctx context.Context, runID string, from, until time.Time, prices []Price) (int64, error) {
+	var deleted int64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `
+			DELETE FROM "Price"
+			WHERE "runId" = ? OR ("runId" IS NULL AND "scrapedAt" >= ? AND "scrapedAt" < ?)
+		`, runID, sqliteTime(from), sqliteTime(until))
+		if err != nil {
+			return fmt.Errorf("failed to delete prices: %w", err)
+		}
+		if deleted, err = result.RowsAffected(); err != nil {
+			return err
+		}
+
+		for _, p := range prices {
+			if err := insertSQLitePrice(ctx, tx, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// --- Read Methods ---
+
+func (s *SQLite) LookupID(ctx context.Context, kind Kind, externalID int) (string, error) {
+	switch kind {
+	case KindCategory, KindProduct, KindStore:
+	default:
+		return "", fmt.Errorf("unknown kind %q", kind)
+	}
+
+	var id string
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT id FROM %q WHERE "externalId" = ?`, string(kind)), externalID).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s %d: %w", kind, externalID, sqlNotFound(err))
+	}
+
+	return id, nil
+}
+
+func (s *SQLite) CategoryIDByName(ctx context.Context, name string) (string, error) {
+	var id string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id FROM "Category" WHERE name = ? LIMIT 1
+	`, name).Scan(&id)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to find category by name %s: %w", name, sqlNotFound(err))
+	}
+
+	return id, nil
+}
+
+func (s *SQLite) CategoryIDs(ctx context.Context) (map[int]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT "externalId", id FROM "Category"`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categoryMap := make(map[int]string)
+	for rows.Next() {
+		var externalID int
+		var id string
+		if err := rows.Scan(&externalID, &id); err != nil {
+			return nil, err
+		}
+		categoryMap[externalID] = id
+	}
+
+	return categoryMap, rows.Err()
+}
+
+func (s *SQLite) CategoryParents(ctx context.Context) (map[int]int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT c."externalId", parent."externalId"
+		FROM "Category" c
+		JOIN "Category" parent ON c."parentId" = parent.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parents := make(map[int]int)
+	for rows.Next() {
+		var childID, parentID int
+		if err := rows.Scan(&childID, &parentID); err != nil {
+			return nil, err
+		}
+		parents[childID] = parentID
+	}
+
+	return parents, rows.Err()
+}
+
+func (s *SQLite) ProductCategories(ctx context.Context) ([]ProductCategory, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT p."externalId", p.id, c."externalId", parent."externalId"
+		FROM "Product" p
+		JOIN "Category" c ON p."categoryId" = c.id
+		LEFT JOIN "Category" parent ON c."parentId" = parent.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []ProductCategory
+	for rows.Next() {
+		var pc ProductCategory
+		if err := rows.Scan(&pc.ExternalID, &pc.ID, &pc.CategoryExternalID, &pc.ParentExternalID); err != nil {
+			return nil, err
+		}
+		products = append(products, pc)
+	}
+
+	return products, rows.Err()
+}
+
+func (s *SQLite) LatestPrice(ctx context.Context, productID, storeID string) (LatestPrice, error) {
+	var latest LatestPrice
+	err := s.db.QueryRowContext(ctx, `
+		SELECT pr.price, p.name, st.name
+		FROM "Price" pr
+		JOIN "Product" p ON pr."productId" = p.id
+		JOIN "Store" st ON pr."storeId" = st.id
+		WHERE pr."productId" = ? AND pr."storeId" = ?
+		ORDER BY pr."scrapedAt" DESC
+		LIMIT 1
+	`, productID, storeID).Scan(&latest.Price, &latest.Product, &latest.Store)
+
+	if err != nil {
+		return latest, fmt.Errorf("failed to look up previous price: %w", sqlNotFound(err))
+	}
+
+	return latest, nil
+}
+
+func (s *SQLite) ExportPrices(ctx context.Context, filter PriceFilter, fn func(PriceRecord) error) error {
+	var where []string
+	var args []any
+	if !filter.From.IsZero() {
+		where = append(where, `pr."scrapedAt" >= ?`)
+		args = append(args, sqliteTime(filter.From))
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, `pr."scrapedAt" < ?`)
+		args = append(args, sqliteTime(filter.Until))
+	}
+	if len(filter.Categories) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.Categories)), ", ")
+		where = append(where, `(c."externalId" IN (`+placeholders+`) OR parent."externalId" IN (`+placeholders+`))`)
+		for range 2 {
+			for _, id := range filter.Categories {
+				args = append(args, id)
+			}
+		}
+	}
+	if len(filter.Chains) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.Chains)), ", ")
+		where = append(where, `s."chain" IN (`+placeholders+`)`)
+		for _, chain := range filter.Chains {
+			args = append(args, chain)
+		}
+	}
+	query := `
+		SELECT pr."scrapedAt", pr."price",
+			p."externalId", p."code", p."name", p."nameEnglish",
+			c."externalId", c."code", c."name", c."nameEnglish",
+			parent."externalId", parent."name", parent."nameEnglish",
+			s."externalId", s."name", COALESCE(s."chain", ''), COALESCE(s."district", ''), COALESCE(s."location", '')
+		FROM "Price" pr
+		JOIN "Product" p ON pr."productId" = p."id"
+		JOIN "Category" c ON p."categoryId" = c."id"
+		LEFT JOIN "Category" parent ON c."parentId" = parent."id"
+		JOIN "Store" s ON pr."storeId" = s."id"
+	`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY pr."scrapedAt", p."externalId", s."externalId"`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query prices: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r PriceRecord
+		var scrapedAt string
+		if err := rows.Scan(
+			&scrapedAt, &r.Price,
+			&r.ProductExternalID, &r.ProductCode, &r.ProductName, &r.ProductNameEnglish,
+			&r.CategoryExternalID, &r.CategoryCode, &r.CategoryName, &r.CategoryNameEnglish,
+			&r.ParentCategoryExternalID, &r.ParentCategoryName, &r.ParentCategoryNameEnglish,
+			&r.StoreExternalID, &r.StoreName, &r.Chain, &r.District, &r.Location,
+		); err != nil {
+			return fmt.Errorf("failed to read price: %w", err)
+		}
+		if r.ScrapedAt, err = time.Parse(sqliteTimeLayout, scrapedAt); err != nil {
+			return fmt.Errorf("failed to read price: %w", err)
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query prices: %w", err)
+	}
+	return nil
+}
+
+// --- Aggregate Methods ---
+
+// RefreshDailyAggregates recomputes the "PriceDaily" rows for a single UTC
+// day in one transaction. SQLite has no percentile_cont, so the rows are
+// computed by aggregateDay.
+func (s *SQLite) RefreshDailyAggregates(ctx context.Context, day time.Time) error {
+	start := startOfDay(day)
+	end := start.AddDate(0, 0, 1)
+	now := sqliteTime(time.Now())
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT p."productId", COALESCE(s.chain, ''), COALESCE(s.district, ''), p.price
+			FROM "Price" p
+			JOIN "Store" s ON p."storeId" = s.id
+			WHERE p."scrapedAt" >= ? AND p."scrapedAt" < ?
+		`, sqliteTime(start), sqliteTime(end))
+		if err != nil {
+			return fmt.Errorf("failed to load prices: %w", err)
+		}
+		var samples []priceSample
+		for rows.Next() {
+			var sample priceSample
+			if err := rows.Scan(&sample.ProductID, &sample.Chain, &sample.District, &sample.Price); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to load prices: %w", err)
+			}
+			samples = append(samples, sample)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to load prices: %w", err)
+		}
+
+		dayText := start.Format(sqliteDayLayout)
+		if _, err := tx.ExecContext(ctx, `DELETE FROM "PriceDaily" WHERE day = ?`, dayText); err != nil {
+			return fmt.Errorf("failed to clear daily aggregates: %w", err)
+		}
+
+		for _, row := range aggregateDay(start, samples) {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO "PriceDaily" ("productId", day, chain, district, "minPrice", "maxPrice", "meanPrice", "medianPrice", "sampleCount", "updatedAt")
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`, row.ProductID, dayText, row.Chain, row.District,
+				roundTo(row.MinPrice, 2), roundTo(row.MaxPrice, 2), roundTo(row.MeanPrice, 4), roundTo(row.MedianPrice, 4),
+				row.SampleCount, now)
+			if err != nil {
+				return fmt.Errorf("failed to store daily aggregates: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// DailyAggregates returns the aggregate rows of a UTC day, ordered by
+// product, chain and district
+func (s *SQLite) DailyAggregates(ctx context.Context, day time.Time) ([]DailyAggregate, error) {
+	start := startOfDay(day)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT "productId", chain, district, "minPrice", "maxPrice", "meanPrice", "medianPrice", "sampleCount"
+		FROM "PriceDaily"
+		WHERE day = ?
+		ORDER BY "productId", chain, district
+	`, start.Format(sqliteDayLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aggregates []DailyAggregate
+	for rows.Next() {
+		a := DailyAggregate{Day: start}
+		if err := rows.Scan(&a.ProductID, &a.Chain, &a.District, &a.MinPrice, &a.MaxPrice, &a.MeanPrice, &a.MedianPrice, &a.SampleCount); err != nil {
+			return nil, err
+		}
+		aggregates = append(aggregates, a)
+	}
+
+	return aggregates, rows.Err()
+}
+
+// --- Failure Methods ---
+
+func (s *SQLite) RecordFailure(ctx context.Context, f Failure) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO "ScrapeFailure" (id, phase, "categoryExternalId", "productExternalId", "regionId", "regionName", error, "createdAt")
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, uuid.New().String(), f.Phase, f.CategoryExternalID, f.ProductExternalID, f.RegionID, f.RegionName, f.Error, sqliteTime(time.Now()))
+
+	if err != nil {
+		return fmt.Errorf("failed to record scrape failure: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLite) Failures(ctx context.Context) ([]Failure, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, phase, "categoryExternalId", "productExternalId", "regionId", "regionName", error, "createdAt"
+		FROM "ScrapeFailure"
+		WHERE "resolvedAt" IS NULL
+		ORDER BY "createdAt", rowid
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scrape failures: %w", err)
+	}
+	defer rows.Close()
+
+	var failures []Failure
+	for rows.Next() {
+		var f Failure
+		var createdAt string
+		if err := rows.Scan(&f.ID, &f.Phase, &f.CategoryExternalID, &f.ProductExternalID, &f.RegionID, &f.RegionName, &f.Error, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to load scrape failures: %w", err)
+		}
+		if f.CreatedAt, err = time.Parse(sqliteTimeLayout, createdAt); err != nil {
+			return nil, fmt.Errorf("failed to load scrape failures: %w", err)
+		}
+		failures = append(failures, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load scrape failures: %w", err)
+	}
+
+	return failures, nil
+}
+
+func (s *SQLite) ResolveFailures(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := []any{sqliteTime(time.Now())}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	_, err := s.db.ExecContext(ctx, `UPDATE "ScrapeFailure" SET "resolvedAt" = ? WHERE id IN (`+placeholders+`)`, args...)
+
+	if err != nil {
+		return fmt.Errorf("failed to resolve scrape failures: %w", err)
+	}
+
+	return nil
+}
+
+// --- Stats ---
+
+func (s *SQLite) Stats(ctx context.Context, since time.Time) (Stats, error) {
+	var stats Stats
+	var latest sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM "Category"),
+			(SELECT COUNT(*) FROM "Product"),
+			(SELECT COUNT(*) FROM "Store"),
+			(SELECT COUNT(*) FROM "Price"),
+			(SELECT COUNT(*) FROM "Price" WHERE "scrapedAt" >= ?),
+			(SELECT COUNT(*) FROM "HistoricalPrice"),
+			(SELECT COUNT(*) FROM "ScrapeFailure" WHERE "resolvedAt" IS NULL),
+			(SELECT MAX("scrapedAt") FROM "Price")
+	`, sqliteTime(since)).Scan(
+		&stats.Categories,
+		&stats.Products,
+		&stats.Stores,
+		&stats.Prices,
+		&stats.RecentPrices,
+		&stats.HistoricalPrices,
+		&stats.PendingFailures,
+		&latest,
+	)
+
+	if err != nil {
+		return stats, fmt.Errorf("failed to query stats: %w", err)
+	}
+
+	if latest.Valid {
+		t, err := time.Parse(sqliteTimeLayout, latest.String)
+		if err != nil {
+			return stats, fmt.Errorf("failed to query stats: %w", err)
+		}
+		stats.LatestScrape = &t
+	}
+
+	return stats, nil
+}
+
+// sqliteTime formats t as stored in SQLite
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+// sqlNotFound maps sql.ErrNoRows to ErrNotFound
+func sqlNotFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// roundTo rounds v to a number of decimal places, as a DECIMAL column would
+func roundTo(v float64, places int) float64 {
+	scale := math.Pow10(places)
+	return math.Round(v*scale) / scale
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLitePath(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"sqlite:///var/lib/scraper/prices.db", "/var/lib/scraper/prices.db"},
+		{"sqlite://prices.db", "prices.db"},
+		{"sqlite://data/prices.db", "data/prices.db"},
+		{"sqlite:prices.db", "prices.db"},
+		{"sqlite://", ""},
+	}
+
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sqlitePath(u); got != tt.want {
+			t.Errorf("sqlitePath(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestSQLiteReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "archive", "prices.db")
+
+	s, err := OpenSQLite(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	productID := seedPrices(t, s, time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC))
+	s.Close()
+
+	// Migrations already applied are skipped and the data is still there
+	s, err = OpenSQLite(ctx, path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer s.Close()
+
+	if id, err := s.LookupID(ctx, KindProduct, 10); err != nil || id != productID {
+		t.Errorf("LookupID() after reopening = %q, %v, want %q", id, err, productID)
+	}
+	var migrations int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM "_migrations"`).Scan(&migrations); err != nil || migrations == 0 {
+		t.Errorf("recorded %d migrations, %v", migrations, err)
+	}
+}
+
+func TestSQLiteRoundsPrices(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenSQLite(ctx, filepath.Join(t.TempDir(), "prices.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	day := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	productID := seedPrices(t, s, day)
+	storeID, _ := s.LookupID(ctx, KindStore, 100)
+	if err := s.InsertPrice(ctx, Price{ProductID: productID, StoreID: storeID, Price: 1.2345, ScrapedAt: day.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := s.LatestPrice(ctx, productID, storeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Price != 1.23 {
+		t.Errorf("stored price = %v, want it rounded to 1.23 like DECIMAL(10,2)", latest.Price)
+	}
+
+	other, _ := s.UpsertStore(ctx, Branch{ExternalID: 300, Name: "C1"})
+	if err := s.InsertPrice(ctx, Price{ProductID: "missing", StoreID: other, Price: 1, ScrapedAt: day}); err == nil {
+		t.Error("a price for an unknown product should violate the foreign key")
+	}
+}
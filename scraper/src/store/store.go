@@ -0,0 +1,275 @@
+// Package store persists what the scraper collects. Store is implemented for
+// PostgreSQL, the production database, SQLite for local and offline use, and
+// in memory for tests and trial runs; Open picks one from the database URL.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNotFound is returned by lookups that match no row
+var ErrNotFound = errors.New("not found")
+
+// Kind names a table of rows keyed by their eKalathi ID
+type Kind string
+
+const (
+	KindCategory Kind = "Category"
+	KindProduct  Kind = "Product"
+	KindStore    Kind = "Store"
+)
+
+// Category is an eKalathi category or subcategory
+type Category struct {
+	ExternalID  int
+	Code        string
+	Name        string
+	NameEnglish string
+	ParentID    *string // nil for a top level category
+}
+
+// Product is an eKalathi product in its category
+type Product struct {
+	ExternalID  int
+	Code        string
+	Name        string
+	NameEnglish string
+	CategoryID  string
+}
+
+// Branch is a retail branch, stored in "Store"
+type Branch struct {
+	ExternalID int
+	Name       string
+	Chain      string
+	District   string
+	Location   string
+}
+
+// Price is a product's price at a store when it was scraped
+type Price struct {
+	ProductID string
+	StoreID   string
+	Price     float64
+	ScrapedAt time.Time
+	RunID     string // the run that scraped it; empty for prices stored before runs were recorded
+}
+
+// HistoricalPrice is a price observation that was not scraped directly from
+// a store, e.g. from a product's price history
+type HistoricalPrice struct {
+	ProductID  string
+	Price      float64
+	ObservedAt time.Time // zero when the source gives no date
+	Source     string
+}
+
+// LatestPrice is the most recent price stored for a product at a store,
+// along with their names
+type LatestPrice struct {
+	Price   float64
+	Product string
+	Store   string
+}
+
+// ProductCategory places a stored product in the category hierarchy by
+// eKalathi IDs
+type ProductCategory struct {
+	ExternalID         int
+	ID                 string
+	CategoryExternalID int
+	ParentExternalID   *int // nil when the category is top level
+}
+
+// Failure is a work item that still failed after all retries. Failures are
+// kept so they can be retried without a full run.
+type Failure struct {
+	ID                 string
+	Phase              string
+	CategoryExternalID *int
+	ProductExternalID  *int
+	RegionID           *int
+	RegionName         *string
+	Error              string
+	CreatedAt          time.Time
+}
+
+// Stats are the totals shown by the stats command
+type Stats struct {
+	Categories       int64
+	Products         int64
+	Stores           int64
+	Prices           int64
+	RecentPrices     int64 // scraped since the time passed to Stats
+	HistoricalPrices int64
+	PendingFailures  int64
+	LatestScrape     *time.Time
+}
+
+// PriceFilter selects the prices ExportPrices returns. Zero fields match
+// everything.
+type PriceFilter struct {
+	From       time.Time // scraped at or after
+	Until      time.Time // scraped before
+	Categories []int     // eKalathi category IDs, matching their subcategories too
+	Chains     []string  // exact chain names
+}
+
+// PriceRecord is a scraped price with the product, category and store it
+// belongs to, identified by eKalathi IDs
+type PriceRecord struct {
+	ScrapedAt                 time.Time
+	Price                     float64
+	ProductExternalID         int
+	ProductCode               string
+	ProductName               string
+	ProductNameEnglish        string
+	CategoryExternalID        int
+	CategoryCode              string
+	CategoryName              string
+	CategoryNameEnglish       string
+	ParentCategoryExternalID  *int // nil when the category is top level
+	ParentCategoryName        *string
+	ParentCategoryNameEnglish *string
+	StoreExternalID           int
+	StoreName                 string
+	Chain                     string
+	District                  string
+	Location                  string
+}
+
+// Store reads and writes everything a scrape run touches. Upserts are keyed
+// by eKalathi ID and return the internal ID of the row. Implementations must
+// be safe for concurrent use.
+type Store interface {
+	UpsertCategory(ctx context.Context, c Category) (string, error)
+	UpsertProduct(ctx context.Context, p Product) (string, error)
+	UpsertStore(ctx context.Context, b Branch) (string, error)
+	InsertPrice(ctx context.Context, p Price) error
+	// InsertHistoricalPrice reports whether the price was new; the same
+	// product, time and source are only stored once. An undated price
+	// replaces the one stored for the product and source, and is new when
+	// its value changed.
+	InsertHistoricalPrice(ctx context.Context, p HistoricalPrice) (bool, error)
+	// ReplacePrices replaces the prices run runID stored with prices in one
+	// transaction, returning how many it deleted. Prices stored without a
+	// run ID count as the run's when scraped at or after from and before
+	// until.
+	ReplacePrices(ctx context.Context, runID string, from, until time.Time, prices []Price) (int64, error)
+
+	// LookupID returns the internal ID of the row with an eKalathi ID, or
+	// ErrNotFound
+	LookupID(ctx context.Context, kind Kind, externalID int) (string, error)
+	// CategoryIDByName returns the ID of a category with the given name, or
+	// ErrNotFound
+	CategoryIDByName(ctx context.Context, name string) (string, error)
+	// CategoryIDs maps the eKalathi ID of every category to its internal ID
+	CategoryIDs(ctx context.Context) (map[int]string, error)
+	// CategoryParents maps the eKalathi ID of every subcategory to its
+	// parent's
+	CategoryParents(ctx context.Context) (map[int]int, error)
+	ProductCategories(ctx context.Context) ([]ProductCategory, error)
+	// LatestPrice returns the most recent price of a product at a store, or
+	// ErrNotFound
+	LatestPrice(ctx context.Context, productID, storeID string) (LatestPrice, error)
+	// ExportPrices calls fn for every price the filter matches, ordered by
+	// scrape time, then product and store eKalathi IDs. It stops at the
+	// first error fn returns.
+	ExportPrices(ctx context.Context, filter PriceFilter, fn func(PriceRecord) error) error
+
+	// RefreshDailyAggregates recomputes the daily price aggregates for the
+	// UTC day containing day, replacing whatever was there before
+	RefreshDailyAggregates(ctx context.Context, day time.Time) error
+
+	RecordFailure(ctx context.Context, f Failure) error
+	// Failures returns the failures that have not been resolved, oldest first
+	Failures(ctx context.Context) ([]Failure, error)
+	ResolveFailures(ctx context.Context, ids []string) error
+
+	Stats(ctx context.Context, since time.Time) (Stats, error)
+	Ping(ctx context.Context) error
+	Close()
+}
+
+// Options configures the stores Open creates
+type Options struct {
+	QueryTracer pgx.QueryTracer // traces PostgreSQL queries, if set
+}
+
+// Open connects to the store a database URL names: postgres:// and
+// postgresql:// URLs or key/value connection strings for PostgreSQL,
+// sqlite:///path/to/file.db for SQLite, and memory:// for an empty in-memory
+// store
+func Open(ctx context.Context, rawURL string, opts Options) (Store, error) {
+	if err := CheckURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	u := parseURL(rawURL)
+	switch u.Scheme {
+	case "memory":
+		return NewMemory(), nil
+	case "sqlite":
+		lite, err := OpenSQLite(ctx, sqlitePath(u))
+		if err != nil {
+			return nil, err
+		}
+		return lite, nil
+	}
+	pg, err := OpenPostgres(ctx, rawURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	return pg, nil
+}
+
+// CheckURL reports whether Open supports a database URL, without connecting
+func CheckURL(rawURL string) error {
+	u := parseURL(rawURL)
+	switch u.Scheme {
+	case "", "postgres", "postgresql", "memory":
+		return nil
+	case "sqlite":
+		if sqlitePath(u) == "" {
+			return errSQLitePath
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported database URL scheme %q (want postgres, sqlite or memory)", u.Scheme)
+	}
+}
+
+// parseURL parses a database URL with a lower-cased scheme. Key/value
+// connection strings have no scheme.
+func parseURL(rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &url.URL{}
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	return u
+}
+
+// sqlitePath returns the file a sqlite URL names: sqlite:///abs/prices.db is
+// absolute, sqlite://prices.db and sqlite:prices.db are relative
+func sqlitePath(u *url.URL) string {
+	if u.Opaque != "" {
+		return u.Opaque
+	}
+	return u.Host + u.Path
+}
+
+// nullString returns nil for an empty string, so that it is stored as NULL
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
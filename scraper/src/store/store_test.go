@@ -0,0 +1,477 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// implementations returns an empty store of each kind that can run without a
+// server. Every test below runs against all of them.
+func implementations(t *testing.T) map[string]func(t *testing.T) Store {
+	return map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemory() },
+		"sqlite": func(t *testing.T) Store {
+			s, err := OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "prices.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(s.Close)
+			return s
+		},
+	}
+}
+
+// forEachStore runs test against a new store of each implementation
+func forEachStore(t *testing.T, test func(t *testing.T, s Store)) {
+	for name, open := range implementations(t) {
+		t.Run(name, func(t *testing.T) {
+			test(t, open(t))
+		})
+	}
+}
+
+// dailyAggregates reads back the aggregates of a day, which Store has no
+// method for
+func dailyAggregates(t *testing.T, s Store, day time.Time) []DailyAggregate {
+	t.Helper()
+	switch s := s.(type) {
+	case *Memory:
+		return s.DailyAggregates(day)
+	case interface {
+		DailyAggregates(context.Context, time.Time) ([]DailyAggregate, error)
+	}:
+		rows, err := s.DailyAggregates(context.Background(), day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+	t.Fatalf("no way to read aggregates from %T", s)
+	return nil
+}
+
+func TestUpsert(t *testing.T) {
+	forEachStore(t, testUpsert)
+}
+
+func testUpsert(t *testing.T, m Store) {
+	ctx := context.Background()
+
+	dairy, err := m.UpsertCategory(ctx, Category{ExternalID: 1, Name: "Dairy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	milk, err := m.UpsertCategory(ctx, Category{ExternalID: 2, Name: "Milk", ParentID: &dairy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := m.UpsertCategory(ctx, Category{ExternalID: 2, Name: "Fresh milk", ParentID: &dairy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != milk {
+		t.Errorf("upserting the same eKalathi ID returned %q, want the existing %q", again, milk)
+	}
+
+	if id, err := m.CategoryIDByName(ctx, "Fresh milk"); err != nil || id != milk {
+		t.Errorf("CategoryIDByName() = %q, %v, want the updated name to match %q", id, err, milk)
+	}
+	if _, err := m.CategoryIDByName(ctx, "Milk"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CategoryIDByName() of the old name error = %v, want ErrNotFound", err)
+	}
+
+	product, err := m.UpsertProduct(ctx, Product{ExternalID: 10, Name: "Whole milk 1l", CategoryID: milk})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.UpsertProduct(ctx, Product{ExternalID: 11, CategoryID: "missing"}); err == nil {
+		t.Error("UpsertProduct() with an unknown category should fail")
+	}
+
+	if id, err := m.LookupID(ctx, KindProduct, 10); err != nil || id != product {
+		t.Errorf("LookupID(Product, 10) = %q, %v, want %q", id, err, product)
+	}
+	if _, err := m.LookupID(ctx, KindStore, 10); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LookupID(Store, 10) error = %v, want ErrNotFound", err)
+	}
+
+	parents, _ := m.CategoryParents(ctx)
+	if len(parents) != 1 || parents[2] != 1 {
+		t.Errorf("CategoryParents() = %v, want map[2:1]", parents)
+	}
+	products, _ := m.ProductCategories(ctx)
+	if len(products) != 1 || products[0].CategoryExternalID != 2 || products[0].ParentExternalID == nil || *products[0].ParentExternalID != 1 {
+		t.Errorf("ProductCategories() = %+v, want product 10 in category 2 under 1", products)
+	}
+}
+
+// seedPrices stores one product with prices at three stores across two
+// chains and districts
+func seedPrices(t *testing.T, m Store, day time.Time) (productID string) {
+	t.Helper()
+	ctx := context.Background()
+
+	category, _ := m.UpsertCategory(ctx, Category{ExternalID: 1, Name: "Dairy"})
+	productID, err := m.UpsertProduct(ctx, Product{ExternalID: 10, Name: "Milk", CategoryID: category})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	branches := []Branch{
+		{ExternalID: 100, Name: "A1", Chain: "Alpha", District: "Nicosia"},
+		{ExternalID: 101, Name: "A2", Chain: "Alpha", District: "Limassol"},
+		{ExternalID: 200, Name: "B1", Chain: "Beta", District: "Nicosia"},
+	}
+	for i, b := range branches {
+		storeID, err := m.UpsertStore(ctx, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		price := Price{ProductID: productID, StoreID: storeID, Price: float64(i + 1), ScrapedAt: day.Add(time.Duration(i) * time.Hour)}
+		if err := m.InsertPrice(ctx, price); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return productID
+}
+
+func TestLatestPrice(t *testing.T) {
+	forEachStore(t, testLatestPrice)
+}
+
+func testLatestPrice(t *testing.T, m Store) {
+	ctx := context.Background()
+	day := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	productID := seedPrices(t, m, day)
+	storeID, _ := m.LookupID(ctx, KindStore, 100)
+
+	if err := m.InsertPrice(ctx, Price{ProductID: productID, StoreID: storeID, Price: 1.25, ScrapedAt: day.Add(24 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := m.LatestPrice(ctx, productID, storeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != (LatestPrice{Price: 1.25, Product: "Milk", Store: "A1"}) {
+		t.Errorf("LatestPrice() = %+v, want the newer 1.25 at A1", latest)
+	}
+
+	other, _ := m.UpsertStore(ctx, Branch{ExternalID: 300, Name: "C1"})
+	if _, err := m.LatestPrice(ctx, productID, other); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LatestPrice() at a store without prices error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDailyAggregates(t *testing.T) {
+	forEachStore(t, testDailyAggregates)
+}
+
+func testDailyAggregates(t *testing.T, m Store) {
+	ctx := context.Background()
+	day := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	productID := seedPrices(t, m, day)
+
+	if err := m.RefreshDailyAggregates(ctx, day.Add(3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []DailyAggregate{
+		{Chain: "", District: "", MinPrice: 1, MaxPrice: 3, MeanPrice: 2, MedianPrice: 2, SampleCount: 3},
+		{Chain: "", District: "Limassol", MinPrice: 2, MaxPrice: 2, MeanPrice: 2, MedianPrice: 2, SampleCount: 1},
+		{Chain: "", District: "Nicosia", MinPrice: 1, MaxPrice: 3, MeanPrice: 2, MedianPrice: 2, SampleCount: 2},
+		{Chain: "Alpha", District: "", MinPrice: 1, MaxPrice: 2, MeanPrice: 1.5, MedianPrice: 1.5, SampleCount: 2},
+		{Chain: "Beta", District: "", MinPrice: 3, MaxPrice: 3, MeanPrice: 3, MedianPrice: 3, SampleCount: 1},
+	}
+	got := dailyAggregates(t, m, day)
+	if len(got) != len(want) {
+		t.Fatalf("DailyAggregates() returned %d rows, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		w.ProductID = productID
+		w.Day = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+		if got[i] != w {
+			t.Errorf("row %d = %+v, want %+v", i, got[i], w)
+		}
+	}
+
+	if rows := dailyAggregates(t, m, day.AddDate(0, 0, 1)); len(rows) != 0 {
+		t.Errorf("the next day has %d rows, want none", len(rows))
+	}
+}
+
+func TestDailyAggregatesEmptyChain(t *testing.T) {
+	forEachStore(t, testDailyAggregatesEmptyChain)
+}
+
+// testDailyAggregatesEmptyChain checks that stores without a chain or
+// district only count towards the rows covering all stores
+func testDailyAggregatesEmptyChain(t *testing.T, m Store) {
+	ctx := context.Background()
+	day := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+
+	category, _ := m.UpsertCategory(ctx, Category{ExternalID: 1, Name: "Dairy"})
+	productID, err := m.UpsertProduct(ctx, Product{ExternalID: 10, Name: "Milk", CategoryID: category})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range []Branch{
+		{ExternalID: 300, Name: "Kiosk"},
+		{ExternalID: 301, Name: "Corner", District: "Nicosia"},
+	} {
+		storeID, err := m.UpsertStore(ctx, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.InsertPrice(ctx, Price{ProductID: productID, StoreID: storeID, Price: float64(i + 1), ScrapedAt: day}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.RefreshDailyAggregates(ctx, day); err != nil {
+		t.Fatalf("RefreshDailyAggregates() error = %v", err)
+	}
+
+	want := []DailyAggregate{
+		{Chain: "", District: "", MinPrice: 1, MaxPrice: 2, MeanPrice: 1.5, MedianPrice: 1.5, SampleCount: 2},
+		{Chain: "", District: "Nicosia", MinPrice: 2, MaxPrice: 2, MeanPrice: 2, MedianPrice: 2, SampleCount: 1},
+	}
+	got := dailyAggregates(t, m, day)
+	if len(got) != len(want) {
+		t.Fatalf("DailyAggregates() returned %d rows, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		w.ProductID = productID
+		w.Day = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+		if got[i] != w {
+			t.Errorf("row %d = %+v, want %+v", i, got[i], w)
+		}
+	}
+}
+
+func TestFailures(t *testing.T) {
+	forEachStore(t, testFailures)
+}
+
+func testFailures(t *testing.T, m Store) {
+	ctx := context.Background()
+	categoryID := 7
+
+	for _, phase := range []string{"products", "prices"} {
+		if err := m.RecordFailure(ctx, Failure{Phase: phase, CategoryExternalID: &categoryID, Error: "timeout"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failures, _ := m.Failures(ctx)
+	if len(failures) != 2 || failures[0].Phase != "products" || failures[0].ID == "" {
+		t.Fatalf("Failures() = %+v, want both, oldest first", failures)
+	}
+
+	if err := m.ResolveFailures(ctx, []string{failures[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+	failures, _ = m.Failures(ctx)
+	if len(failures) != 1 || failures[0].Phase != "prices" {
+		t.Errorf("Failures() after resolving = %+v, want only the prices failure", failures)
+	}
+
+	stats, _ := m.Stats(ctx, time.Now().Add(-time.Hour))
+	if stats.PendingFailures != 1 {
+		t.Errorf("Stats().PendingFailures = %d, want 1", stats.PendingFailures)
+	}
+}
+
+func TestHistoricalPrices(t *testing.T) {
+	forEachStore(t, func(t *testing.T, m Store) {
+		ctx := context.Background()
+		day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+		productID := seedPrices(t, m, day)
+
+		h := HistoricalPrice{ProductID: productID, Price: 1.99, ObservedAt: day, Source: "ekalathi_price_history"}
+		for i, want := range []bool{true, false} {
+			inserted, err := m.InsertHistoricalPrice(ctx, h)
+			if err != nil || inserted != want {
+				t.Errorf("insert %d = %v, %v, want %v", i+1, inserted, err, want)
+			}
+		}
+
+		// Undated prices are kept once per product and source, replaced when
+		// the value changes
+		for i, step := range []struct {
+			price float64
+			want  bool
+		}{{1.50, true}, {1.50, false}, {1.60, true}} {
+			undated := HistoricalPrice{ProductID: productID, Price: step.price, Source: "ekalathi_previous_price"}
+			inserted, err := m.InsertHistoricalPrice(ctx, undated)
+			if err != nil || inserted != step.want {
+				t.Errorf("undated insert %d = %v, %v, want %v", i+1, inserted, err, step.want)
+			}
+		}
+
+		stats, err := m.Stats(ctx, day.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Stats{Categories: 1, Products: 1, Stores: 3, Prices: 3, RecentPrices: 2, HistoricalPrices: 2}
+		latest := stats.LatestScrape
+		stats.LatestScrape = nil
+		if stats != want {
+			t.Errorf("Stats() = %+v, want %+v", stats, want)
+		}
+		if latest == nil || !latest.Equal(day.Add(2*time.Hour)) {
+			t.Errorf("Stats().LatestScrape = %v, want %v", latest, day.Add(2*time.Hour))
+		}
+	})
+}
+
+func TestReplacePrices(t *testing.T) {
+	forEachStore(t, func(t *testing.T, m Store) {
+		ctx := context.Background()
+		day := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+		productID := seedPrices(t, m, day) // at 8:00, 9:00 and 10:00, with no run ID
+		a1, _ := m.LookupID(ctx, KindStore, 100)
+		b1, _ := m.LookupID(ctx, KindStore, 200)
+		for _, p := range []Price{
+			{ProductID: productID, StoreID: a1, Price: 5, ScrapedAt: day.Add(4 * time.Hour), RunID: "run-1"},
+			{ProductID: productID, StoreID: b1, Price: 7, ScrapedAt: day.Add(90 * time.Minute), RunID: "run-2"},
+		} {
+			if err := m.InsertPrice(ctx, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		prices := func() []float64 {
+			var got []float64
+			m.ExportPrices(ctx, PriceFilter{}, func(r PriceRecord) error {
+				got = append(got, r.Price)
+				return nil
+			})
+			return got
+		}
+
+		// A new price for a store that doesn't exist fails, and changes nothing
+		replacement := Price{ProductID: productID, StoreID: a1, Price: 6, ScrapedAt: day.Add(4 * time.Hour), RunID: "run-1"}
+		broken := Price{ProductID: productID, StoreID: "missing", Price: 6, ScrapedAt: day.Add(4 * time.Hour), RunID: "run-1"}
+		if _, err := m.ReplacePrices(ctx, "run-1", day.Add(time.Hour), day.Add(2*time.Hour), []Price{replacement, broken}); err == nil {
+			t.Fatal("ReplacePrices() with an unknown store succeeded")
+		}
+		if got := prices(); !slices.Equal(got, []float64{1, 2, 7, 3, 5}) {
+			t.Fatalf("prices after a failed replace = %v, want them untouched", got)
+		}
+
+		// Run 1's price goes, and so does the 9:00 one with no run ID; run 2's
+		// stays though it was scraped while run 1 was going
+		deleted, err := m.ReplacePrices(ctx, "run-1", day.Add(time.Hour), day.Add(2*time.Hour), []Price{replacement})
+		if err != nil || deleted != 2 {
+			t.Fatalf("ReplacePrices() = %d, %v, want run 1's price and the 9:00 one", deleted, err)
+		}
+		if got := prices(); !slices.Equal(got, []float64{1, 7, 3, 6}) {
+			t.Errorf("prices = %v, want run 1's replaced and run 2's kept", got)
+		}
+	})
+}
+
+func TestExportPrices(t *testing.T) {
+	forEachStore(t, func(t *testing.T, m Store) {
+		ctx := context.Background()
+		day := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+		seedPrices(t, m, day)
+
+		// A second product in a subcategory of Dairy, at B1 the next day
+		dairy, _ := m.LookupID(ctx, KindCategory, 1)
+		cheese, _ := m.UpsertCategory(ctx, Category{ExternalID: 2, Code: "D2", Name: "Τυριά", NameEnglish: "Cheese", ParentID: &dairy})
+		halloumi, _ := m.UpsertProduct(ctx, Product{ExternalID: 20, Name: "Halloumi", CategoryID: cheese})
+		b1, _ := m.LookupID(ctx, KindStore, 200)
+		if err := m.InsertPrice(ctx, Price{ProductID: halloumi, StoreID: b1, Price: 4.5, ScrapedAt: day.AddDate(0, 0, 1)}); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name   string
+			filter PriceFilter
+			want   []int // store eKalathi IDs in order
+		}{
+			{"everything", PriceFilter{}, []int{100, 101, 200, 200}},
+			{"from", PriceFilter{From: day.Add(time.Hour)}, []int{101, 200, 200}},
+			{"until", PriceFilter{Until: day.Add(time.Hour)}, []int{100}},
+			{"parent category", PriceFilter{Categories: []int{1}}, []int{100, 101, 200, 200}},
+			{"subcategory", PriceFilter{Categories: []int{2}}, []int{200}},
+			{"chain", PriceFilter{Chains: []string{"Alpha"}}, []int{100, 101}},
+			{"no match", PriceFilter{Chains: []string{"Gamma"}}, nil},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var got []int
+				err := m.ExportPrices(ctx, tt.filter, func(r PriceRecord) error {
+					got = append(got, r.StoreExternalID)
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("ExportPrices() stores = %v, want %v", got, tt.want)
+				}
+			})
+		}
+
+		var last PriceRecord
+		m.ExportPrices(ctx, PriceFilter{}, func(r PriceRecord) error {
+			last = r
+			return nil
+		})
+		parent := 1
+		dairyName, empty := "Dairy", ""
+		want := PriceRecord{
+			ScrapedAt: day.AddDate(0, 0, 1), Price: 4.5,
+			ProductExternalID: 20, ProductName: "Halloumi",
+			CategoryExternalID: 2, CategoryCode: "D2", CategoryName: "Τυριά", CategoryNameEnglish: "Cheese",
+			ParentCategoryExternalID: &parent, ParentCategoryName: &dairyName, ParentCategoryNameEnglish: &empty,
+			StoreExternalID: 200, StoreName: "B1", Chain: "Beta", District: "Nicosia",
+		}
+		if !reflect.DeepEqual(last, want) {
+			t.Errorf("last record = %+v, want %+v", last, want)
+		}
+
+		stop := errors.New("stop")
+		calls := 0
+		err := m.ExportPrices(ctx, PriceFilter{}, func(r PriceRecord) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("ExportPrices() = %v after %d calls, want fn's error after 1", err, calls)
+		}
+	})
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"memory://", false},
+		{"sqlite://" + filepath.Join(t.TempDir(), "prices.db"), false},
+		{"sqlite://", true},
+		{"mysql://localhost/prices", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			s, err := Open(context.Background(), tt.url, Options{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+			if s != nil {
+				s.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	ekalathiapi "github.com/pheever/cy-price-watchdog/scraper/src/ekalathi-api"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates every span of the scraper. It delegates to the global
+// provider, so spans are only exported once setupTracing has installed one.
+var tracer = otel.Tracer("github.com/pheever/cy-price-watchdog/scraper/src")
+
+// Span attributes shared by the scrape phases, HTTP calls and database calls
+const (
+	attrPhase      = attribute.Key("scraper.phase")
+	attrCategoryID = attribute.Key("ekalathi.category_id")
+	attrProductID  = attribute.Key("ekalathi.product_id")
+	attrRegionID   = attribute.Key("ekalathi.region_id")
+	attrPage       = attribute.Key("ekalathi.page")
+	attrEndpoint   = attribute.Key("ekalathi.endpoint")
+	attrAttempt    = attribute.Key("scraper.attempt")
+)
+
+// setupTracing exports spans over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT
+// or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set. The exporter reads the rest
+// of its configuration (headers, timeout, ...) from the standard OTEL_
+// variables. Without an endpoint tracing stays a no-op. The returned function
+// flushes pending spans and must be called before exiting.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+	provider := newTracerProvider(sdktrace.NewBatchSpanProcessor(exporter))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// newTracerProvider creates a provider that sends spans to processor,
+// identifying them by OTEL_SERVICE_NAME (default "scraper")
+func newTracerProvider(processor sdktrace.SpanProcessor) *sdktrace.TracerProvider {
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "scraper"
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		res = resource.Default()
+	}
+	return sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(processor), sdktrace.WithResource(res))
+}
+
+// startPhaseSpan starts the span of a scrape phase
+func startPhaseSpan(ctx context.Context, phase string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "phase "+phase, trace.WithAttributes(attrPhase.String(phase)))
+}
+
+// decodeJSON decodes r into v inside its own span, so parsing time shows up
+// apart from the request in traces
+func decodeJSON(ctx context.Context, what string, r io.Reader, v any) error {
+	_, span := tracer.Start(ctx, "decode "+what)
+	err := json.NewDecoder(r).Decode(v)
+	endSpan(span, err)
+	return err
+}
+
+// endSpan records err on span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, context.Canceled) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// --- HTTP Tracing ---
+
+// tracingTransport wraps every eKalathi request in a client span carrying the
+// endpoint and the page, product, region and category it asks for. The span
+// ends once the response body is read to the end or closed, so it covers the
+// download as well as the wait for headers.
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := ekalathiapi.Endpoint(req)
+	ctx, span := tracer.Start(req.Context(), req.Method+" "+endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(requestAttributes(req),
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.String()),
+			semconv.ServerAddress(req.URL.Hostname()),
+			attrEndpoint.String(endpoint),
+		)...),
+	)
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		endSpan(span, err)
+		return resp, err
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	resp.Body = &spanBody{ReadCloser: resp.Body, span: span}
+	return resp, nil
+}
+
+// requestAttributes picks the page, product, region and category out of an
+// eKalathi query string
+func requestAttributes(req *http.Request) []attribute.KeyValue {
+	params := map[string]attribute.Key{
+		"page":        attrPage,
+		"id":          attrProductID,
+		"productId":   attrProductID,
+		"regionIds":   attrRegionID,
+		"categoryIds": attrCategoryID,
+	}
+
+	var attrs []attribute.KeyValue
+	query := req.URL.Query()
+	for param, key := range params {
+		if values := query[param]; len(values) > 0 {
+			attrs = append(attrs, key.String(strings.Join(values, ",")))
+		}
+	}
+	return attrs
+}
+
+// spanBody ends its span once, when the body is read to the end or closed
+type spanBody struct {
+	io.ReadCloser
+	span trace.Span
+	once sync.Once
+}
+
+func (b *spanBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(func() {
+			if err != io.EOF {
+				endSpan(b.span, err)
+				return
+			}
+			b.span.End()
+		})
+	}
+	return n, err
+}
+
+func (b *spanBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.span.End() })
+	return err
+}
+
+// --- Database Tracing ---
+
+// dbTracer is a pgx query tracer that wraps every statement in a client span
+type dbTracer struct{}
+
+func (dbTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = tracer.Start(ctx, "db "+sqlOperation(data.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBOperationName(sqlOperation(data.SQL)),
+			semconv.DBQueryText(strings.Join(strings.Fields(data.SQL), " ")),
+		),
+	)
+	return ctx
+}
+
+func (dbTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	err := data.Err
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = nil // an expected outcome, not a failure
+	}
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	endSpan(span, err)
+}
+
+// sqlOperation returns the leading keyword of a statement, e.g. "INSERT"
+func sqlOperation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "query"
+	}
+	return strings.ToUpper(fields[0])
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	spanExporter    = tracetest.NewInMemoryExporter()
+	installProvider sync.Once
+)
+
+// recordSpans routes spans to an in-memory exporter. The global provider can
+// only be delegated to once, so it is shared and reset between tests.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	installProvider.Do(func() {
+		otel.SetTracerProvider(newTracerProvider(sdktrace.NewSimpleSpanProcessor(spanExporter)))
+	})
+	spanExporter.Reset()
+	return spanExporter
+}
+
+func spanAttributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracingTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "9" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int64
+		wantError  bool
+		want       map[attribute.Key]string
+	}{
+		{
+			name:       "branches page",
+			query:      "?productId=12&regionIds=3&page=2",
+			wantStatus: 200,
+			want:       map[attribute.Key]string{attrProductID: "12", attrRegionID: "3", attrPage: "2"},
+		},
+		{
+			name:       "error status",
+			query:      "?categoryIds=7&page=9",
+			wantStatus: 502,
+			wantError:  true,
+			want:       map[attribute.Key]string{attrCategoryID: "7", attrPage: "9"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := recordSpans(t)
+			client := &http.Client{Transport: &tracingTransport{next: http.DefaultTransport}}
+
+			req, err := http.NewRequestWithContext(context.Background(), "GET", server.URL+"/api/test"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if n := len(exporter.GetSpans()); n != 0 {
+				t.Fatalf("span ended before the body was read, got %d spans", n)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("expected 1 span, got %d", len(spans))
+			}
+			attrs := spanAttributes(spans[0])
+			for key, want := range tt.want {
+				if got := attrs[key].AsString(); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+			if got := attrs["http.response.status_code"].AsInt64(); got != tt.wantStatus {
+				t.Errorf("status code = %d, want %d", got, tt.wantStatus)
+			}
+			if gotError := spans[0].Status.Code == codes.Error; gotError != tt.wantError {
+				t.Errorf("error status = %v, want %v", gotError, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONSpan(t *testing.T) {
+	exporter := recordSpans(t)
+	ctx, parent := tracer.Start(context.Background(), "parent")
+
+	var v map[string]int
+	if err := decodeJSON(ctx, "regions", strings.NewReader(""), &v); err == nil {
+		t.Fatal("expected an error decoding an empty reader")
+	}
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	decode := spans[0]
+	if decode.Name != "decode regions" || decode.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("decode span = %q under %v, want \"decode regions\" under the parent", decode.Name, decode.Parent.SpanID())
+	}
+	if decode.Status.Code != codes.Error {
+		t.Errorf("decode span status = %v, want Error", decode.Status.Code)
+	}
+}
+
+func TestSQLOperation(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{`INSERT INTO "Price" VALUES ($1)`, "INSERT"},
+		{"\n\t\tselect id from \"Product\"", "SELECT"},
+		{"", "query"},
+	}
+
+	for _, tt := range tests {
+		if got := sqlOperation(tt.sql); got != tt.want {
+			t.Errorf("sqlOperation(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}